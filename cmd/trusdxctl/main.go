@@ -0,0 +1,102 @@
+// trusdxctl is the control client of the trusdxd daemon, small and without
+// the audio and serial dependencies of the driver, for scripts and service
+// deployments.
+//
+// The control protocol, version 1, is stable: the client connects to the
+// Unix socket, sends a single line of space separated words, a command and
+// its arguments, and reads until the daemon closes the connection. The
+// first line of the answer is "OK", followed by the output of the command,
+// or "ERR <message>".
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/leshniak/trusdx-go/internal/config"
+)
+
+const protocolVersion = 1
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: trusdxctl [-socket path] [-timeout duration] <command> [arguments]
+
+Sends the command to the running trusdxd, "trusdxctl help" lists the
+commands. The socket is CONTROL_SOCKET, from the environment or the config
+file, or trusdx-go.sock in the temporary directory.
+
+`)
+	flag.PrintDefaults()
+}
+
+// socketPath finds the socket the way the daemon does, the environment
+// takes precedence over the config file.
+func socketPath() string {
+	config.Load(config.FilePath())
+
+	return config.ControlSocketPath()
+}
+
+func request(path string, timeout time.Duration, args []string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return "", fmt.Errorf("trusdxd doesn't seem to be running: %w", err)
+	}
+	defer conn.Close()
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	if _, err := fmt.Fprintln(conn, strings.Join(args, " ")); err != nil {
+		return "", err
+	}
+
+	reader := bufio.NewReader(conn)
+	status, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	if message, isError := strings.CutPrefix(strings.TrimSpace(status), "ERR "); isError {
+		return "", errors.New(message)
+	} else if strings.TrimSpace(status) != "OK" {
+		return "", fmt.Errorf("unexpected answer %q", status)
+	}
+
+	return string(output), nil
+}
+
+func main() {
+	path := flag.String("socket", socketPath(), "the control socket of the daemon")
+	timeout := flag.Duration("timeout", time.Minute, "how long to wait for the answer, 0 for ever")
+	version := flag.Bool("version", false, "print the protocol version and exit")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *version {
+		fmt.Printf("trusdxctl, control protocol %d\n", protocolVersion)
+		return
+	}
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	output, err := request(*path, *timeout, flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Print(output)
+}
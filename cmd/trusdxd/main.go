@@ -0,0 +1,9 @@
+// trusdxd only runs the driver, without the subcommands of the combined
+// binary, for service deployments with the trusdxctl client.
+package main
+
+import "github.com/leshniak/trusdx-go/internal/driver"
+
+func main() {
+	driver.RunDaemon()
+}
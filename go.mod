@@ -2,11 +2,15 @@ module github.com/leshniak/trusdx-go
 
 go 1.20
 
-require github.com/gordonklaus/portaudio v0.0.0-20230709114228-aafa478834f5
-
 require (
-	github.com/pkg/term v1.1.0 // indirect
-	github.com/sirupsen/logrus v1.9.3 // indirect
-	github.com/tarm/serial v0.0.0-20180830185346-98f6abe2eb07 // indirect
-	golang.org/x/sys v0.11.0 // indirect
+	github.com/godbus/dbus/v5 v5.1.0
+	github.com/gordonklaus/portaudio v0.0.0-20230709114228-aafa478834f5
+	github.com/pkg/term v1.1.0
+	github.com/sirupsen/logrus v1.9.3
+	github.com/yuin/gopher-lua v1.1.1
+	go.bug.st/serial v1.6.4
+	golang.org/x/sys v0.19.0
+	layeh.com/gopus v0.0.0-20210501142526-1ee02d434e32
 )
+
+require github.com/creack/goselect v0.1.2 // indirect
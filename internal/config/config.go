@@ -0,0 +1,79 @@
+// Package config finds and reads the config file and the control socket,
+// shared by the driver and the trusdxctl client, which must look for them
+// in the same places.
+package config
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FilePath is TRUSDX_CONFIG if set, otherwise the config file in the
+// user's config directory, e.g. ~/.config/trusdx-go/config.
+func FilePath() string {
+	if path, ok := os.LookupEnv("TRUSDX_CONFIG"); ok {
+		return path
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "trusdx-go", "config")
+}
+
+// Load reads KEY=VALUE lines into the environment, variables which are
+// already set take precedence.
+func Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, value, ok := parseLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		if _, isSet := os.LookupEnv(name); !isSet {
+			os.Setenv(name, value)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseLine splits a KEY=VALUE line, skipping blank lines and comments.
+// The value may be quoted with double or single quotes.
+func parseLine(line string) (name string, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+
+	name, value, ok = strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+
+	return strings.TrimSpace(name), value, true
+}
+
+// ControlSocketPath is CONTROL_SOCKET, otherwise trusdx-go.sock in the
+// temporary directory. Load the config file first for its value to count.
+func ControlSocketPath() string {
+	if path, ok := os.LookupEnv("CONTROL_SOCKET"); ok {
+		return path
+	}
+
+	return filepath.Join(os.TempDir(), "trusdx-go.sock")
+}
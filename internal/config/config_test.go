@@ -0,0 +1,31 @@
+package config
+
+import "testing"
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantName  string
+		wantValue string
+		wantOk    bool
+	}{
+		{line: "SERIAL_PORT=/dev/ttyUSB0", wantName: "SERIAL_PORT", wantValue: "/dev/ttyUSB0", wantOk: true},
+		{line: "  LOG_LEVEL = debug  ", wantName: "LOG_LEVEL", wantValue: "debug", wantOk: true},
+		{line: `CONTROL_SOCKET="/run/trusdx/control.sock"`, wantName: "CONTROL_SOCKET", wantValue: "/run/trusdx/control.sock", wantOk: true},
+		{line: `CONTROL_SOCKET='/run/trusdx/control.sock'`, wantName: "CONTROL_SOCKET", wantValue: "/run/trusdx/control.sock", wantOk: true},
+		{line: `TX_AUDIO_TOKEN="it's"`, wantName: "TX_AUDIO_TOKEN", wantValue: "it's", wantOk: true},
+		{line: `TX_AUDIO_TOKEN="unbalanced'`, wantName: "TX_AUDIO_TOKEN", wantValue: `"unbalanced'`, wantOk: true},
+		{line: "MACROS=", wantName: "MACROS", wantValue: "", wantOk: true},
+		{line: "HOOKS=a=b", wantName: "HOOKS", wantValue: "a=b", wantOk: true},
+		{line: "# SERIAL_PORT=/dev/ttyUSB0", wantOk: false},
+		{line: "   ", wantOk: false},
+		{line: "SERIAL_PORT", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		name, value, ok := parseLine(tt.line)
+		if name != tt.wantName || value != tt.wantValue || ok != tt.wantOk {
+			t.Errorf("parseLine(%q) = %q, %q, %v, want %q, %q, %v", tt.line, name, value, ok, tt.wantName, tt.wantValue, tt.wantOk)
+		}
+	}
+}
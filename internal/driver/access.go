@@ -0,0 +1,169 @@
+package driver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AccessList decides which hosts a network service takes, the denied
+// networks win over the allowed ones and an empty allowlist allows
+// everyone. A nil AccessList allows everyone. It goes by the source
+// address, which is easily forged for UDP, so it only narrows down who can
+// reach a service, the ones keying the rig need a token as well.
+type AccessList struct {
+	service string
+	allow   []*net.IPNet
+	deny    []*net.IPNet
+}
+
+// parseNetworks takes a comma separated list of addresses and networks,
+// e.g. 192.168.1.0/24,::1.
+func parseNetworks(spec string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", item)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// newAccessListFromEnv reads <SERVICE>_ALLOW_FROM and <SERVICE>_DENY_FROM,
+// falling back to ALLOW_FROM and DENY_FROM for all the services. It
+// returns nil when none are set.
+func newAccessListFromEnv(service string) *AccessList {
+	lookup := func(name string) string {
+		if spec, ok := os.LookupEnv(service + "_" + name); ok {
+			return spec
+		}
+		return os.Getenv(name)
+	}
+
+	al := &AccessList{service: strings.ToLower(service)}
+	var err error
+	if al.allow, err = parseNetworks(lookup("ALLOW_FROM")); err != nil {
+		log.Fatalf("%s_ALLOW_FROM: %s\n", service, err)
+	}
+	if al.deny, err = parseNetworks(lookup("DENY_FROM")); err != nil {
+		log.Fatalf("%s_DENY_FROM: %s\n", service, err)
+	}
+	if len(al.allow) == 0 && len(al.deny) == 0 {
+		return nil
+	}
+
+	return al
+}
+
+func (al *AccessList) allowsIP(ip net.IP) bool {
+	if al == nil {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range al.deny {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	if len(al.allow) == 0 {
+		return true
+	}
+	for _, network := range al.allow {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allows tells whether the remote address may use the service, the
+// denied ones are logged.
+func (al *AccessList) Allows(addr net.Addr) bool {
+	if al == nil {
+		return true
+	}
+
+	var ip net.IP
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.UDPAddr:
+		ip = addr.IP
+	}
+	if al.allowsIP(ip) {
+		return true
+	}
+	log.Debugf("[%s]: %s denied\n", al.service, addr)
+
+	return false
+}
+
+// Listener drops the connections of the denied hosts as they come.
+func (al *AccessList) Listener(listener net.Listener) net.Listener {
+	if al == nil {
+		return listener
+	}
+
+	return &accessListener{listener, al}
+}
+
+type accessListener struct {
+	net.Listener
+	list *AccessList
+}
+
+func (al *accessListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := al.Listener.Accept()
+		if err != nil || al.list.Allows(conn.RemoteAddr()) {
+			return conn, err
+		}
+		log.Warnf("Connection from %s to %s denied\n", conn.RemoteAddr(), al.list.service)
+		conn.Close()
+	}
+}
+
+// Handler answers 403 to the denied hosts, for endpoints with a stricter
+// list than the rest of the HTTP server.
+func (al *AccessList) Handler(handler http.HandlerFunc) http.HandlerFunc {
+	if al == nil {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+		if err != nil || !al.Allows(addr) {
+			log.Warnf("Request from %s to %s denied\n", r.RemoteAddr, al.service)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
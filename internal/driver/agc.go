@@ -0,0 +1,57 @@
+package driver
+
+import (
+	"math"
+	"time"
+)
+
+// Agc evens out the level of the received audio: the gain follows the peaks
+// quickly down and slowly back up, up to a maximum for quiet bands.
+type Agc struct {
+	target  float64
+	maxGain float64
+	attack  float64
+	decay   float64
+	level   float64
+}
+
+func NewAgc(target, maxGain float64, attack, decay time.Duration, sampleRate float64) *Agc {
+	return &Agc{
+		target:  target,
+		maxGain: maxGain,
+		attack:  math.Exp(-1 / (attack.Seconds() * sampleRate)),
+		decay:   math.Exp(-1 / (decay.Seconds() * sampleRate)),
+		level:   target,
+	}
+}
+
+// newAgcFromEnv returns nil unless RX_AGC is set.
+func newAgcFromEnv() *Agc {
+	if envInt("RX_AGC", 0) == 0 {
+		return nil
+	}
+
+	return NewAgc(
+		math.Pow(10, float64(envInt("RX_AGC_TARGET", -12))/20),
+		math.Pow(10, float64(envInt("RX_AGC_MAX_GAIN", 30))/20),
+		10*time.Millisecond,
+		envDuration("RX_AGC_DECAY", 2*time.Second),
+		7820,
+	)
+}
+
+func (a *Agc) Process(sample float64) float64 {
+	peak := math.Abs(sample)
+	if peak > a.level {
+		a.level = peak + (a.level-peak)*a.attack
+	} else {
+		a.level = peak + (a.level-peak)*a.decay
+	}
+
+	gain := a.maxGain
+	if a.level > 0 && a.target/a.level < gain {
+		gain = a.target / a.level
+	}
+
+	return sample * gain
+}
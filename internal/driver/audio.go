@@ -0,0 +1,263 @@
+//go:build !noportaudio
+
+package driver
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gordonklaus/portaudio"
+	log "github.com/sirupsen/logrus"
+)
+
+// getAudioFromRig queues the audio from the rig for the sound card, the
+// writes to the ring wait for the callback to play what's there.
+func getAudioFromRig(ring *RingBuffer[uint8], rcvdAudio chan []byte, framesPerBuffer int, stats *Stats, jitter *JitterEstimator) {
+	fill := newSilenceFillFromEnv()
+	buf := make([]uint8, framesPerBuffer)
+
+	// after a gap, wait until enough chunks are queued to ride out the jitter
+	isBuffering := true
+	// the chunks from the rig don't match the buffer of the sound card
+	var pending []byte
+	for isRunning() {
+		target := jitter.Target()
+		if isBuffering && len(rcvdAudio) >= target {
+			isBuffering = false
+		}
+		// too much queued is just latency
+		if !isBuffering && len(rcvdAudio) > 2*target+1 {
+			<-rcvdAudio
+		}
+
+		if isBuffering {
+			fill.Fill(buf)
+		}
+		for filled := 0; !isBuffering && filled < len(buf); {
+			if len(pending) == 0 {
+				select {
+				case pending = <-rcvdAudio:
+				default:
+					fill.Played(buf[:filled])
+					fill.Fill(buf[filled:])
+					stats.RxUnderruns.Add(1)
+					isBuffering = true
+					continue
+				}
+			}
+			n := copy(buf[filled:], pending)
+			pending = pending[n:]
+			filled += n
+		}
+		if !isBuffering {
+			fill.Played(buf)
+		}
+
+		if !ring.Write(buf) {
+			return
+		}
+	}
+}
+
+// playFromRing is the callback of the output stream, what hasn't arrived
+// in time is silence.
+func playFromRing(ring *RingBuffer[uint8], stats *Stats) func([]uint8) {
+	return func(out []uint8) {
+		n := ring.TryRead(out)
+		if n == len(out) {
+			return
+		}
+		for i := n; i < len(out); i++ {
+			out[i] = 128
+		}
+		stats.RxUnderruns.Add(1)
+	}
+}
+
+// pushAudioToRig queues the audio from the sound card, process turns a
+// buffer of it into samples for the rig.
+func pushAudioToRig[T any](ring *RingBuffer[T], framesPerBuffer int, sndAudio chan []byte, process func([]T) []byte, events *EventBus) {
+	buf := make([]T, framesPerBuffer)
+	for isRunning() {
+		if !ring.Read(buf) {
+			return
+		}
+		if ring.Overflowed.Swap(false) {
+			events.Publish(ChunkDropped{"tx"})
+		}
+
+		select {
+		case sndAudio <- process(buf):
+		default:
+			events.Publish(ChunkDropped{"tx"})
+		}
+	}
+}
+
+// startAudio bridges the audio of the rig to the sound cards, along the
+// routes configured, the returned function stops it.
+func startAudio(ss *SerialStream) (func(), error) {
+	framesPerBuffer := envSize("FRAMES_PER_BUFFER", dataChunkLength)
+	format := audioFormatFromEnv()
+
+	portaudio.Initialize()
+	paHost, err := audioHostFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	routes, err := audioRoutesFromEnv(paHost)
+	if err != nil {
+		return nil, err
+	}
+
+	var stops []func()
+	stopAll := func() {
+		for _, stop := range stops {
+			stop()
+		}
+		portaudio.Terminate()
+	}
+	rxRoutes := 0
+	for _, route := range routes {
+		var stop func()
+		switch {
+		case route.path == routeRx && rxRoutes == 0:
+			// the first one gets the audio first hand, and counts the underruns
+			stop, err = startRxOutput(ss, route, format, framesPerBuffer, ss.AudioOutBuf, &ss.Stats)
+			rxRoutes++
+		case route.path == routeRx:
+			stop, err = startRxCopy(ss, fmt.Sprintf("rx%d", rxRoutes+1), route, format, framesPerBuffer)
+			rxRoutes++
+		case route.path == routeMonitor:
+			stop, err = startMonitor(ss, route, format, framesPerBuffer)
+		case route.path == routeTx:
+			// the audio to transmit may come from the network instead
+			if _, ok := os.LookupEnv("TX_AUDIO_LISTEN"); ok {
+				continue
+			}
+			stop, err = startTxInput(ss, route, format, framesPerBuffer)
+		}
+		if err != nil {
+			stopAll()
+			return nil, fmt.Errorf("%s: %w", route, err)
+		}
+		log.Infof("Audio %s\n", route)
+		stops = append(stops, stop)
+	}
+	if rxRoutes == 0 {
+		// nothing plays the audio of the rig at first hand
+		go func() {
+			for isRunning() {
+				<-ss.AudioOutBuf
+			}
+		}()
+	}
+
+	return stopAll, nil
+}
+
+// openOutput opens an output stream playing what's written to the ring.
+func openOutput(route audioRoute, params portaudio.StreamParameters, rate int, format string, framesPerBuffer int, stats *Stats) (*portaudio.Stream, *RingBuffer[uint8], error) {
+	params.Output.Channels = route.channels.count
+	params.SampleRate = float64(rate)
+	params.FramesPerBuffer = framesPerBuffer
+	// a couple of buffers, the callback plays one while the next is queued
+	ring := NewRingBuffer[uint8](2 * framesPerBuffer)
+	stream, err := portaudio.OpenStream(params, playCallback(ring, stats, format, route.channels))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stream, ring, nil
+}
+
+// startRxOutput plays the audio from the source on the route.
+func startRxOutput(ss *SerialStream, route audioRoute, format string, framesPerBuffer int, source chan []byte, stats *Stats) (func(), error) {
+	params, err := audioParameters(nil, route.device)
+	if err != nil {
+		return nil, err
+	}
+	stream, ring, err := openOutput(route, params, ss.RxRate(), format, framesPerBuffer, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	go getAudioFromRig(ring, source, framesPerBuffer, stats, ss.RxJitter)
+	stream.Start()
+
+	return func() {
+		stream.Close()
+		ring.Close()
+	}, nil
+}
+
+// startRxCopy plays the audio of the rig on another route as well.
+func startRxCopy(ss *SerialStream, name string, route audioRoute, format string, framesPerBuffer int) (func(), error) {
+	sink := ss.RxAudio.Add(name, cap(ss.AudioOutBuf))
+	// the underruns of the copies don't count
+	stop, err := startRxOutput(ss, route, format, framesPerBuffer, sink.C, new(Stats))
+	if err != nil {
+		ss.RxAudio.Remove(sink)
+		return nil, err
+	}
+
+	return func() {
+		ss.RxAudio.Remove(sink)
+		stop()
+	}, nil
+}
+
+// startTxInput captures the audio to transmit from the route.
+func startTxInput(ss *SerialStream, route audioRoute, format string, framesPerBuffer int) (func(), error) {
+	params, err := audioParameters(route.device, nil)
+	if err != nil {
+		return nil, err
+	}
+	params.Input.Channels = route.channels.count
+	params.SampleRate = float64(ss.TxRate())
+	params.FramesPerBuffer = framesPerBuffer
+
+	// floats go through the TX chain, integers in 16 bits if there's any processing
+	var stream *portaudio.Stream
+	var closeRing func()
+	chain := ss.txChain
+	if format == audioFormatFloat32 {
+		ring := NewRingBuffer[float32](4 * framesPerBuffer)
+		stream, err = portaudio.OpenStream(params, captureToRing(ring, route.channels))
+		go pushAudioToRig(ring, framesPerBuffer, ss.AudioInBuf, chain.ProcessFloat32, ss.Events)
+		closeRing = ring.Close
+	} else if chain.IsBypassed() {
+		ring := NewRingBuffer[uint8](4 * framesPerBuffer)
+		stream, err = portaudio.OpenStream(params, captureToRing(ring, route.channels))
+		go pushAudioToRig(ring, framesPerBuffer, ss.AudioInBuf, func(samples []uint8) []byte {
+			return append([]byte(nil), samples...)
+		}, ss.Events)
+		closeRing = ring.Close
+	} else {
+		ring := NewRingBuffer[int16](4 * framesPerBuffer)
+		stream, err = portaudio.OpenStream(params, captureToRing(ring, route.channels))
+		go pushAudioToRig(ring, framesPerBuffer, ss.AudioInBuf, chain.Process, ss.Events)
+		closeRing = ring.Close
+	}
+	if err != nil {
+		closeRing()
+		return nil, err
+	}
+
+	// nothing to capture while no client is attached
+	ss.Events.Subscribe(func(event Event) {
+		if e, ok := event.(IdleChanged); ok {
+			if e.Idle {
+				stream.Stop()
+			} else {
+				stream.Start()
+			}
+		}
+	})
+	stream.Start()
+
+	return func() {
+		stream.Close()
+		closeRing()
+	}, nil
+}
@@ -0,0 +1,108 @@
+//go:build !noportaudio
+
+package driver
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// 8 bits to the sound card, 8 or 16 bits from it
+	audioFormatInt = "int"
+	// floats from -1 to 1 both ways
+	audioFormatFloat32 = "float32"
+)
+
+func audioFormatFromEnv() string {
+	format, ok := os.LookupEnv("AUDIO_FORMAT")
+	if !ok {
+		return audioFormatInt
+	}
+
+	switch format {
+	case audioFormatInt, audioFormatFloat32:
+	default:
+		log.Warnf("Invalid AUDIO_FORMAT value %q, using %s\n", format, audioFormatInt)
+		format = audioFormatInt
+	}
+
+	return format
+}
+
+// audioSample is what the sound card streams are made of.
+type audioSample interface {
+	~uint8 | ~int16 | ~float32
+}
+
+// playCallback is the callback of an output stream in the format, the mono
+// audio of the rig goes to the channels used, the others are silent.
+func playCallback(ring *RingBuffer[uint8], stats *Stats, format string, channels audioChannels) interface{} {
+	play := playFromRing(ring, stats)
+	var buf []uint8
+	mono := func(frames int) []uint8 {
+		if len(buf) < frames {
+			buf = make([]uint8, frames)
+		}
+		play(buf[:frames])
+		return buf[:frames]
+	}
+
+	if format == audioFormatFloat32 {
+		return func(out []float32) {
+			spread(out, mono(len(out)/channels.count), channels, func(sample uint8) float32 {
+				return (float32(sample) - 128) / 128
+			})
+		}
+	}
+	if channels.count == 1 {
+		return play
+	}
+	return func(out []uint8) {
+		spread(out, mono(len(out)/channels.count), channels, func(sample uint8) uint8 {
+			return sample
+		})
+	}
+}
+
+// spread copies every mono sample to the channels used of a frame.
+func spread[T audioSample](out []T, mono []uint8, channels audioChannels, convert func(uint8) T) {
+	silence := convert(128)
+	for i := range out {
+		out[i] = silence
+	}
+	for i, sample := range mono {
+		value := convert(sample)
+		for _, channel := range channels.used {
+			out[i*channels.count+channel] = value
+		}
+	}
+}
+
+// captureToRing is the callback of the input stream, it mixes the channels
+// used down to mono and drops what the driver doesn't take in time.
+func captureToRing[T audioSample](ring *RingBuffer[T], channels audioChannels) func([]T) {
+	if channels.count == 1 {
+		return func(in []T) {
+			ring.TryWrite(in)
+		}
+	}
+
+	var mono []T
+	return func(in []T) {
+		frames := len(in) / channels.count
+		if cap(mono) < frames {
+			mono = make([]T, frames)
+		}
+		mono = mono[:frames]
+		for i := range mono {
+			sum := 0.0
+			for _, channel := range channels.used {
+				sum += float64(in[i*channels.count+channel])
+			}
+			mono[i] = T(sum / float64(len(channels.used)))
+		}
+		ring.TryWrite(mono)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build !noportaudio
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// audioHostFromEnv picks the host API named by AUDIO_HOST_API, e.g. JACK,
+// ALSA, Core Audio or WASAPI, or the default one.
+func audioHostFromEnv() (*portaudio.HostApiInfo, error) {
+	name, ok := os.LookupEnv("AUDIO_HOST_API")
+	if !ok || name == "" {
+		return portaudio.DefaultHostApi()
+	}
+
+	hosts, err := portaudio.HostApis()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, host := range hosts {
+		if strings.Contains(strings.ToLower(host.Name), strings.ToLower(name)) {
+			return host, nil
+		}
+		names = append(names, host.Name)
+	}
+
+	return nil, fmt.Errorf("no host API matching %q, there are: %s", name, strings.Join(names, ", "))
+}
+
+// audioParameters are the parameters of a stream with the latency set by
+// AUDIO_LATENCY: low (the default), high, or a duration, e.g. 40ms.
+func audioParameters(in, out *portaudio.DeviceInfo) (portaudio.StreamParameters, error) {
+	text, ok := os.LookupEnv("AUDIO_LATENCY")
+	switch {
+	case !ok || text == "low":
+		return portaudio.LowLatencyParameters(in, out), nil
+	case text == "high":
+		return portaudio.HighLatencyParameters(in, out), nil
+	}
+
+	latency, err := time.ParseDuration(text)
+	if err != nil || latency <= 0 {
+		return portaudio.StreamParameters{}, fmt.Errorf("invalid AUDIO_LATENCY value %q", text)
+	}
+	params := portaudio.LowLatencyParameters(in, out)
+	params.Input.Latency = latency
+	params.Output.Latency = latency
+
+	return params, nil
+}
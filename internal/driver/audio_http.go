@@ -0,0 +1,103 @@
+package driver
+
+import (
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// a WAV file of unknown length, players just keep reading
+const wavStreamLength = 0x7fffffff - 36
+
+// serveRxWebSocket sends the received audio as binary WebSocket messages,
+// unsigned 8 bits at the RX sample rate, as it comes from the rig.
+func serveRxWebSocket(ss *SerialStream) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer ws.Close()
+
+		sink := ss.RxAudio.Add("ws", cap(ss.AudioOutBuf))
+		defer ss.RxAudio.Remove(sink)
+		log.Infof("RX audio listener %s connected\n", r.RemoteAddr)
+		defer log.Infof("RX audio listener %s disconnected\n", r.RemoteAddr)
+
+		// the client only closes, or pings
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, err := ws.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		for {
+			select {
+			case chunk := <-sink.C:
+				if err := ws.WriteMessage(wsBinary, chunk); err != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}
+}
+
+// serveRxAudio streams the received audio as an endless WAV file, e.g. for
+// a browser or VLC.
+func serveRxAudio(ss *SerialStream) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		sink := ss.RxAudio.Add("http", cap(ss.AudioOutBuf))
+		defer ss.RxAudio.Remove(sink)
+		log.Infof("RX audio listener %s connected\n", r.RemoteAddr)
+		defer log.Infof("RX audio listener %s disconnected\n", r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "audio/wav")
+		w.Header().Set("Cache-Control", "no-cache")
+		if _, err := w.Write(wavHeader(wavStreamLength, ss.RxRate())); err != nil {
+			return
+		}
+
+		// players stall without audio, gaps are filled with silence
+		const gap = 100 * time.Millisecond
+		silence := make([]byte, int(time.Duration(ss.RxRate())*gap/time.Second))
+		for i := range silence {
+			silence[i] = 128
+		}
+		timer := time.NewTimer(gap)
+		defer timer.Stop()
+
+		for {
+			var chunk []byte
+			select {
+			case chunk = <-sink.C:
+				if !timer.Stop() {
+					<-timer.C
+				}
+			case <-timer.C:
+				chunk = silence
+			case <-r.Context().Done():
+				return
+			}
+			timer.Reset(gap)
+
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build noportaudio
+
+package driver
+
+import "errors"
+
+var errNoPortAudio = errors.New("built without PortAudio, the audio is only available over the network")
+
+func startAudio(ss *SerialStream) (func(), error) {
+	return nil, errNoPortAudio
+}
+
+func checkAudio() (string, error) {
+	return "", &doctorError{errNoPortAudio.Error(), "Use DRIVER_MODE=server, or build without the noportaudio tag."}
+}
@@ -0,0 +1,191 @@
+//go:build !noportaudio
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+const (
+	// the audio of the rig, for the digimode programs
+	routeRx = "rx"
+	// the audio to transmit
+	routeTx = "tx"
+	// the audio of the rig for listening, muted while transmitting
+	routeMonitor = "monitor"
+)
+
+// audioChannels are the channels of a stream which carry the audio.
+type audioChannels struct {
+	count int   // of the stream
+	used  []int // from 0
+}
+
+func allChannels(count int) audioChannels {
+	channels := audioChannels{count: count}
+	for channel := 0; channel < count; channel++ {
+		channels.used = append(channels.used, channel)
+	}
+
+	return channels
+}
+
+// audioRoute connects a path of the audio to the channels of a device.
+type audioRoute struct {
+	path     string
+	device   *portaudio.DeviceInfo
+	channels audioChannels
+}
+
+func (r audioRoute) String() string {
+	used := make([]string, len(r.channels.used))
+	for i, channel := range r.channels.used {
+		used[i] = strconv.Itoa(channel + 1)
+	}
+
+	return fmt.Sprintf("%s: %s, channel %s of %d", r.path, r.device.Name, strings.Join(used, "+"), r.channels.count)
+}
+
+// audioRoutesFromEnv reads AUDIO_ROUTES, e.g.
+// "rx=Loopback@1/2; tx=Loopback@1/2; monitor=Headphones@1+2", a device by a
+// part of its name or its index, followed by the channels carrying the
+// audio, from 1, and how many the stream has. Without it, the rig is on
+// the second device of the host API, and MONITOR_DEVICE is the monitor.
+func audioRoutesFromEnv(host *portaudio.HostApiInfo) ([]audioRoute, error) {
+	text, ok := os.LookupEnv("AUDIO_ROUTES")
+	if !ok {
+		return defaultAudioRoutes(host)
+	}
+
+	var routes []audioRoute
+	txRoutes := 0
+	for _, field := range strings.Split(text, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		route, err := parseAudioRoute(field)
+		if err != nil {
+			return nil, fmt.Errorf("AUDIO_ROUTES: %w", err)
+		}
+		if route.path == routeTx {
+			txRoutes++
+		}
+		routes = append(routes, route)
+	}
+	if txRoutes > 1 {
+		return nil, fmt.Errorf("AUDIO_ROUTES: only one tx route")
+	}
+
+	return routes, nil
+}
+
+func defaultAudioRoutes(host *portaudio.HostApiInfo) ([]audioRoute, error) {
+	if len(host.Devices) < 2 {
+		return nil, fmt.Errorf("%s has no second device for the rig", host.Name)
+	}
+	channels := allChannels(envSize("AUDIO_CHANNELS", 1))
+	routes := []audioRoute{
+		{routeRx, host.Devices[1], channels},
+		{routeTx, host.Devices[1], channels},
+	}
+
+	if name, ok := os.LookupEnv("MONITOR_DEVICE"); ok {
+		device, err := findDevice(name, true)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, audioRoute{routeMonitor, device, allChannels(1)})
+	}
+
+	return routes, nil
+}
+
+// parseAudioRoute takes "<path>=<device>[@<channel>[+<channel>...][/<count>]]".
+func parseAudioRoute(text string) (audioRoute, error) {
+	path, spec, ok := strings.Cut(text, "=")
+	path = strings.TrimSpace(path)
+	if !ok || (path != routeRx && path != routeTx && path != routeMonitor) {
+		return audioRoute{}, fmt.Errorf("invalid route %q, expected rx, tx or monitor=<device>", text)
+	}
+
+	name, channelSpec, hasChannels := strings.Cut(spec, "@")
+	channels := allChannels(1)
+	if hasChannels {
+		var err error
+		if channels, err = parseAudioChannels(channelSpec); err != nil {
+			return audioRoute{}, fmt.Errorf("%s: %w", text, err)
+		}
+	}
+
+	device, err := findDevice(strings.TrimSpace(name), path != routeTx)
+	if err != nil {
+		return audioRoute{}, err
+	}
+
+	return audioRoute{path, device, channels}, nil
+}
+
+func parseAudioChannels(text string) (audioChannels, error) {
+	list, countText, hasCount := strings.Cut(text, "/")
+
+	var channels audioChannels
+	for _, field := range strings.Split(list, "+") {
+		channel, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || channel < 1 {
+			return audioChannels{}, fmt.Errorf("invalid channel %q", field)
+		}
+		channels.used = append(channels.used, channel-1)
+		if channel > channels.count {
+			channels.count = channel
+		}
+	}
+	if hasCount {
+		count, err := strconv.Atoi(strings.TrimSpace(countText))
+		if err != nil || count < channels.count {
+			return audioChannels{}, fmt.Errorf("invalid channel count %q", countText)
+		}
+		channels.count = count
+	}
+
+	return channels, nil
+}
+
+// findDevice looks up a sound card output or input by its index or a part
+// of its name.
+func findDevice(name string, isOutput bool) (*portaudio.DeviceInfo, error) {
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil, err
+	}
+	kind := "input"
+	hasChannels := func(device *portaudio.DeviceInfo) bool {
+		return device.MaxInputChannels > 0
+	}
+	if isOutput {
+		kind = "output"
+		hasChannels = func(device *portaudio.DeviceInfo) bool {
+			return device.MaxOutputChannels > 0
+		}
+	}
+
+	if index, err := strconv.Atoi(name); err == nil {
+		if index < 0 || index >= len(devices) || !hasChannels(devices[index]) {
+			return nil, fmt.Errorf("no %s device %d", kind, index)
+		}
+		return devices[index], nil
+	}
+
+	for _, device := range devices {
+		if hasChannels(device) && strings.Contains(strings.ToLower(device.Name), strings.ToLower(name)) {
+			return device, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no %s device matching %q", kind, name)
+}
@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// AudioSink is one consumer of the audio, with its own queue, so a slow one
+// doesn't hold up the others.
+type AudioSink struct {
+	Name    string
+	C       chan []byte
+	Dropped atomic.Uint64
+}
+
+// AudioTee hands the same audio to all its sinks.
+type AudioTee struct {
+	mu    sync.Mutex
+	sinks []*AudioSink
+}
+
+func NewAudioTee() *AudioTee {
+	return new(AudioTee)
+}
+
+// Add starts a sink queueing up to size chunks.
+func (t *AudioTee) Add(name string, size int) *AudioSink {
+	sink := &AudioSink{Name: name, C: make(chan []byte, size)}
+
+	t.mu.Lock()
+	t.sinks = append(t.sinks, sink)
+	t.mu.Unlock()
+
+	return sink
+}
+
+func (t *AudioTee) Remove(sink *AudioSink) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, s := range t.sinks {
+		if s == sink {
+			t.sinks = append(t.sinks[:i:i], t.sinks[i+1:]...)
+			return
+		}
+	}
+}
+
+// Write queues the chunk for every sink, the ones which are full drop it.
+func (t *AudioTee) Write(samples []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, sink := range t.sinks {
+		select {
+		case sink.C <- samples:
+		default:
+			sink.Dropped.Add(1)
+		}
+	}
+}
+
+func (t *AudioTee) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fields := make([]string, 0, len(t.sinks))
+	for _, sink := range t.sinks {
+		fields = append(fields, fmt.Sprintf("sink_%s=%d/%d", sink.Name, len(sink.C), sink.Dropped.Load()))
+	}
+
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,80 @@
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// handleAutoInformation takes care of the AI command locally. The firmware
+// doesn't report state changes on its own, so the driver polls the rig
+// instead and notifies the client of the virtual port when it has asked
+// for it.
+func (ss *SerialStream) handleAutoInformation(client *CatClient, cmd string) bool {
+	if len(cmd) < 2 || cmd[:2] != "AI" {
+		return false
+	}
+
+	if cmd == "AI" {
+		mode := ss.aiMode.Load()
+		if client != nil {
+			mode = 0
+		}
+		ss.reply(client, []byte(fmt.Sprintf("AI%d;", mode)))
+		return true
+	}
+
+	// the notifications only go to the virtual port
+	if client == nil && len(cmd) == 3 && cmd[2] >= '0' && cmd[2] <= '2' {
+		ss.aiMode.Store(int32(cmd[2] - '0'))
+		log.Debugf("[AI Mode]: %c\n", cmd[2])
+	}
+
+	return true
+}
+
+func (ss *SerialStream) notifyAutoInformation(event Event) {
+	if ss.aiMode.Load() == 0 {
+		return
+	}
+
+	var cmd string
+	switch event.(type) {
+	case FrequencyChanged:
+		cmd = "FA"
+	case ModeChanged:
+		cmd = "MD"
+	case PttChanged:
+		// there's no PTT report in the protocol, IF carries it
+		cmd = "IF"
+	default:
+		return
+	}
+
+	if reply, ok := ss.State.Reply(cmd); ok {
+		ss.RepliesBuf <- reply
+	}
+}
+
+func (ss *SerialStream) pollAutoInformation(stop chan struct{}) {
+	if ss.aiPollInterval <= 0 {
+		return
+	}
+
+	for ss.IsRunning() {
+		select {
+		case <-stop:
+			return
+		case <-time.After(ss.aiPollInterval):
+		}
+
+		if ss.aiMode.Load() == 0 || ss.link.IsTransmitting() || ss.isIdle.Load() {
+			continue
+		}
+
+		if _, err := ss.Query("IF"); err != nil {
+			log.Debugln(err)
+		}
+	}
+}
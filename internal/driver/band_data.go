@@ -0,0 +1,110 @@
+package driver
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"go.bug.st/serial"
+)
+
+// BandData tells antenna switches and filter boxes the band of the rig,
+// as BCD on GPIO pins, the A (lowest) bit first, and as "<band>
+// <frequency>\n" lines on a serial port or to a UDP address. Outside of the
+// bands, the BCD is 0 and the band "-".
+type BandData struct {
+	mu      sync.Mutex
+	band    string
+	isSent  bool
+	pins    []*GpioOutput
+	writers []io.WriteCloser
+}
+
+// startBandDataFromEnv sets up BAND_DATA_GPIO, BAND_DATA_SERIAL and
+// BAND_DATA_UDP, it returns nil when there are none.
+func startBandDataFromEnv(ss *SerialStream) *BandData {
+	bd := new(BandData)
+
+	if spec := strings.TrimSpace(os.Getenv("BAND_DATA_GPIO")); spec != "" {
+		for _, pin := range strings.Split(spec, ",") {
+			output, err := NewGpioOutput(strings.TrimSpace(pin))
+			if err != nil {
+				log.Fatalln(err)
+			}
+			bd.pins = append(bd.pins, output)
+		}
+	}
+	if name := strings.TrimSpace(os.Getenv("BAND_DATA_SERIAL")); name != "" {
+		port, err := serial.Open(name, &serial.Mode{BaudRate: envInt("BAND_DATA_BAUD", 9600)})
+		if err != nil {
+			log.Fatalf("%s: %s\n", name, err)
+		}
+		bd.writers = append(bd.writers, port)
+	}
+	if address := strings.TrimSpace(os.Getenv("BAND_DATA_UDP")); address != "" {
+		conn, err := net.Dial("udp", address)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		bd.writers = append(bd.writers, conn)
+	}
+
+	if len(bd.pins) == 0 && len(bd.writers) == 0 {
+		return nil
+	}
+	ss.Events.Subscribe(bd.onEvent)
+
+	return bd
+}
+
+func (bd *BandData) onEvent(event Event) {
+	e, ok := event.(FrequencyChanged)
+	if !ok {
+		return
+	}
+
+	b, _ := findBand(e.Frequency)
+	bd.mu.Lock()
+	defer bd.mu.Unlock()
+	if b.name == bd.band && bd.isSent {
+		return
+	}
+	bd.band = b.name
+	bd.isSent = true
+
+	for bit, pin := range bd.pins {
+		if err := pin.Set(b.bcd&(1<<bit) != 0); err != nil {
+			log.Warnln(err)
+		}
+	}
+
+	name := b.name
+	if name == "" {
+		name = "-"
+	}
+	message := fmt.Sprintf("%s %d\n", name, e.Frequency)
+	for _, writer := range bd.writers {
+		if _, err := io.WriteString(writer, message); err != nil {
+			log.Warnf("Band data: %s\n", err)
+		}
+	}
+}
+
+func (bd *BandData) Close() {
+	if bd == nil {
+		return
+	}
+
+	bd.mu.Lock()
+	defer bd.mu.Unlock()
+	for _, pin := range bd.pins {
+		pin.Close()
+	}
+	for _, writer := range bd.writers {
+		writer.Close()
+	}
+}
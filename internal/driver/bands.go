@@ -0,0 +1,71 @@
+package driver
+
+import (
+	"strings"
+)
+
+type band struct {
+	name string
+	low  int64 // Hz
+	high int64
+	home int64 // where to go when switching to the band, the FT8 frequency
+	bcd  int   // the Yaesu band data of antenna switches and filters
+}
+
+// The amateur HF bands, the widest edges of the three IARU regions.
+// 60m has no band data of its own.
+var hamBands = []band{
+	{"160m", 1800000, 2000000, 1840000, 1},
+	{"80m", 3500000, 4000000, 3573000, 2},
+	{"60m", 5351500, 5366500, 5357000, 0},
+	{"40m", 7000000, 7300000, 7074000, 3},
+	{"30m", 10100000, 10150000, 10136000, 4},
+	{"20m", 14000000, 14350000, 14074000, 5},
+	{"17m", 18068000, 18168000, 18100000, 6},
+	{"15m", 21000000, 21450000, 21074000, 7},
+	{"12m", 24890000, 24990000, 24915000, 8},
+	{"10m", 28000000, 29700000, 28074000, 9},
+}
+
+// findBand returns the band of the frequency, false when it's outside of
+// the amateur bands.
+func findBand(frequency int64) (band, bool) {
+	for _, b := range hamBands {
+		if frequency >= b.low && frequency <= b.high {
+			return b, true
+		}
+	}
+
+	return band{}, false
+}
+
+// parseBands parses a comma separated list of band names, e.g. "20m,40m".
+func parseBands(text string) map[string]bool {
+	bands := make(map[string]bool)
+	for _, name := range strings.Split(text, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			bands[name] = true
+		}
+	}
+
+	return bands
+}
+
+// nextBand is the band above or below the frequency, wrapping around.
+func nextBand(frequency int64, up bool) band {
+	for i := range hamBands {
+		j := i
+		if !up {
+			j = len(hamBands) - 1 - i
+		}
+		b := hamBands[j]
+		if up && b.low > frequency || !up && b.high < frequency {
+			return b
+		}
+	}
+
+	if up {
+		return hamBands[0]
+	}
+	return hamBands[len(hamBands)-1]
+}
@@ -0,0 +1,113 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Beacon sends a CW message, on its own at an interval or on demand.
+type Beacon struct {
+	ss        *SerialStream
+	message   string
+	interval  time.Duration
+	wpm       int
+	tone      float64
+	bands     map[string]bool
+	isRunning atomic.Bool
+}
+
+func NewBeacon(ss *SerialStream) *Beacon {
+	message, _ := os.LookupEnv("BEACON_MESSAGE")
+
+	return &Beacon{
+		ss:       ss,
+		message:  message,
+		interval: envDuration("BEACON_INTERVAL", 0),
+		wpm:      envInt("BEACON_WPM", 20),
+		tone:     float64(envInt("BEACON_TONE", 700)),
+		bands:    parseBands(os.Getenv("BEACON_BANDS")),
+	}
+}
+
+// Start sends the message at the interval, if there are both.
+func (b *Beacon) Start() {
+	if b.message == "" || b.interval <= 0 {
+		return
+	}
+
+	b.isRunning.Store(true)
+	go func() {
+		for b.isRunning.Load() {
+			time.Sleep(b.interval)
+			if !b.isRunning.Load() {
+				return
+			}
+			if err := b.waitForRx(); err != nil {
+				log.Warnf("Beacon skipped: %s\n", err)
+				continue
+			}
+			if err := b.Send(b.message); err != nil {
+				log.Warnf("Beacon skipped: %s\n", err)
+			}
+		}
+	}()
+}
+
+func (b *Beacon) Stop() {
+	b.isRunning.Store(false)
+}
+
+// waitForRx doesn't interrupt a transmission, e.g. of a digital mode
+// program, the ID goes out after it.
+func (b *Beacon) waitForRx() error {
+	deadline := time.Now().Add(b.interval)
+	for b.ss.State.Transmitting() || b.ss.isPlaying.Load() {
+		if time.Now().After(deadline) {
+			return errors.New("the rig is transmitting all the time")
+		}
+		time.Sleep(time.Second)
+	}
+
+	return nil
+}
+
+// Send keys the message and waits until it's sent.
+func (b *Beacon) Send(message string) error {
+	frequency := b.ss.State.Frequency()
+	band, ok := findBand(frequency)
+	if !ok {
+		return fmt.Errorf("%d Hz is outside of the amateur bands", frequency)
+	}
+	if len(b.bands) > 0 && !b.bands[band.name] {
+		return fmt.Errorf("%s isn't in BEACON_BANDS", band.name)
+	}
+
+	done, err := b.ss.SendMorse(message, b.wpm, b.tone)
+	if err != nil {
+		return err
+	}
+	log.Infof("Beacon: %s\n", message)
+	<-done
+
+	return nil
+}
+
+func registerBeaconCommands(cs *ControlServer, b *Beacon) {
+	cs.Handle("beacon", func(args []string) (string, error) {
+		message := b.message
+		if len(args) > 0 {
+			message = strings.Join(args, " ")
+		}
+		if message == "" {
+			return "", errUsage
+		}
+
+		return "", b.Send(message)
+	})
+}
@@ -0,0 +1,207 @@
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	benchQueries = 20
+	// a chunk at this level stands out of the silence in the loopback
+	benchMarker      = 200
+	benchMarkerEvery = 250 * time.Millisecond
+)
+
+// benchTimes keeps the minimum, the average and the maximum of durations.
+type benchTimes struct {
+	count         int
+	min, max, sum time.Duration
+}
+
+func (bt *benchTimes) add(d time.Duration) {
+	if bt.count == 0 || d < bt.min {
+		bt.min = d
+	}
+	if d > bt.max {
+		bt.max = d
+	}
+	bt.sum += d
+	bt.count++
+}
+
+func (bt *benchTimes) String() string {
+	if bt.count == 0 {
+		return "none"
+	}
+	avg := bt.sum / time.Duration(bt.count)
+
+	return fmt.Sprintf("min %s, avg %s, max %s over %d",
+		bt.min.Round(10*time.Microsecond), avg.Round(10*time.Microsecond), bt.max.Round(10*time.Microsecond), bt.count)
+}
+
+// cpuTime is the user and system time the process has used.
+func cpuTime() time.Duration {
+	var usage unix.Rusage
+	if err := unix.Getrusage(unix.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// runBench measures how the driver keeps up on this machine: the CAT round
+// trips, the audio throughput of the serial port, the latency of the audio
+// through the simulator and the CPU it takes.
+func runBench(args []string) error {
+	options := map[string]string{"port": "sim", "duration": "10s", "tx": "0"}
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if _, known := options[name]; !ok || !known {
+			return errUsage
+		}
+		options[name] = value
+	}
+	duration, err := time.ParseDuration(options["duration"])
+	if err != nil || duration <= 0 {
+		return fmt.Errorf("invalid duration %q", options["duration"])
+	}
+	tx, err := strconv.ParseBool(options["tx"])
+	if err != nil {
+		return fmt.Errorf("invalid tx %q", options["tx"])
+	}
+
+	var ss *SerialStream
+	isSimulated := options["port"] == "sim"
+	if isSimulated {
+		ss = newSerialStream("simulator", OpenSimulatedPort)
+		// never key real outputs for the simulator
+		ss.pttOutputs.Close()
+		ss.pttOutputs = nil
+		// the simulator only streams the loopback
+		tx = true
+	} else {
+		ss = NewSerialStream(options["port"])
+	}
+	// the markers are found untouched
+	ss.rxChain = new(RxChain)
+	ss.rxChain.SetGain(0)
+	ss.Start()
+	defer ss.Close()
+
+	var queries benchTimes
+	for i := 0; i < benchQueries; i++ {
+		start := time.Now()
+		if _, err := ss.Query("FA"); err != nil {
+			return err
+		}
+		queries.add(time.Since(start))
+	}
+
+	if tx {
+		ss.PushCommand(";UA2;TX;")
+	} else {
+		ss.PushCommand(";UA2;RX;")
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	stop := make(chan struct{})
+	sentMarkers := make(chan time.Time, 64)
+	var sent int
+	sentDone := make(chan struct{})
+	if tx {
+		go func() {
+			defer close(sentDone)
+			// at the pace of the sound card
+			ticker := time.NewTicker(time.Second * dataChunkLength / time.Duration(ss.TxRate()))
+			defer ticker.Stop()
+			silence := bytes.Repeat([]byte{128}, dataChunkLength)
+			marker := bytes.Repeat([]byte{benchMarker}, dataChunkLength)
+			lastMarker := time.Now()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+				}
+				chunk := silence
+				if isSimulated && time.Since(lastMarker) >= benchMarkerEvery {
+					chunk = marker
+					lastMarker = time.Now()
+					select {
+					case sentMarkers <- lastMarker:
+					default:
+					}
+				}
+				ss.AudioInBuf <- chunk
+				sent += len(chunk)
+			}
+		}()
+	} else {
+		close(sentDone)
+	}
+
+	var received int
+	var latencies benchTimes
+	last := byte(128)
+	startCpu, start := cpuTime(), time.Now()
+	timeout := time.After(duration)
+receive:
+	for {
+		select {
+		case samples := <-ss.AudioOutBuf:
+			received += len(samples)
+			for _, sample := range samples {
+				if sample == benchMarker && last != benchMarker {
+					select {
+					case sentAt := <-sentMarkers:
+						latencies.add(time.Since(sentAt))
+					default:
+					}
+				}
+				last = sample
+			}
+		case <-timeout:
+			break receive
+		}
+	}
+	close(stop)
+	<-sentDone
+	elapsed, cpu := time.Since(start), cpuTime()-startCpu
+	ss.PushCommand("RX;")
+
+	port := options["port"]
+	if isSimulated {
+		port = "simulator"
+	}
+	fmt.Printf("Port:          %s\n", port)
+	fmt.Printf("Duration:      %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("CAT queries:   %s\n", &queries)
+	switch {
+	case isSimulated:
+		// the loopback comes back at the TX rate
+		fmt.Printf("Serial RX:     %.0f samples/s, %d Hz expected\n", float64(received)/elapsed.Seconds(), ss.TxRate())
+	case tx:
+		fmt.Println("Serial RX:     not measured, the rig doesn't stream while transmitting")
+	default:
+		fmt.Printf("Serial RX:     %.0f samples/s, %d Hz expected\n", float64(received)/elapsed.Seconds(), ss.RxRate())
+	}
+	if tx {
+		fmt.Printf("Serial TX:     %.0f samples/s, %d Hz expected\n", float64(sent)/elapsed.Seconds(), ss.TxRate())
+	} else {
+		fmt.Println("Serial TX:     not measured, tx=1 keys the rig")
+	}
+	if isSimulated {
+		fmt.Printf("Audio latency: %s\n", &latencies)
+	} else {
+		fmt.Println("Audio latency: not measured, only through the simulator")
+	}
+	fmt.Printf("Dropped:       %d RX chunks, %d TX chunks\n", ss.Stats.RxOverruns.Load(), ss.Stats.TxOverruns.Load())
+	fmt.Printf("CPU:           %.1f%% of a core\n", 100*cpu.Seconds()/elapsed.Seconds())
+
+	return nil
+}
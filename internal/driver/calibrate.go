@@ -0,0 +1,177 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Calibration struct {
+	Beat       float64 // Hz
+	Error      float64 // Hz at the reference frequency
+	Ppm        float64
+	CorrectRef int64 // what to set the reference oscillator to
+}
+
+// Calibrate tunes below a reference carrier in USB, so it's heard as a tone
+// of offset Hz, and measures the tone. The difference is the error of the
+// reference oscillator of the rig, scaled down to the tuned frequency. It
+// only measures, the firmware has no CAT command for the reference
+// oscillator, which is set in the menu of the rig.
+func (ss *SerialStream) Calibrate(reference, offset int64, duration time.Duration, oscillator int64) (Calibration, error) {
+	var result Calibration
+
+	frequency, mode := ss.State.Frequency(), ss.State.Mode()
+	defer func() {
+		if frequency != 0 && mode != 0 {
+			ss.PushCommand(fmt.Sprintf("FA%011d;MD%c", frequency, mode))
+		}
+	}()
+
+	dial := reference - offset
+	ss.PushCommand(fmt.Sprintf("MD2;FA%011d", dial))
+	// let the synthesizer and the AGC settle
+	time.Sleep(time.Second)
+
+	tap, untap := ss.TapRxAudio()
+	var samples []byte
+	timeout := time.After(duration)
+collect:
+	for {
+		select {
+		case chunk := <-tap:
+			samples = append(samples, chunk...)
+		case <-timeout:
+			break collect
+		}
+	}
+	untap()
+
+	beat, ok := findTone(samples, float64(ss.RxRate()), float64(offset)-200, float64(offset)+200)
+	if !ok {
+		return result, errors.New("no tone found, is the reference audible?")
+	}
+
+	// in USB the tone is the signal minus the local oscillator, which is
+	// off by the same ratio as the reference oscillator
+	ratio := (float64(reference) - beat) / float64(dial)
+	result.Beat = beat
+	result.Ppm = (ratio - 1) * 1e6
+	result.Error = float64(reference) * (ratio - 1)
+	result.CorrectRef = int64(math.Round(float64(oscillator) * ratio))
+
+	return result, nil
+}
+
+// findTone finds the strongest frequency between low and high Hz.
+func findTone(samples []byte, sampleRate, low, high float64) (float64, bool) {
+	size := 1
+	for size*2 <= len(samples) {
+		size *= 2
+	}
+	if size < 1024 {
+		return 0, false
+	}
+
+	bins := make([]complex128, size)
+	for i := range bins {
+		window := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size-1))
+		bins[i] = complex(window*(float64(samples[i])-128), 0)
+	}
+	fft(bins)
+
+	binWidth := sampleRate / float64(size)
+	first, last := int(low/binWidth), int(high/binWidth)
+	if first < 1 || last >= size/2-1 {
+		return 0, false
+	}
+
+	peak, peakPower, total := first, 0.0, 0.0
+	for i := first; i <= last; i++ {
+		power := cmplx.Abs(bins[i])
+		total += power
+		if power > peakPower {
+			peak, peakPower = i, power
+		}
+	}
+	// a tone stands out of the noise
+	if peakPower < 10*total/float64(last-first+1) {
+		return 0, false
+	}
+
+	// parabolic interpolation between the neighbouring bins
+	a, b, c := cmplx.Abs(bins[peak-1]), peakPower, cmplx.Abs(bins[peak+1])
+	shift := 0.5 * (a - c) / (a - 2*b + c)
+
+	return (float64(peak) + shift) * binWidth, true
+}
+
+// fft is an in-place radix-2 FFT, len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for length := 2; length <= n; length <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(length)))
+		for start := 0; start < n; start += length {
+			w := complex(1, 0)
+			for k := 0; k < length/2; k++ {
+				even, odd := x[start+k], w*x[start+k+length/2]
+				x[start+k], x[start+k+length/2] = even+odd, even-odd
+				w *= step
+			}
+		}
+	}
+}
+
+func registerCalibrateCommands(cs *ControlServer, ss *SerialStream) {
+	cs.Handle("calibrate", func(args []string) (string, error) {
+		options := map[string]string{
+			"freq": "10000000", "offset": "1000", "duration": "10s", "ref": "27004000",
+		}
+		for _, arg := range args {
+			name, value, ok := strings.Cut(arg, "=")
+			if _, known := options[name]; !ok || !known {
+				return "", errUsage
+			}
+			options[name] = value
+		}
+
+		var values [3]int64
+		for i, name := range []string{"freq", "offset", "ref"} {
+			value, err := strconv.ParseInt(options[name], 10, 64)
+			if err != nil || value <= 0 {
+				return "", fmt.Errorf("invalid %s %q", name, options[name])
+			}
+			values[i] = value
+		}
+		duration, err := time.ParseDuration(options["duration"])
+		if err != nil {
+			return "", err
+		}
+
+		result, err := ss.Calibrate(values[0], values[1], duration, values[2])
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("Tone:  %.2f Hz, expected %d Hz\n"+
+			"Error: %+.2f Hz at %d Hz, %+.3f ppm\n"+
+			"Not applied, the reference oscillator can't be set over CAT.\n"+
+			"Set it in the menu of the rig to %d Hz.\n",
+			result.Beat, values[1], result.Error, values[0], result.Ppm, result.CorrectRef), nil
+	})
+}
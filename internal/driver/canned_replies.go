@@ -0,0 +1,108 @@
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Commands answered locally, without bothering the rig. The replies are
+// Go templates, with .Command (the whole command) and .Args (whatever
+// follows the matched prefix) available.
+var defaultCannedReplies = map[string]string{
+	// the reply is constant anyway, this is a workaround for unrealistic
+	// fast RTT expectations in hamlib for sequence RX;ID;
+	"ID": "ID" + defaultRigIdentity.id + ";",
+	// probes of hamlib and others the firmware doesn't answer, which would
+	// time out: powered on, a firmware version, a K type without options
+	"PS": "{{if not .Args}}PS1;{{end}}",
+	"FV": "FV1.00;",
+	"TY": "TYK00;",
+}
+
+type cannedReplyArgs struct {
+	Command string
+	Args    string
+}
+
+type CannedReplies struct {
+	replies map[string]*template.Template
+}
+
+func NewCannedReplies(identity rigIdentity) *CannedReplies {
+	cr := new(CannedReplies)
+	cr.replies = make(map[string]*template.Template)
+	for prefix, reply := range defaultCannedReplies {
+		cr.Set(prefix, reply)
+	}
+	cr.Set("ID", "ID"+identity.id+";")
+	// menu reads get 0, the settings are swallowed
+	cr.Set("EX", fmt.Sprintf("{{if eq (len .Command) %d}}{{.Command}}0;{{end}}", identity.exRead))
+
+	return cr
+}
+
+func (cr *CannedReplies) Set(prefix string, reply string) error {
+	tmpl, err := template.New(prefix).Parse(reply)
+	if err != nil {
+		return err
+	}
+	cr.replies[prefix] = tmpl
+
+	return nil
+}
+
+// Load reads a table of "<prefix> <reply>" lines, one per command. Empty
+// lines and lines starting with # are ignored. An empty reply removes a
+// command from the table.
+func (cr *CannedReplies) Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		prefix, reply, _ := strings.Cut(line, " ")
+		reply = strings.TrimSpace(reply)
+		if reply == "" {
+			delete(cr.replies, prefix)
+			continue
+		}
+		if err := cr.Set(prefix, reply); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Reply renders the reply for the longest matching prefix of cmd.
+func (cr *CannedReplies) Reply(cmd string) ([]byte, bool) {
+	var match string
+	for prefix := range cr.replies {
+		if strings.HasPrefix(cmd, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	if match == "" {
+		return nil, false
+	}
+
+	var reply bytes.Buffer
+	args := cannedReplyArgs{Command: cmd, Args: strings.TrimPrefix(cmd, match)}
+	if err := cr.replies[match].Execute(&reply, args); err != nil {
+		return nil, false
+	}
+
+	return reply.Bytes(), true
+}
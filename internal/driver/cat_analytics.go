@@ -0,0 +1,139 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// catCommandStats are the counters of one CAT command.
+type catCommandStats struct {
+	requests   uint64 // from the clients and the driver
+	local      uint64 // answered without asking the rig
+	replies    uint64
+	unanswered uint64
+	latency    time.Duration // of all the replies, for the average
+	maxLatency time.Duration
+	pending    []time.Time // queries sent to the rig and not answered yet
+}
+
+// CatAnalytics counts the CAT commands by type, with how long the rig takes
+// to answer the queries, to tell which client floods the link.
+type CatAnalytics struct {
+	mu       sync.Mutex
+	commands map[string]*catCommandStats
+}
+
+func NewCatAnalytics() *CatAnalytics {
+	ca := new(CatAnalytics)
+	ca.commands = make(map[string]*catCommandStats)
+
+	return ca
+}
+
+func (ca *CatAnalytics) command(cmd string) *catCommandStats {
+	if len(cmd) > 2 {
+		cmd = cmd[:2]
+	}
+	stats, ok := ca.commands[cmd]
+	if !ok {
+		stats = new(catCommandStats)
+		ca.commands[cmd] = stats
+	}
+
+	return stats
+}
+
+// Requested counts a command to the driver, isLocal when the driver has
+// answered it itself.
+func (ca *CatAnalytics) Requested(cmd string, isLocal bool) {
+	if cmd == "" {
+		return
+	}
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	stats := ca.command(cmd)
+	stats.requests++
+	if isLocal {
+		stats.local++
+	}
+}
+
+// Sent starts the clock of a query going to the rig.
+func (ca *CatAnalytics) Sent(cmd string) {
+	if !isQuery(cmd) {
+		return
+	}
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	stats := ca.command(cmd)
+	stats.pending = append(stats.pending, time.Now())
+}
+
+// Replied stops the clock of the oldest query the reply answers.
+func (ca *CatAnalytics) Replied(data []byte) {
+	if len(data) < 2 {
+		return
+	}
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	stats, ok := ca.commands[string(data[:2])]
+	if !ok {
+		return
+	}
+	for len(stats.pending) > 0 && time.Since(stats.pending[0]) > catReplyTimeout {
+		stats.pending = stats.pending[1:]
+		stats.unanswered++
+	}
+	if len(stats.pending) == 0 {
+		return
+	}
+
+	latency := time.Since(stats.pending[0])
+	stats.pending = stats.pending[1:]
+	stats.replies++
+	stats.latency += latency
+	if latency > stats.maxLatency {
+		stats.maxLatency = latency
+	}
+}
+
+// String lists the commands, the most requested first, as
+// name=requests/local/unanswered/average/maximum latency.
+func (ca *CatAnalytics) String() string {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	names := make([]string, 0, len(ca.commands))
+	for name := range ca.commands {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := ca.commands[names[i]], ca.commands[names[j]]
+		if a.requests != b.requests {
+			return a.requests > b.requests
+		}
+		return names[i] < names[j]
+	})
+
+	fields := make([]string, 0, len(names))
+	for _, name := range names {
+		stats := ca.commands[name]
+		var average time.Duration
+		if stats.replies > 0 {
+			average = stats.latency / time.Duration(stats.replies)
+		}
+		fields = append(fields, fmt.Sprintf("%s=%d/%d/%d/%s/%s", name, stats.requests, stats.local,
+			stats.unanswered, average.Round(time.Microsecond), stats.maxLatency.Round(time.Microsecond)))
+	}
+
+	return strings.Join(fields, " ")
+}
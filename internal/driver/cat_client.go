@@ -0,0 +1,35 @@
+package driver
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// CatClient is a program talking CAT to the driver next to the one on the
+// virtual port, e.g. over TCP, with its own replies. The tag tells the
+// clients apart in the logs. A nil CatClient is the virtual port.
+type CatClient struct {
+	Tag     string
+	Replies chan []byte
+}
+
+func NewCatClient(tag string) *CatClient {
+	return &CatClient{
+		Tag:     tag,
+		Replies: make(chan []byte, envSize("REPLY_QUEUE_SIZE", 32)),
+	}
+}
+
+// reply hands a reply to the client. Unlike the virtual port, a client
+// which doesn't read loses its replies instead of holding up the rig.
+func (ss *SerialStream) reply(client *CatClient, data []byte) {
+	if client == nil {
+		ss.RepliesBuf <- data
+		return
+	}
+
+	select {
+	case client.Replies <- data:
+	default:
+		log.Warnf("[CAT %s]: reply dropped, the client doesn't read\n", client.Tag)
+	}
+}
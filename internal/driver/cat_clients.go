@@ -0,0 +1,82 @@
+package driver
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var errNotSupported = errors.New("not supported on this system")
+
+// CatClients tells whether anything is using the virtual CAT port. A client
+// which has sent a command recently counts as attached too, for where the
+// open files of other processes can't be looked at.
+type CatClients struct {
+	ptsName    string
+	idleAfter  time.Duration
+	lastSeenAt atomic.Int64
+}
+
+// newCatClientsFromEnv returns nil when CAT_IDLE_AFTER isn't set.
+func newCatClientsFromEnv(ptsName string) *CatClients {
+	idleAfter := envDuration("CAT_IDLE_AFTER", 0)
+	if idleAfter <= 0 {
+		return nil
+	}
+
+	cc := new(CatClients)
+	cc.ptsName = ptsName
+	cc.idleAfter = idleAfter
+	cc.lastSeenAt.Store(time.Now().UnixNano())
+
+	return cc
+}
+
+// Seen records a command from a client.
+func (cc *CatClients) Seen() {
+	if cc == nil {
+		return
+	}
+
+	cc.lastSeenAt.Store(time.Now().UnixNano())
+}
+
+func (cc *CatClients) isAttached() bool {
+	if isOpen, err := isOpenElsewhere(cc.ptsName); err == nil && isOpen {
+		cc.Seen()
+		return true
+	}
+
+	return time.Since(time.Unix(0, cc.lastSeenAt.Load())) < cc.idleAfter
+}
+
+// Watch puts the driver into the idle state when no client has been
+// attached for a while, and wakes it up when one comes.
+func (cc *CatClients) Watch(ss *SerialStream) {
+	if cc == nil {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for isRunning() {
+		<-ticker.C
+
+		isAttached := cc.isAttached()
+		if isAttached == !ss.isIdle.Load() {
+			continue
+		}
+		if !isAttached && time.Since(time.Unix(0, cc.lastSeenAt.Load())) < cc.idleAfter {
+			continue
+		}
+
+		if isAttached {
+			log.Println("A CAT client is attached, waking up")
+		} else {
+			log.Println("No CAT client attached, going idle")
+		}
+		ss.SetIdle(!isAttached)
+	}
+}
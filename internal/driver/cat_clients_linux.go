@@ -0,0 +1,30 @@
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// isOpenElsewhere tells whether another process has the file open.
+func isOpenElsewhere(name string) (bool, error) {
+	fds, err := filepath.Glob("/proc/[0-9]*/fd/*")
+	if err != nil {
+		return false, err
+	}
+
+	self := "/proc/" + strconv.Itoa(os.Getpid()) + "/"
+	for _, fd := range fds {
+		if strings.HasPrefix(fd, self) {
+			continue
+		}
+		// the processes of other users can't be looked at, nor can
+		// anything which has gone away meanwhile
+		if target, err := os.Readlink(fd); err == nil && target == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
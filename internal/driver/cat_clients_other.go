@@ -0,0 +1,7 @@
+//go:build !linux
+
+package driver
+
+func isOpenElsewhere(name string) (bool, error) {
+	return false, errNotSupported
+}
@@ -0,0 +1,130 @@
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// isCatQuery tells whether a command expects a reply, besides the plain
+// queries also the read only extended ones like SM0.
+func isCatQuery(cmd string) bool {
+	if isQuery(cmd) {
+		return true
+	}
+	for _, ext := range extCommands {
+		if ext.readOnly && cmd == ext.prefix {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Cat sends the ;-separated commands to the rig and collects the replies
+// to the queries among them.
+func (ss *SerialStream) Cat(commands string) (string, error) {
+	var output strings.Builder
+	for _, cmd := range strings.Split(commands, ";") {
+		if cmd = strings.TrimSpace(cmd); cmd == "" {
+			continue
+		}
+		if !isCatQuery(cmd) {
+			ss.PushCommand(cmd + ";")
+			continue
+		}
+
+		reply, err := ss.Query(cmd)
+		if err != nil {
+			return output.String(), err
+		}
+		fmt.Fprintf(&output, "%s;\n", reply)
+	}
+
+	return output.String(), nil
+}
+
+func registerCatCommands(cs *ControlServer, ss *SerialStream) {
+	cs.Handle("cat", func(args []string) (string, error) {
+		if len(args) == 0 {
+			return "", errUsage
+		}
+
+		return ss.Cat(strings.Join(args, " "))
+	})
+}
+
+// runCatCommand goes through the driver when it's running, otherwise it
+// opens the serial port for a moment.
+func runCatCommand(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	output, err := controlRequest(append([]string{"cat"}, args...))
+	if errors.Is(err, errNotRunning) {
+		output, err = catOverSerialPort(strings.Join(args, " "))
+	}
+	fmt.Print(output)
+
+	return err
+}
+
+func catOverSerialPort(commands string) (string, error) {
+	port, err := openCatPort()
+	if err != nil {
+		return "", err
+	}
+	defer port.Close()
+
+	return catOverPort(port, commands)
+}
+
+// openCatPort opens the serial port of the rig and waits for it to answer,
+// opening it resets the rig.
+func openCatPort() (*SerialPort, error) {
+	port, err := OpenSerialPort(serialPortName())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := waitRigReady(port, envDuration("READY_TIMEOUT", 10*time.Second)); err != nil {
+		port.Close()
+		return nil, err
+	}
+	port.SetReadTimeout(catReplyTimeout)
+
+	return port, nil
+}
+
+// catOverPort sends the ;-separated commands to the port opened by
+// openCatPort and collects the replies to the queries.
+func catOverPort(port *SerialPort, commands string) (string, error) {
+	var output strings.Builder
+	for _, cmd := range strings.Split(commands, ";") {
+		if cmd = strings.TrimSpace(cmd); cmd == "" {
+			continue
+		}
+		port.Write([]byte(cmd + ";"))
+		port.Flush()
+		if !isCatQuery(cmd) {
+			continue
+		}
+
+		var reply []byte
+		chunk := make([]byte, maxReplyLength)
+		for !bytes.HasSuffix(reply, []byte(";")) {
+			n, err := port.Read(chunk)
+			if n == 0 || err != nil {
+				return output.String(), fmt.Errorf("%s: %w", cmd, ErrNoReply)
+			}
+			reply = append(reply, chunk[:n]...)
+		}
+		output.Write(reply)
+		output.WriteString("\n")
+	}
+
+	return output.String(), nil
+}
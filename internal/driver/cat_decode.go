@@ -0,0 +1,84 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var modeNames = map[byte]string{
+	'1': "LSB",
+	'2': "USB",
+	'3': "CW",
+	'4': "FM",
+	'5': "AM",
+	'6': "FSK",
+	'7': "CW-R",
+	'9': "FSK-R",
+}
+
+// describeCat turns a CAT command or reply into a human readable form, an
+// empty string is returned for the ones it doesn't know.
+func describeCat(msg string) string {
+	msg = strings.TrimSuffix(msg, ";")
+	if len(msg) < 2 {
+		return ""
+	}
+
+	cmd, args := msg[:2], msg[2:]
+	switch cmd {
+	case "FA", "FB":
+		if args == "" {
+			return fmt.Sprintf("read VFO %c", cmd[1])
+		}
+		if hz, err := strconv.ParseInt(args, 10, 64); err == nil {
+			return fmt.Sprintf("VFO %c %d Hz", cmd[1], hz)
+		}
+	case "MD":
+		if args == "" {
+			return "read mode"
+		}
+		if name, ok := modeNames[args[0]]; ok {
+			return "mode " + name
+		}
+	case "IF":
+		if args == "" {
+			return "read status"
+		}
+		if len(args) >= 28 {
+			hz, _ := strconv.ParseInt(args[:11], 10, 64)
+			ptt := "RX"
+			if args[26] == '1' {
+				ptt = "TX"
+			}
+			return fmt.Sprintf("status %d Hz, %s, %s", hz, modeNames[args[27]], ptt)
+		}
+	case "TX":
+		if args == "2" {
+			return "tune"
+		}
+		return "transmit"
+	case "RX":
+		return "receive"
+	case "ID":
+		if args == "" {
+			return "read model"
+		}
+		if name := rigModelName(args); name != "" {
+			return "model " + args + ", " + name
+		}
+		return "model " + args
+	case "UA":
+		if args == "" {
+			return "read audio streaming"
+		}
+		return "audio streaming " + args
+	case "AI":
+		if args == "" {
+			return "read auto-information"
+		}
+		return "auto-information " + args
+	}
+
+	return ""
+}
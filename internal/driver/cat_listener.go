@@ -0,0 +1,121 @@
+package driver
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CatListener takes CAT clients over TCP, for programs on other machines
+// or outside of a container, e.g. hamlib with the rig at host:port. Each
+// connection is a client of its own, next to the one on the virtual port.
+// A nil CatListener does nothing.
+type CatListener struct {
+	ss       *SerialStream
+	script   *Script
+	offset   *FrequencyOffset
+	clients  *CatClients
+	listener net.Listener
+	mu       sync.Mutex
+	conns    map[net.Conn]bool
+}
+
+// startCatListenerFromEnv listens on CAT_LISTEN, on the loopback interface
+// when it has no host, it returns nil when it isn't set.
+func startCatListenerFromEnv(ss *SerialStream, script *Script, offset *FrequencyOffset, clients *CatClients) (*CatListener, error) {
+	address, ok := os.LookupEnv("CAT_LISTEN")
+	if !ok || address == "" {
+		return nil, nil
+	}
+
+	listener, err := net.Listen("tcp", loopbackByDefault(address))
+	if err != nil {
+		return nil, err
+	}
+	listener = newAccessListFromEnv("CAT").Listener(listener)
+	cl := &CatListener{ss: ss, script: script, offset: offset, clients: clients, listener: listener}
+	cl.conns = make(map[net.Conn]bool)
+	log.Printf("CAT over TCP: %s\n", listener.Addr())
+	go cl.serve()
+
+	return cl, nil
+}
+
+func (cl *CatListener) serve() {
+	for {
+		conn, err := cl.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			log.Errorln(err)
+			continue
+		}
+
+		cl.mu.Lock()
+		cl.conns[conn] = true
+		cl.mu.Unlock()
+		go cl.serveClient(conn)
+	}
+}
+
+func (cl *CatListener) serveClient(conn net.Conn) {
+	client := NewCatClient("tcp:" + conn.RemoteAddr().String())
+	log.Infof("CAT client %s connected\n", client.Tag)
+	defer log.Infof("CAT client %s disconnected\n", client.Tag)
+	defer func() {
+		cl.mu.Lock()
+		delete(cl.conns, conn)
+		cl.mu.Unlock()
+		conn.Close()
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case reply := <-client.Replies:
+				cmd := cl.offset.ToClient(cl.script.FilterReply(reply))
+				log.Debugf("[CAT %s <- Rig]: %s\n", client.Tag, cmd)
+				conn.Write([]byte(cmd))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		cmdString, err := reader.ReadString(';')
+		if err != nil {
+			return
+		}
+		log.Debugf("[CAT %s -> Rig]: %s\n", client.Tag, cmdString)
+		cl.clients.Seen()
+		cmdString, rejected := cl.offset.ToRig(cmdString)
+		cmdString, replies := cl.script.FilterCommands(cmdString)
+		if replies = rejected + replies; replies != "" {
+			conn.Write([]byte(replies))
+		}
+		if cmdString != "" {
+			cl.ss.PushCommandFrom(client, cmdString)
+		}
+	}
+}
+
+func (cl *CatListener) Close() {
+	if cl == nil {
+		return
+	}
+
+	cl.listener.Close()
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	for conn := range cl.conns {
+		conn.Close()
+	}
+}
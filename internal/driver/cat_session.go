@@ -0,0 +1,84 @@
+package driver
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// a reply the client doesn't read for this long means it has gone away,
+// e.g. closed the port to open it again later
+const catWriteTimeout = 2 * time.Second
+
+// CatSession is the client on the virtual CAT port. When it goes away,
+// what it has left behind is cleaned up, so the next one starts afresh
+// instead of wedging the bridge.
+type CatSession struct {
+	ss      *SerialStream
+	pts     *os.File
+	clients *CatClients
+	isGone  atomic.Bool
+}
+
+func NewCatSession(ss *SerialStream, pts *os.File, clients *CatClients) *CatSession {
+	cs := new(CatSession)
+	cs.ss = ss
+	cs.pts = pts
+	cs.clients = clients
+
+	return cs
+}
+
+// Tag tells the client apart from those over TCP in the logs.
+func (cs *CatSession) Tag() string {
+	return cs.pts.Name()
+}
+
+// Hangup forgets the client: the replies it hasn't read are dropped, and
+// the auto-information it has asked for is turned off.
+func (cs *CatSession) Hangup() {
+	if cs.isGone.Swap(true) {
+		return
+	}
+
+	log.Printf("The CAT client on %s has gone away\n", cs.Tag())
+	cs.flush()
+	cs.ss.aiMode.Store(0)
+}
+
+// IsGone tells whether the replies have nobody to go to.
+func (cs *CatSession) IsGone() bool {
+	return cs.isGone.Load()
+}
+
+// Seen is called for every command from the client, the first one after a
+// hangup brings the state of the rig up to date for it.
+func (cs *CatSession) Seen() {
+	cs.clients.Seen()
+	if !cs.isGone.Swap(false) {
+		return
+	}
+
+	log.Printf("A CAT client is back on %s, resyncing\n", cs.Tag())
+	cs.flush()
+	go func() {
+		if _, err := cs.ss.Query("IF"); err != nil {
+			log.Debugln(err)
+		}
+	}()
+}
+
+func (cs *CatSession) flush() {
+	if err := flushInput(cs.pts); err != nil {
+		log.Debugln(err)
+	}
+	for {
+		select {
+		case <-cs.ss.RepliesBuf:
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package driver
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	text, ok := os.LookupEnv(name)
+	if !ok {
+		return fallback
+	}
+
+	value, err := time.ParseDuration(text)
+	if err != nil {
+		log.Warnf("Invalid %s value %q, using %s\n", name, text, fallback)
+		return fallback
+	}
+
+	return value
+}
+
+func envInt(name string, fallback int) int {
+	text, ok := os.LookupEnv(name)
+	if !ok {
+		return fallback
+	}
+
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		log.Warnf("Invalid %s value %q, using %d\n", name, text, fallback)
+		return fallback
+	}
+
+	return value
+}
+
+// envSize is envInt for sizes of queues and buffers, which can't be zero.
+func envSize(name string, fallback int) int {
+	value := envInt(name, fallback)
+	if value < 1 {
+		log.Warnf("Invalid %s value %d, using %d\n", name, value, fallback)
+		return fallback
+	}
+
+	return value
+}
+
+const (
+	driverModeFull = "full"
+	// only the CAT bridge, the audio goes through the jacks of the rig
+	driverModeCat = "cat"
+	// CAT goes to the rig untouched, for programs which want to talk to it
+	// directly, the driver only streams the audio
+	driverModeAudio = "audio"
+	// no sound card, the audio only goes over the network, e.g. in a
+	// container
+	driverModeServer = "server"
+	// no sound card, the audio goes to stdout and comes from stdin, for
+	// piping into decoders
+	driverModePipe = "pipe"
+)
+
+// driverMode tells which parts of the driver run, from DRIVER_MODE.
+func driverMode() string {
+	mode, ok := os.LookupEnv("DRIVER_MODE")
+	if !ok {
+		return driverModeFull
+	}
+
+	switch mode {
+	case driverModeFull, driverModeCat, driverModeAudio, driverModeServer, driverModePipe:
+		return mode
+	}
+	log.Warnf("Invalid DRIVER_MODE value %q, using %s\n", mode, driverModeFull)
+
+	return driverModeFull
+}
@@ -0,0 +1,215 @@
+package driver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+const consoleHelp = `Raw CAT commands are sent as they are, e.g. FA; or IF;MD;, in any case.
+Symbolic ones read a value, or set it when one is given:
+  freq [Hz]         VFO frequency
+  mode [name]       LSB, USB, CW, FM, AM...
+  band <name>       the FT8 frequency of the band, e.g. 20m
+  tx, tune, rx      key the rig, tune, release it
+  id, status        the model, the IF status
+  <setting> [value] the settings listed by trusdx-go ext, e.g. smeter
+help shows this, quit or Ctrl-D leaves.
+`
+
+// consoleCommand turns a line of the console into CAT commands, anything
+// which isn't a symbolic command is taken as raw CAT.
+func consoleCommand(line string) (string, error) {
+	fields := strings.Fields(line)
+	name, value := strings.ToLower(fields[0]), ""
+	if len(fields) > 1 {
+		value = fields[1]
+	}
+	isSymbolic := len(fields) <= 2
+
+	switch {
+	case !isSymbolic:
+	case name == "freq" && value == "":
+		return "FA;", nil
+	case name == "freq":
+		frequency, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || frequency <= 0 {
+			return "", fmt.Errorf("invalid frequency %q, it's in Hz", value)
+		}
+		return fmt.Sprintf("FA%011d;", frequency), nil
+	case name == "mode" && value == "":
+		return "MD;", nil
+	case name == "mode":
+		mode, ok := parseModeName(value)
+		if !ok {
+			return "", fmt.Errorf("unknown mode %q", value)
+		}
+		return fmt.Sprintf("MD%c;", mode), nil
+	case name == "band":
+		for _, b := range hamBands {
+			if strings.EqualFold(b.name, value) {
+				return fmt.Sprintf("FA%011d;", b.home), nil
+			}
+		}
+		return "", fmt.Errorf("unknown band %q", value)
+	case name == "tx" && value == "":
+		return "TX;", nil
+	case name == "tune" && value == "":
+		return "TX2;", nil
+	case name == "rx" && value == "":
+		return "RX;", nil
+	case name == "id" && value == "":
+		return "ID;", nil
+	case name == "status" && value == "":
+		return "IF;", nil
+	}
+
+	if ext, ok := findExtCommand(name); ok && isSymbolic {
+		if value == "" {
+			return ext.prefix + ";", nil
+		}
+		number, err := strconv.Atoi(value)
+		text := fmt.Sprintf("%0*d", ext.digits, number)
+		if ext.readOnly || err != nil || number < 0 || len(text) > ext.digits {
+			return "", fmt.Errorf("invalid value of %s", ext.name)
+		}
+		return ext.prefix + text + ";", nil
+	}
+
+	return strings.ToUpper(line), nil
+}
+
+// describeConsole is describeCat knowing the settings too.
+func describeConsole(msg string) string {
+	if description := describeCat(msg); description != "" {
+		return description
+	}
+
+	msg = strings.TrimSuffix(msg, ";")
+	for _, ext := range extCommands {
+		args, ok := strings.CutPrefix(msg, ext.prefix)
+		if !ok {
+			continue
+		}
+		if args == "" {
+			return "read " + ext.name
+		}
+		if value, err := strconv.Atoi(args); err == nil {
+			return fmt.Sprintf("%s %d", ext.name, value)
+		}
+	}
+
+	return ""
+}
+
+// runConsole is an interactive prompt for exploring the rig, through the
+// running driver or, when there's none, the serial port held open for the
+// whole session.
+func runConsole(args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	send := func(commands string) (string, error) {
+		return controlRequest([]string{"cat", commands})
+	}
+	if _, err := controlRequest([]string{"protocol"}); errors.Is(err, errNotRunning) {
+		fmt.Fprintln(os.Stderr, "No driver running, opening the serial port, which resets the rig...")
+		port, err := openCatPort()
+		if err != nil {
+			return err
+		}
+		// with nothing else on the port, a rig left keyed would stay so
+		var isKeyed atomic.Bool
+		var once sync.Once
+		release := func() {
+			once.Do(func() {
+				if isKeyed.Load() {
+					fmt.Fprintln(os.Stderr, "Releasing the PTT")
+					catOverPort(port, "RX")
+				}
+				port.Close()
+			})
+		}
+		defer release()
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(interrupt)
+		go func() {
+			if _, ok := <-interrupt; ok {
+				release()
+				os.Exit(1)
+			}
+		}()
+		send = func(commands string) (string, error) {
+			for _, cmd := range strings.Split(commands, ";") {
+				if cmd = strings.TrimSpace(cmd); strings.HasPrefix(cmd, "TX") {
+					isKeyed.Store(true)
+				} else if cmd == "RX" {
+					isKeyed.Store(false)
+				}
+			}
+			return catOverPort(port, commands)
+		}
+	}
+
+	// the prompt only for a person, not for commands piped in
+	info, err := os.Stdin.Stat()
+	isInteractive := err == nil && info.Mode()&os.ModeCharDevice != 0
+	if isInteractive {
+		fmt.Println(`CAT console, "help" lists the commands.`)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		if isInteractive {
+			fmt.Print("cat> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch strings.ToLower(line) {
+		case "help", "?":
+			fmt.Print(consoleHelp)
+			continue
+		case "quit", "exit":
+			return nil
+		}
+
+		commands, err := consoleCommand(line)
+		if err != nil {
+			fmt.Printf("error: %s\n", err)
+			continue
+		}
+		output, err := send(commands)
+		for _, cmd := range strings.Split(commands, ";") {
+			if cmd = strings.TrimSpace(cmd); cmd != "" && !isCatQuery(cmd) {
+				fmt.Printf("%-20s  %s\n", "sent "+cmd+";", describeConsole(cmd))
+			}
+		}
+		for _, reply := range strings.Split(strings.TrimSpace(output), "\n") {
+			if reply != "" {
+				fmt.Printf("%-20s  %s\n", reply, describeConsole(reply))
+			}
+		}
+		if err != nil {
+			fmt.Printf("error: %s\n", err)
+		}
+	}
+	if isInteractive {
+		fmt.Println()
+	}
+
+	return scanner.Err()
+}
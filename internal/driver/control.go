@@ -0,0 +1,182 @@
+package driver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/leshniak/trusdx-go/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// The control socket takes a single line of space separated words and
+// answers with "OK" or "ERR <message>", followed by the output lines. This
+// is version 1 of the protocol, which trusdxctl speaks too; new commands
+// don't change it.
+const controlProtocolVersion = 1
+
+type controlHandler func(args []string) (string, error)
+
+var (
+	errUsage      = errors.New("invalid arguments")
+	errNotRunning = errors.New("the driver doesn't seem to be running")
+	errRunning    = errors.New("the driver is already running")
+)
+
+type ControlServer struct {
+	path     string
+	listener net.Listener
+	mu       sync.Mutex
+	handlers map[string]controlHandler
+}
+
+func NewControlServer(path string) *ControlServer {
+	cs := new(ControlServer)
+	cs.path = path
+	cs.handlers = make(map[string]controlHandler)
+	cs.Handle("help", cs.help)
+	cs.Handle("protocol", func(args []string) (string, error) {
+		return fmt.Sprintf("%d\n", controlProtocolVersion), nil
+	})
+
+	return cs
+}
+
+func (cs *ControlServer) Handle(name string, handler controlHandler) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.handlers[name] = handler
+}
+
+func (cs *ControlServer) Start() error {
+	// a socket nobody listens on is a leftover from a previous run which
+	// didn't clean up, one somebody does belongs to another driver
+	conn, err := net.Dial("unix", cs.path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%w, its control socket is %s", errRunning, cs.path)
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		os.Remove(cs.path)
+	}
+
+	listener, err := net.Listen("unix", cs.path)
+	if err != nil {
+		return err
+	}
+	cs.listener = listener
+
+	go cs.serve()
+
+	return nil
+}
+
+func (cs *ControlServer) serve() {
+	for {
+		conn, err := cs.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			log.Errorln(err)
+			continue
+		}
+		go cs.handleConn(conn)
+	}
+}
+
+func (cs *ControlServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return
+	}
+	log.Debugf("[Control]: %s\n", strings.Join(args, " "))
+
+	cs.mu.Lock()
+	handler, ok := cs.handlers[args[0]]
+	cs.mu.Unlock()
+
+	var output string
+	if !ok {
+		err = fmt.Errorf("unknown command %q, try help", args[0])
+	} else {
+		output, err = handler(args[1:])
+	}
+
+	if err != nil {
+		fmt.Fprintf(conn, "ERR %s\n", err)
+		return
+	}
+	fmt.Fprintf(conn, "OK\n%s", output)
+}
+
+func (cs *ControlServer) help(args []string) (string, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	names := make([]string, 0, len(cs.handlers))
+	for name := range cs.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, "\n") + "\n", nil
+}
+
+func (cs *ControlServer) Close() {
+	if cs.listener != nil {
+		cs.listener.Close()
+	}
+}
+
+// controlRequest sends a command to the running driver and returns its
+// output.
+func controlRequest(args []string) (string, error) {
+	conn, err := net.Dial("unix", config.ControlSocketPath())
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errNotRunning, err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintln(conn, strings.Join(args, " "))
+
+	reader := bufio.NewReader(conn)
+	status, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	var output strings.Builder
+	if _, err := reader.WriteTo(&output); err != nil {
+		return "", err
+	}
+
+	if message, isError := strings.CutPrefix(strings.TrimSpace(status), "ERR "); isError {
+		return "", errors.New(message)
+	}
+
+	return output.String(), nil
+}
+
+func runControlCommand(args []string) error {
+	output, err := controlRequest(args)
+	if err != nil {
+		return err
+	}
+	fmt.Print(output)
+
+	return nil
+}
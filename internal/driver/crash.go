@@ -0,0 +1,147 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CatHistory keeps the latest CAT messages over the serial port, for the
+// crash dumps.
+type CatHistory struct {
+	mu      sync.Mutex
+	entries []catHistoryEntry
+	next    int
+	isFull  bool
+}
+
+type catHistoryEntry struct {
+	at      time.Time
+	fromRig bool
+	data    string
+}
+
+// NewCatHistory returns nil, which keeps nothing, for a size of 0.
+func NewCatHistory(size int) *CatHistory {
+	if size <= 0 {
+		return nil
+	}
+
+	return &CatHistory{entries: make([]catHistoryEntry, size)}
+}
+
+func (ch *CatHistory) Add(fromRig bool, data []byte) {
+	if ch == nil {
+		return
+	}
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	ch.entries[ch.next] = catHistoryEntry{time.Now(), fromRig, string(data)}
+	ch.next = (ch.next + 1) % len(ch.entries)
+	if ch.next == 0 {
+		ch.isFull = true
+	}
+}
+
+// String lists the messages, the oldest first.
+func (ch *CatHistory) String() string {
+	if ch == nil {
+		return ""
+	}
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	entries := ch.entries[:ch.next]
+	if ch.isFull {
+		entries = append(append([]catHistoryEntry(nil), ch.entries[ch.next:]...), ch.entries[:ch.next]...)
+	}
+	var sb strings.Builder
+	for _, entry := range entries {
+		arrow := "->"
+		if entry.fromRig {
+			arrow = "<-"
+		}
+		fmt.Fprintf(&sb, "%s %s %q\n", entry.at.Format("15:04:05.000000"), arrow, entry.data)
+	}
+
+	return sb.String()
+}
+
+// crashDumpDir is CRASH_DUMP_DIR if set, otherwise the user's cache
+// directory, e.g. ~/.cache/trusdx-go.
+func crashDumpDir() string {
+	if dir, ok := os.LookupEnv("CRASH_DUMP_DIR"); ok {
+		return dir
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return os.TempDir()
+	}
+
+	return filepath.Join(dir, "trusdx-go")
+}
+
+// writeCrashDump writes what went wrong and the state of the driver to a
+// new file and returns its path.
+func (ss *SerialStream) writeCrashDump(name string, value any, stack []byte) (string, error) {
+	dir := crashDumpDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	path := filepath.Join(dir, "crash-"+now.Format("20060102-150405")+".txt")
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Panic in %s at %s: %v\n\n%s\n", name, now.Format(time.RFC3339), value, stack)
+	fmt.Fprintf(&sb, "Port:  %s, link %s\n", ss.PortName(), ss.link.State())
+	mode, ok := modeNames[ss.State.Mode()]
+	if !ok {
+		mode = "mode unknown"
+	}
+	fmt.Fprintf(&sb, "Rig:   %d Hz, %s, transmitting %t\n", ss.State.Frequency(), mode, ss.State.Transmitting())
+	fmt.Fprintf(&sb, "Stats: %s\n", ss.Stats.String())
+	fmt.Fprintf(&sb, "Queues: %s\n\n", ss.queueDepths())
+	fmt.Fprintf(&sb, "Recent CAT messages:\n%s\n", ss.catHistory.String())
+	all := make([]byte, 1<<20)
+	fmt.Fprintf(&sb, "All goroutines:\n%s", all[:runtime.Stack(all, true)])
+
+	return path, os.WriteFile(path, []byte(sb.String()), 0o644)
+}
+
+// recoverCrash is deferred in the goroutines of the driver, it writes a
+// crash dump of a panic and exits, for the service manager to restart the
+// driver.
+func (ss *SerialStream) recoverCrash(name string) {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	stack := debug.Stack()
+	log.Errorf("Panic in %s: %v\n%s", name, value, stack)
+	if path, err := ss.writeCrashDump(name, value, stack); err != nil {
+		log.Errorf("Crash dump not written: %s\n", err)
+	} else {
+		log.Errorf("Crash dump written to %s\n", path)
+	}
+	os.Exit(2)
+}
+
+// goGuarded runs f in a goroutine with a crash dump on a panic.
+func (ss *SerialStream) goGuarded(name string, f func()) {
+	go func() {
+		defer ss.recoverCrash(name)
+		f()
+	}()
+}
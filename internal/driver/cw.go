@@ -0,0 +1,63 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// The CW pitch of Kenwood's PT command, from 400 to 1000 Hz in steps of 50.
+const (
+	cwPitchLow  = 400
+	cwPitchHigh = 1000
+	cwPitchStep = 50
+)
+
+func cwToneFromPitch(pitch int) int {
+	return cwPitchLow + pitch*cwPitchStep
+}
+
+func cwPitchFromTone(tone int) (int, error) {
+	if tone < cwPitchLow || tone > cwPitchHigh || tone%cwPitchStep != 0 {
+		return 0, fmt.Errorf("the CW tone goes from %d to %d Hz in steps of %d", cwPitchLow, cwPitchHigh, cwPitchStep)
+	}
+
+	return (tone - cwPitchLow) / cwPitchStep, nil
+}
+
+// cw               shows the keyer speed and the CW tone of the rig
+// cw speed <wpm>   sets the speed
+// cw tone <hz>     sets the tone
+func registerCwCommands(cs *ControlServer, ss *SerialStream) {
+	cs.Handle("cw", func(args []string) (string, error) {
+		switch {
+		case len(args) == 0:
+			speed, err := ss.GetExtended("cw-speed")
+			if err != nil {
+				return "", err
+			}
+			pitch, err := ss.GetExtended("cw-tone")
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("speed %d\ntone %d\n", speed, cwToneFromPitch(pitch)), nil
+		case len(args) == 2 && args[0] == "speed":
+			speed, err := strconv.Atoi(args[1])
+			if err != nil {
+				return "", fmt.Errorf("invalid speed %q", args[1])
+			}
+			return "", ss.SetExtended("cw-speed", speed)
+		case len(args) == 2 && args[0] == "tone":
+			tone, err := strconv.Atoi(args[1])
+			if err != nil {
+				return "", fmt.Errorf("invalid tone %q", args[1])
+			}
+			pitch, err := cwPitchFromTone(tone)
+			if err != nil {
+				return "", err
+			}
+			return "", ss.SetExtended("cw-tone", pitch)
+		}
+
+		return "", errUsage
+	})
+}
@@ -0,0 +1,168 @@
+//go:build linux
+
+package driver
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	log "github.com/sirupsen/logrus"
+)
+
+// The state of the rig on D-Bus, for desktop applets, which can tune it
+// and key it too.
+
+const (
+	dbusName      = "io.github.leshniak.trusdx"
+	dbusPath      = "/io/github/leshniak/trusdx"
+	dbusInterface = "io.github.leshniak.trusdx"
+)
+
+// DbusService offers the rig on the bus.
+type DbusService struct {
+	ss    *SerialStream
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+// dbusRig has the methods of the interface.
+type dbusRig struct {
+	ss *SerialStream
+}
+
+func (r dbusRig) SetFrequency(hz uint64) *dbus.Error {
+	if hz == 0 || hz > 99999999999 {
+		return dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs", []any{fmt.Sprintf("invalid frequency %d", hz)})
+	}
+	r.ss.PushCommand(fmt.Sprintf("FA%011d", hz))
+
+	return nil
+}
+
+func (r dbusRig) SetMode(name string) *dbus.Error {
+	mode, ok := parseModeName(name)
+	if !ok {
+		return dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs", []any{fmt.Sprintf("invalid mode %q", name)})
+	}
+	r.ss.PushCommand(fmt.Sprintf("MD%c", mode))
+
+	return nil
+}
+
+func (r dbusRig) SetPtt(transmit bool) *dbus.Error {
+	if transmit {
+		r.ss.PushCommand("TX")
+	} else {
+		r.ss.PushCommand("RX")
+	}
+
+	return nil
+}
+
+// startDbusFromEnv offers the rig on the bus in DBUS_BUS, session or
+// system, it returns nil when it's not set.
+func startDbusFromEnv(ss *SerialStream) *DbusService {
+	bus, ok := os.LookupEnv("DBUS_BUS")
+	if !ok || bus == "" {
+		return nil
+	}
+	if bus != "session" && bus != "system" {
+		log.Fatalf("Invalid DBUS_BUS value %q, expected session or system\n", bus)
+	}
+
+	ds, err := connectDbus(ss, bus)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("D-Bus: %s on the %s bus\n", dbusName, bus)
+
+	return ds
+}
+
+func connectDbus(ss *SerialStream, bus string) (*DbusService, error) {
+	connect := dbus.ConnectSessionBus
+	if bus == "system" {
+		connect = dbus.ConnectSystemBus
+	}
+	conn, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	ds := &DbusService{ss: ss, conn: conn}
+
+	rig := dbusRig{ss}
+	if err := conn.Export(rig, dbusPath, dbusInterface); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// read-only, the methods change them
+	property := func(value any) *prop.Prop {
+		return &prop.Prop{Value: value, Emit: prop.EmitTrue}
+	}
+	ds.props, err = prop.Export(conn, dbusPath, prop.Map{dbusInterface: {
+		"Frequency":    property(uint64(ss.State.Frequency())),
+		"Mode":         property(modeNames[ss.State.Mode()]),
+		"Transmitting": property(ss.State.Transmitting()),
+		"Connected":    property(ss.IsRunning()),
+	}})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	node := &introspect.Node{
+		Name: dbusPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       dbusInterface,
+				Methods:    introspect.Methods(rig),
+				Properties: ds.props.Introspection(dbusInterface),
+			},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), dbusPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// don't queue behind another driver
+	reply, err := conn.RequestName(dbusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return nil, fmt.Errorf("the D-Bus name %s is taken", dbusName)
+	}
+
+	ss.Events.Subscribe(ds.publish)
+
+	return ds, nil
+}
+
+// publish tells the applets about the changes.
+func (ds *DbusService) publish(event Event) {
+	switch e := event.(type) {
+	case FrequencyChanged:
+		ds.props.SetMust(dbusInterface, "Frequency", uint64(e.Frequency))
+	case ModeChanged:
+		ds.props.SetMust(dbusInterface, "Mode", modeNames[e.Mode])
+	case PttChanged:
+		ds.props.SetMust(dbusInterface, "Transmitting", e.Transmitting)
+	case ConnectionChanged:
+		ds.props.SetMust(dbusInterface, "Connected", e.Connected)
+	}
+}
+
+func (ds *DbusService) Close() {
+	if ds == nil {
+		return
+	}
+
+	ds.conn.Close()
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package driver
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type DbusService struct{}
+
+func startDbusFromEnv(ss *SerialStream) *DbusService {
+	if os.Getenv("DBUS_BUS") != "" {
+		log.Warnln("DBUS_BUS is only supported on Linux")
+	}
+
+	return nil
+}
+
+func (ds *DbusService) Close() {}
@@ -0,0 +1,72 @@
+package driver
+
+import (
+	"math"
+	"math/rand"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// the top 8 bits, as the rig has always got them
+	ditherOff = "off"
+	// triangular noise of 2 LSB, decorrelating the error from the signal
+	ditherTpdf = "tpdf"
+	// the same, with the noise pushed up in frequency, away from the voice
+	ditherShaped = "shaped"
+)
+
+// Quantizer turns 16-bit samples into the 8-bit unsigned ones of the rig.
+type Quantizer struct {
+	mode  string
+	error float64 // of the previous sample, for the noise shaping
+	rand  *rand.Rand
+}
+
+func newQuantizerFromEnv() *Quantizer {
+	mode, ok := os.LookupEnv("TX_DITHER")
+	if !ok {
+		mode = ditherOff
+	}
+
+	switch mode {
+	case ditherOff, ditherTpdf, ditherShaped:
+	default:
+		log.Warnf("Invalid TX_DITHER value %q, using %s\n", mode, ditherOff)
+		mode = ditherOff
+	}
+
+	return &Quantizer{mode: mode, rand: rand.New(rand.NewSource(1))}
+}
+
+func (q *Quantizer) Quantize(samples []int16) []byte {
+	quantized := make([]byte, len(samples))
+	for i, sample := range samples {
+		quantized[i] = q.quantize(float64(sample) / 32768)
+	}
+
+	return quantized
+}
+
+// quantize takes a sample from -1 to 1.
+func (q *Quantizer) quantize(sample float64) byte {
+	// in 8-bit steps
+	value := sample * 128
+	if q.mode == ditherOff {
+		return byte(int(math.Floor(value)) + 128)
+	}
+
+	if q.mode == ditherShaped {
+		value -= q.error
+	}
+	dithered := math.Round(value + q.rand.Float64() - q.rand.Float64())
+	if dithered < -128 {
+		dithered = -128
+	} else if dithered > 127 {
+		dithered = 127
+	}
+	q.error = dithered - value
+
+	return byte(int(dithered) + 128)
+}
@@ -0,0 +1,154 @@
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// doctorCheck returns what it found, or an error with a hint how to fix it.
+type doctorCheck struct {
+	name string
+	run  func() (string, error)
+}
+
+type doctorError struct {
+	problem string
+	fix     string
+}
+
+func (e *doctorError) Error() string {
+	return e.problem
+}
+
+// runDoctor checks the environment the driver needs, most problems are
+// there rather than in the driver.
+func runDoctor(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	checks := []doctorCheck{
+		{"Serial port", checkSerialPort},
+		{"PTY", checkPty},
+		{"Audio", checkAudio},
+		{"Timers", checkTimers},
+	}
+
+	failed := 0
+	for _, check := range checks {
+		found, err := check.run()
+		if err == nil {
+			fmt.Printf("[ OK ] %s: %s\n", check.name, found)
+			continue
+		}
+
+		failed++
+		fmt.Printf("[FAIL] %s: %s\n", check.name, err)
+		var de *doctorError
+		if errors.As(err, &de) && de.fix != "" {
+			fmt.Printf("       %s\n", de.fix)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+
+	return nil
+}
+
+func checkSerialPort() (string, error) {
+	if _, err := controlRequest([]string{"help"}); err == nil {
+		return "in use by the running driver", nil
+	}
+
+	name := serialPortName()
+	if _, err := os.Stat(name); err != nil {
+		return "", &doctorError{
+			fmt.Sprintf("%s not found", name),
+			"Plug the rig in, check `trusdx-go ports` and set SERIAL_PORT.",
+		}
+	}
+
+	port, err := OpenSerialPort(name)
+	var portErr *serial.PortError
+	if errors.As(err, &portErr) && portErr.Code() == serial.PermissionDenied {
+		fix := "Add yourself to the group owning the port and log in again."
+		if runtime.GOOS == "linux" {
+			fix = "Add yourself to the dialout group (`sudo usermod -aG dialout $USER`) and log in again."
+		}
+		return "", &doctorError{err.Error(), fix}
+	} else if errors.As(err, &portErr) && portErr.Code() == serial.PortBusy {
+		return "", &doctorError{err.Error(), "Close the program using the port."}
+	} else if err != nil {
+		return "", err
+	}
+	defer port.Close()
+
+	if err := waitRigReady(port, 10*time.Second); err != nil {
+		return "", &doctorError{
+			fmt.Sprintf("%s: no reply to ID;", name),
+			"Check the rig is on and SERIAL_PORT points to it, not to another USB serial adapter.",
+		}
+	}
+	port.SetReadTimeout(time.Second)
+	port.Write([]byte(";UA0;ID;"))
+
+	reply := make([]byte, 0, maxReplyLength)
+	chunk := make([]byte, maxReplyLength)
+	for !bytes.Contains(reply, []byte("ID")) || !bytes.HasSuffix(reply, []byte(";")) {
+		n, err := port.Read(chunk)
+		if n == 0 || err != nil {
+			return "", &doctorError{
+				fmt.Sprintf("%s: no reply to ID;", name),
+				"Check the rig is on and SERIAL_PORT points to it, not to another USB serial adapter.",
+			}
+		}
+		reply = append(reply, chunk[:n]...)
+		if len(reply) > maxReplyLength {
+			reply = reply[len(reply)-maxReplyLength:]
+		}
+	}
+
+	return fmt.Sprintf("%s, the rig replied %s", name, reply[bytes.LastIndex(reply, []byte("ID")):]), nil
+}
+
+func checkPty() (string, error) {
+	ptm, pts, err := openPty()
+	if err != nil {
+		return "", &doctorError{err.Error(), "Check that /dev/ptmx (or /dev/ptm) exists and is accessible."}
+	}
+	defer ptm.Close()
+	defer pts.Close()
+
+	return pts.Name(), nil
+}
+
+// checkTimers measures how late short sleeps wake up, the audio is paced
+// in chunks of a few milliseconds.
+func checkTimers() (string, error) {
+	const count = 50
+	const period = time.Millisecond
+
+	var worst time.Duration
+	for i := 0; i < count; i++ {
+		start := time.Now()
+		time.Sleep(period)
+		if late := time.Since(start) - period; late > worst {
+			worst = late
+		}
+	}
+
+	found := fmt.Sprintf("sleeps wake up at most %s late", worst.Round(10*time.Microsecond))
+	if worst > 4*time.Millisecond {
+		return "", &doctorError{found, "Close busy programs, disable power saving, or raise RX_BUFFER_MIN."}
+	}
+
+	return found, nil
+}
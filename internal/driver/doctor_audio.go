@@ -0,0 +1,49 @@
+//go:build !noportaudio
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+func checkAudio() (string, error) {
+	if err := portaudio.Initialize(); err != nil {
+		return "", err
+	}
+	defer portaudio.Terminate()
+
+	host, err := audioHostFromEnv()
+	if err != nil {
+		return "", &doctorError{err.Error(), "Check the sound system is running."}
+	}
+	if host.DefaultOutputDevice == nil || host.DefaultInputDevice == nil {
+		return "", &doctorError{
+			fmt.Sprintf("%s has no default input or output device", host.Name),
+			"Connect a sound card or create a virtual one (e.g. BlackHole, snd-aloop).",
+		}
+	}
+
+	buf := make([]uint8, dataChunkLength)
+	out := portaudio.LowLatencyParameters(nil, host.DefaultOutputDevice)
+	out.Output.Channels = 1
+	out.SampleRate = 7820
+	if err := portaudio.IsFormatSupported(out, &buf); err != nil {
+		return "", &doctorError{
+			fmt.Sprintf("%s: %s at 7820 Hz", host.DefaultOutputDevice.Name, err),
+			"Use a device which resamples, e.g. through the PulseAudio or PipeWire ALSA plugin.",
+		}
+	}
+	in := portaudio.LowLatencyParameters(host.DefaultInputDevice, nil)
+	in.Input.Channels = 1
+	in.SampleRate = 11520
+	if err := portaudio.IsFormatSupported(in, &buf); err != nil {
+		return "", &doctorError{
+			fmt.Sprintf("%s: %s at 11520 Hz", host.DefaultInputDevice.Name, err),
+			"Use a device which resamples, e.g. through the PulseAudio or PipeWire ALSA plugin.",
+		}
+	}
+
+	return fmt.Sprintf("%s, %s out, %s in", host.Name, host.DefaultOutputDevice.Name, host.DefaultInputDevice.Name), nil
+}
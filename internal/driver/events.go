@@ -0,0 +1,147 @@
+package driver
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Event is something which has happened in the driver, one of the types
+// below.
+type Event interface {
+	String() string
+}
+
+// FrequencyChanged, ModeChanged and PttChanged come from the CAT traffic,
+// as tracked by RigState.
+type FrequencyChanged struct {
+	Frequency int64
+}
+
+type ModeChanged struct {
+	Mode byte
+}
+
+type PttChanged struct {
+	Transmitting bool
+}
+
+// KeyerChanged is the CW keyer of the rig set, by KS or PT, 0 is unknown.
+type KeyerChanged struct {
+	Wpm  int
+	Tone int // Hz
+}
+
+// ChunkDropped is audio lost because the receiving side didn't keep up,
+// "rx" for the audio from the rig and "tx" for the audio to it.
+type ChunkDropped struct {
+	Direction string
+}
+
+// AudioStalled is the audio stream of the rig stopped while receiving.
+type AudioStalled struct {
+	Silence time.Duration
+}
+
+// RigUnresponsive is the rig not answering CAT queries anymore.
+type RigUnresponsive struct{}
+
+// IdleChanged is the driver gone idle because no CAT client is attached,
+// or woken up by one.
+type IdleChanged struct {
+	Idle bool
+}
+
+// ConnectionChanged is the serial port of the rig lost or released, and
+// taken over again.
+type ConnectionChanged struct {
+	Port      string
+	Connected bool
+}
+
+func (e FrequencyChanged) String() string {
+	return fmt.Sprintf("frequency %d Hz", e.Frequency)
+}
+
+func (e ModeChanged) String() string {
+	return "mode " + modeNames[e.Mode]
+}
+
+func (e PttChanged) String() string {
+	if e.Transmitting {
+		return "TX"
+	}
+	return "RX"
+}
+
+func (e KeyerChanged) String() string {
+	return fmt.Sprintf("keyer %d WPM %d Hz", e.Wpm, e.Tone)
+}
+
+func (e ChunkDropped) String() string {
+	return e.Direction + " audio chunk dropped"
+}
+
+func (e AudioStalled) String() string {
+	return fmt.Sprintf("no audio for %s", e.Silence.Round(time.Millisecond))
+}
+
+func (e RigUnresponsive) String() string {
+	return "rig unresponsive"
+}
+
+func (e IdleChanged) String() string {
+	if e.Idle {
+		return "idle"
+	}
+	return "awake"
+}
+
+func (e ConnectionChanged) String() string {
+	if e.Connected {
+		return e.Port + " connected"
+	}
+	return e.Port + " disconnected"
+}
+
+// EventBus delivers events to the subscribers, synchronously in the
+// goroutine of the publisher, so they have to be quick. A nil bus drops
+// everything.
+type EventBus struct {
+	mu       sync.Mutex
+	handlers []func(Event)
+}
+
+func NewEventBus() *EventBus {
+	eb := new(EventBus)
+	eb.Subscribe(func(event Event) {
+		if _, isDrop := event.(ChunkDropped); !isDrop {
+			log.Debugf("[Event]: %s\n", event)
+		}
+	})
+
+	return eb
+}
+
+func (eb *EventBus) Subscribe(fn func(Event)) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.handlers = append(eb.handlers, fn)
+}
+
+func (eb *EventBus) Publish(event Event) {
+	if eb == nil {
+		return
+	}
+
+	eb.mu.Lock()
+	handlers := eb.handlers
+	eb.mu.Unlock()
+
+	for _, fn := range handlers {
+		fn(event)
+	}
+}
@@ -0,0 +1,107 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type extCommand struct {
+	name     string
+	prefix   string // the value follows it, zero padded to digits
+	digits   int
+	readOnly bool
+	help     string
+}
+
+// Rig settings which are reachable over CAT, but otherwise buried in the menu.
+var extCommands = []extCommand{
+	{name: "volume", prefix: "AG0", digits: 3, help: "AF volume"},
+	{name: "drive", prefix: "PC", digits: 3, help: "TX drive level"},
+	{name: "filter", prefix: "FW", digits: 4, help: "receive filter bandwidth in Hz"},
+	{name: "agc", prefix: "GT", digits: 3, help: "AGC time constant, 0 is off"},
+	{name: "nr", prefix: "NR", digits: 1, help: "noise reduction level"},
+	{name: "att", prefix: "RA", digits: 2, help: "attenuator"},
+	{name: "vox", prefix: "VX", digits: 1, help: "VOX, 0 is off and 1 is on"},
+	{name: "cw-speed", prefix: "KS", digits: 3, help: "keyer speed in WPM"},
+	{name: "cw-tone", prefix: "PT", digits: 2, help: "CW tone"},
+	{name: "smeter", prefix: "SM0", digits: 4, readOnly: true, help: "S-meter reading"},
+}
+
+func findExtCommand(name string) (extCommand, bool) {
+	for _, ext := range extCommands {
+		if ext.name == name {
+			return ext, true
+		}
+	}
+
+	return extCommand{}, false
+}
+
+func (ss *SerialStream) GetExtended(name string) (int, error) {
+	ext, ok := findExtCommand(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown setting %q", name)
+	}
+
+	reply, err := ss.Query(ext.prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := strconv.Atoi(strings.TrimPrefix(string(reply), ext.prefix))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected reply %q", reply)
+	}
+
+	return value, nil
+}
+
+func (ss *SerialStream) SetExtended(name string, value int) error {
+	ext, ok := findExtCommand(name)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", name)
+	}
+	if ext.readOnly {
+		return fmt.Errorf("%s is read only", name)
+	}
+
+	text := fmt.Sprintf("%0*d", ext.digits, value)
+	if value < 0 || len(text) > ext.digits {
+		return fmt.Errorf("%d is out of range for %s", value, name)
+	}
+
+	ss.PushCommand(ext.prefix + text)
+
+	return nil
+}
+
+// ext                 lists the settings
+// ext <name>          reads a setting
+// ext <name> <value>  changes a setting
+func registerExtCommands(cs *ControlServer, ss *SerialStream) {
+	cs.Handle("ext", func(args []string) (string, error) {
+		switch len(args) {
+		case 0:
+			var output strings.Builder
+			for _, ext := range extCommands {
+				fmt.Fprintf(&output, "%-10s %s\n", ext.name, ext.help)
+			}
+			return output.String(), nil
+		case 1:
+			value, err := ss.GetExtended(args[0])
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d\n", value), nil
+		case 2:
+			value, err := strconv.Atoi(args[1])
+			if err != nil {
+				return "", fmt.Errorf("invalid value %q", args[1])
+			}
+			return "", ss.SetExtended(args[0], value)
+		}
+
+		return "", errUsage
+	})
+}
@@ -0,0 +1,89 @@
+package driver
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// makeFifo creates the named pipe, unless it exists already.
+func makeFifo(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return unix.Mkfifo(path, 0o660)
+	} else if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeNamedPipe == 0 {
+		return errors.New(path + " exists and isn't a named pipe")
+	}
+
+	return nil
+}
+
+// startRxFifoFromEnv writes the audio of the rig to the named pipe at
+// RX_AUDIO_FIFO, raw 8-bit unsigned mono at the RX sample rate, whenever a
+// program reads it.
+func startRxFifoFromEnv(ss *SerialStream) {
+	path, ok := os.LookupEnv("RX_AUDIO_FIFO")
+	if !ok || path == "" {
+		return
+	}
+	if err := makeFifo(path); err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("RX audio to %s\n", path)
+
+	go func() {
+		for isRunning() {
+			// blocks until there is a reader
+			file, err := os.OpenFile(path, os.O_WRONLY, 0)
+			if err != nil {
+				log.Errorln(err)
+				return
+			}
+			log.Infof("RX audio reader of %s connected\n", path)
+			sink := ss.RxAudio.Add("fifo", cap(ss.AudioOutBuf))
+			for samples := range sink.C {
+				if _, err := file.Write(samples); err != nil {
+					break
+				}
+			}
+			ss.RxAudio.Remove(sink)
+			file.Close()
+			log.Infof("RX audio reader of %s disconnected\n", path)
+		}
+	}()
+}
+
+// readFrom takes the audio from r until it ends.
+func (nt *NetworkTx) readFrom(r io.Reader, buffer []byte) {
+	for {
+		n, err := io.ReadFull(r, buffer)
+		nt.write(buffer[:n])
+		if err != nil {
+			return
+		}
+	}
+}
+
+// serveFifo takes the audio from the named pipe, one writer after another.
+func (nt *NetworkTx) serveFifo(path string) {
+	buffer := make([]byte, networkTxChunk)
+	for isRunning() {
+		// blocks until there is a writer
+		file, err := os.Open(path)
+		if err != nil {
+			log.Errorln(err)
+			return
+		}
+		log.Infof("TX audio writer of %s connected\n", path)
+		nt.readFrom(file, buffer)
+		file.Close()
+		log.Infof("TX audio writer of %s disconnected\n", path)
+	}
+}
@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func registerSuspendCommands(cs *ControlServer, ss *SerialStream) {
+	cs.Handle("suspend", func(args []string) (string, error) {
+		ss.Suspend()
+		return ss.PortName() + "\n", nil
+	})
+	cs.Handle("resume", func(args []string) (string, error) {
+		return "", ss.Resume()
+	})
+	cs.Handle("passthrough", func(args []string) (string, error) {
+		if len(args) == 0 {
+			if ss.passthrough.Load() {
+				return "on\n", nil
+			}
+			return "off\n", nil
+		}
+
+		switch args[0] {
+		case "on":
+			ss.SetPassthrough(true)
+		case "off":
+			ss.SetPassthrough(false)
+		default:
+			return "", errUsage
+		}
+
+		return "", nil
+	})
+}
+
+// runFirmwareUpdate releases the serial port held by the driver, runs the
+// uploader with {port} replaced by the serial port path and resumes the
+// driver afterwards, e.g.:
+//
+//	trusdx-go firmware-update avrdude -p m328p -c arduino -P {port} -U flash:w:firmware.hex
+func runFirmwareUpdate(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	output, err := controlRequest([]string{"suspend"})
+	if err != nil {
+		return err
+	}
+	port := strings.TrimSpace(output)
+
+	uploaderArgs := make([]string, len(args))
+	for i, arg := range args {
+		uploaderArgs[i] = strings.ReplaceAll(arg, "{port}", port)
+	}
+
+	uploader := exec.Command(uploaderArgs[0], uploaderArgs[1:]...)
+	uploader.Stdin = os.Stdin
+	uploader.Stdout = os.Stdout
+	uploader.Stderr = os.Stderr
+	uploadErr := uploader.Run()
+	if uploadErr != nil {
+		uploadErr = fmt.Errorf("uploader failed: %w", uploadErr)
+	}
+
+	if _, err := controlRequest([]string{"resume"}); err != nil {
+		return errors.Join(uploadErr, fmt.Errorf("resuming the driver failed: %w", err))
+	}
+
+	return uploadErr
+}
@@ -0,0 +1,151 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The properties of the rig by the names of get and set, next to the
+// extended settings. Reading asks the rig, not the tracked state.
+var getSetProperties = []struct {
+	name string
+	help string
+}{
+	{"freq", "VFO frequency in Hz"},
+	{"mode", "LSB, USB, CW, FM, AM..."},
+	{"band", "the band of the VFO, setting it goes to its FT8 frequency"},
+	{"ptt", "on or off"},
+}
+
+func getProperty(ss *SerialStream, name string) (string, error) {
+	switch name {
+	case "freq":
+		reply, err := ss.Query("FA")
+		if err != nil {
+			return "", err
+		}
+		frequency, err := strconv.ParseInt(strings.TrimPrefix(string(reply), "FA"), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("unexpected reply %q", reply)
+		}
+		return strconv.FormatInt(frequency, 10), nil
+	case "mode":
+		reply, err := ss.Query("MD")
+		if err != nil {
+			return "", err
+		}
+		if len(reply) != 3 {
+			return "", fmt.Errorf("unexpected reply %q", reply)
+		}
+		mode, ok := modeNames[reply[2]]
+		if !ok {
+			return "", fmt.Errorf("unknown mode in %q", reply)
+		}
+		return mode, nil
+	case "band":
+		band, ok := findBand(ss.State.Frequency())
+		if !ok {
+			return "", fmt.Errorf("%d Hz is out of the bands", ss.State.Frequency())
+		}
+		return band.name, nil
+	case "ptt":
+		if ss.State.Transmitting() {
+			return "on", nil
+		}
+		return "off", nil
+	}
+
+	value, err := ss.GetExtended(name)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.Itoa(value), nil
+}
+
+func setProperty(ss *SerialStream, name, value string) error {
+	switch name {
+	case "freq":
+		frequency, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || frequency <= 0 {
+			return fmt.Errorf("invalid frequency %q, it's in Hz", value)
+		}
+		ss.PushCommand(fmt.Sprintf("FA%011d", frequency))
+		return nil
+	case "mode":
+		mode, ok := parseModeName(value)
+		if !ok {
+			return fmt.Errorf("unknown mode %q", value)
+		}
+		ss.PushCommand(fmt.Sprintf("MD%c", mode))
+		return nil
+	case "band":
+		for _, b := range hamBands {
+			if strings.EqualFold(b.name, value) {
+				ss.PushCommand(fmt.Sprintf("FA%011d", b.home))
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown band %q", value)
+	case "ptt":
+		switch strings.ToLower(value) {
+		case "on", "tx", "1":
+			ss.PushCommand("TX")
+		case "off", "rx", "0":
+			ss.PushCommand("RX")
+		default:
+			return fmt.Errorf("invalid ptt %q, it's on or off", value)
+		}
+		return nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid value of %s", name)
+	}
+
+	return ss.SetExtended(name, number)
+}
+
+// get                    lists the properties
+// get <name> ...         prints their values, one per line
+// set <name> <value> ... changes them, in order
+func registerGetSetCommands(cs *ControlServer, ss *SerialStream) {
+	cs.Handle("get", func(args []string) (string, error) {
+		var sb strings.Builder
+		if len(args) == 0 {
+			for _, property := range getSetProperties {
+				fmt.Fprintf(&sb, "%-10s %s\n", property.name, property.help)
+			}
+			for _, ext := range extCommands {
+				fmt.Fprintf(&sb, "%-10s %s\n", ext.name, ext.help)
+			}
+			return sb.String(), nil
+		}
+
+		for _, name := range args {
+			value, err := getProperty(ss, name)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintln(&sb, value)
+		}
+
+		return sb.String(), nil
+	})
+
+	cs.Handle("set", func(args []string) (string, error) {
+		if len(args) == 0 || len(args)%2 != 0 {
+			return "", errUsage
+		}
+
+		for i := 0; i < len(args); i += 2 {
+			if err := setProperty(ss, args[i], args[i+1]); err != nil {
+				return "", err
+			}
+		}
+
+		return "", nil
+	})
+}
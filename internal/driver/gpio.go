@@ -0,0 +1,77 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const gpioSysfs = "/sys/class/gpio"
+
+// GpioOutput is a GPIO pin driven through the sysfs interface, e.g. on a
+// Raspberry Pi. Active low pins are written as "!17".
+type GpioOutput struct {
+	pin       int
+	activeLow bool
+	value     *os.File
+}
+
+func NewGpioOutput(spec string) (*GpioOutput, error) {
+	activeLow := strings.HasPrefix(spec, "!")
+	pin, err := strconv.Atoi(strings.TrimPrefix(spec, "!"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid GPIO pin %q", spec)
+	}
+
+	dir := filepath.Join(gpioSysfs, fmt.Sprintf("gpio%d", pin))
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(filepath.Join(gpioSysfs, "export"), []byte(strconv.Itoa(pin)), 0); err != nil {
+			return nil, fmt.Errorf("GPIO %d: %w", pin, err)
+		}
+	}
+
+	// "low" and "high" set the direction together with the initial level,
+	// the pin starts released without a glitch
+	direction := "low"
+	if activeLow {
+		direction = "high"
+	}
+	// udev needs a moment to fix the permissions of a freshly exported pin
+	for attempt := 0; ; attempt++ {
+		err = os.WriteFile(filepath.Join(dir, "direction"), []byte(direction), 0)
+		if err == nil || attempt == 10 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GPIO %d: %w", pin, err)
+	}
+
+	value, err := os.OpenFile(filepath.Join(dir, "value"), os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("GPIO %d: %w", pin, err)
+	}
+
+	return &GpioOutput{pin: pin, activeLow: activeLow, value: value}, nil
+}
+
+func (g *GpioOutput) Set(on bool) error {
+	level := "0"
+	if on != g.activeLow {
+		level = "1"
+	}
+	if _, err := g.value.WriteAt([]byte(level), 0); err != nil {
+		return fmt.Errorf("GPIO %d: %w", g.pin, err)
+	}
+
+	return nil
+}
+
+func (g *GpioOutput) Close() error {
+	return g.value.Close()
+}
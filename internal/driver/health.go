@@ -0,0 +1,21 @@
+package driver
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// serveHealth answers 200 while the driver has the serial port of the rig
+// and 503 while it's waiting for it, for the health checks of containers
+// and monitoring.
+func serveHealth(ss *SerialStream) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if !ss.IsRunning() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "down %s\n", ss.PortName())
+			return
+		}
+		fmt.Fprintf(w, "ok %s\n%s\n", ss.PortName(), ss.Stats.String())
+	}
+}
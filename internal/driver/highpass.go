@@ -0,0 +1,51 @@
+package driver
+
+import (
+	"math"
+)
+
+// Biquad is a second order IIR filter, in the form of the RBJ audio EQ
+// cookbook.
+type Biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+// NewHighPass is a Butterworth high-pass, with a gentle knee at the cutoff.
+func NewHighPass(cutoff, sampleRate float64) *Biquad {
+	w := 2 * math.Pi * cutoff / sampleRate
+	// a Q of 1/√2
+	alpha := math.Sin(w) / math.Sqrt2
+	cos := math.Cos(w)
+	a0 := 1 + alpha
+
+	return &Biquad{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+// newHighPassFromEnv returns nil unless TX_HIGHPASS is set, in Hz.
+func newHighPassFromEnv() *Biquad {
+	return newTxHighPass(envInt("TX_HIGHPASS", 0))
+}
+
+// newTxHighPass returns nil when the cutoff isn't positive.
+func newTxHighPass(cutoff int) *Biquad {
+	if cutoff <= 0 {
+		return nil
+	}
+
+	return NewHighPass(float64(cutoff), 11520)
+}
+
+func (bq *Biquad) Process(x float64) float64 {
+	y := bq.b0*x + bq.b1*bq.x1 + bq.b2*bq.x2 - bq.a1*bq.y1 - bq.a2*bq.y2
+	bq.x2, bq.x1 = bq.x1, x
+	bq.y2, bq.y1 = bq.y1, y
+
+	return y
+}
@@ -0,0 +1,290 @@
+package driver
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var historyHeader = []string{"time", "frequency", "mode", "band"}
+
+// historyEntry is the rig settled on a frequency and a mode, the times are
+// in UTC like in the logs.
+type historyEntry struct {
+	Time      time.Time `json:"time"`
+	Frequency int64     `json:"freq"`
+	Mode      string    `json:"mode"`
+	Band      string    `json:"band"`
+}
+
+// History appends the frequency and the mode of the rig to the CSV file in
+// HISTORY_FILE whenever they change, once they have held for
+// HISTORY_SETTLE, so a turn of the dial is a single entry.
+type History struct {
+	ss     *SerialStream
+	settle time.Duration
+	mu     sync.Mutex
+	file   *os.File
+	writer *csv.Writer
+	timer  *time.Timer
+	last   historyEntry
+}
+
+// startHistoryFromEnv returns nil when HISTORY_FILE isn't set.
+func startHistoryFromEnv(ss *SerialStream) *History {
+	path := os.Getenv("HISTORY_FILE")
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	h := &History{ss: ss, settle: envDuration("HISTORY_SETTLE", 2*time.Second), file: file, writer: csv.NewWriter(file)}
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		h.writer.Write(historyHeader)
+		h.writer.Flush()
+	}
+	ss.Events.Subscribe(h.onEvent)
+
+	return h
+}
+
+func (h *History) onEvent(event Event) {
+	switch event.(type) {
+	case FrequencyChanged, ModeChanged:
+	default:
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.settle <= 0 {
+		h.recordLocked()
+	} else if h.timer == nil {
+		h.timer = time.AfterFunc(h.settle, h.record)
+	} else {
+		h.timer.Reset(h.settle)
+	}
+}
+
+func (h *History) record() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.recordLocked()
+}
+
+// recordLocked must be called with the lock held.
+func (h *History) recordLocked() {
+	if h.writer == nil {
+		return
+	}
+	frequency := h.ss.State.Frequency()
+	mode := modeNames[h.ss.State.Mode()]
+	if frequency == 0 || (frequency == h.last.Frequency && mode == h.last.Mode) {
+		return
+	}
+	band, _ := findBand(frequency)
+	h.last = historyEntry{time.Now().UTC(), frequency, mode, band.name}
+
+	h.writer.Write([]string{h.last.Time.Format(time.RFC3339), strconv.FormatInt(frequency, 10), mode, band.name})
+	h.writer.Flush()
+	if err := h.writer.Error(); err != nil {
+		log.Warnf("History: %s\n", err)
+	}
+}
+
+func (h *History) Close() {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	// the last change counts even if it hasn't settled yet
+	h.recordLocked()
+	h.writer = nil
+	h.file.Close()
+}
+
+// historyQuery selects the entries, the zero values match everything.
+type historyQuery struct {
+	from, to   time.Time
+	band, mode string
+}
+
+// parseHistoryTime takes a time in RFC 3339, a date and a time, a date,
+// in UTC, or a duration back from now, e.g. 2h.
+func parseHistoryTime(text string) (time.Time, error) {
+	if d, err := time.ParseDuration(text); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q", text)
+}
+
+func (q *historyQuery) set(name, value string) error {
+	var err error
+	switch name {
+	case "from":
+		q.from, err = parseHistoryTime(value)
+	case "to":
+		q.to, err = parseHistoryTime(value)
+	case "band":
+		q.band = value
+	case "mode":
+		q.mode = value
+	default:
+		return errUsage
+	}
+
+	return err
+}
+
+func (q *historyQuery) matches(entry historyEntry) bool {
+	return (q.from.IsZero() || !entry.Time.Before(q.from)) &&
+		(q.to.IsZero() || entry.Time.Before(q.to)) &&
+		(q.band == "" || strings.EqualFold(q.band, entry.Band)) &&
+		(q.mode == "" || strings.EqualFold(q.mode, entry.Mode))
+}
+
+// readHistory returns the entries of the file matching the query, the
+// oldest first.
+func readHistory(path string, q historyQuery) ([]historyEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = len(historyHeader)
+	var entries []historyEntry
+	for lineNo := 1; ; lineNo++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if lineNo == 1 && record[0] == historyHeader[0] {
+			continue
+		}
+
+		at, err := time.Parse(time.RFC3339, record[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid time %q", path, lineNo, record[0])
+		}
+		frequency, err := strconv.ParseInt(record[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid frequency %q", path, lineNo, record[1])
+		}
+		entry := historyEntry{at, frequency, record[2], record[3]}
+		if q.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+}
+
+// serveHistory answers with the entries as JSON, selected by the from, to,
+// band and mode parameters.
+func serveHistory(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var q historyQuery
+		for name, values := range r.URL.Query() {
+			if err := q.set(name, values[0]); err != nil {
+				http.Error(w, fmt.Sprintf("invalid %s", name), http.StatusBadRequest)
+				return
+			}
+		}
+
+		entries, err := readHistory(path, q)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if entries == nil {
+			entries = []historyEntry{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	}
+}
+
+// runHistory prints the entries of HISTORY_FILE, the file is read directly
+// so it works without the driver running.
+func runHistory(args []string) error {
+	path := os.Getenv("HISTORY_FILE")
+	if path == "" {
+		return errors.New("HISTORY_FILE isn't set")
+	}
+
+	var q historyQuery
+	format := "table"
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return errUsage
+		}
+		if name == "format" {
+			format = value
+			continue
+		}
+		if err := q.set(name, value); err != nil {
+			return err
+		}
+	}
+
+	entries, err := readHistory(path, q)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "table":
+		out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for i, entry := range entries {
+			// how long the rig stayed there, as far as the file tells
+			held := ""
+			if i+1 < len(entries) {
+				held = entries[i+1].Time.Sub(entry.Time).String()
+			}
+			fmt.Fprintf(out, "%s\t%d Hz\t%s\t%s\t%s\n",
+				entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Frequency, entry.Mode, entry.Band, held)
+		}
+		return out.Flush()
+	case "csv":
+		writer := csv.NewWriter(os.Stdout)
+		writer.Write(historyHeader)
+		for _, entry := range entries {
+			writer.Write([]string{entry.Time.Format(time.RFC3339), strconv.FormatInt(entry.Frequency, 10), entry.Mode, entry.Band})
+		}
+		writer.Flush()
+		return writer.Error()
+	case "json":
+		return json.NewEncoder(os.Stdout).Encode(entries)
+	}
+
+	return fmt.Errorf("invalid format %q", format)
+}
@@ -0,0 +1,120 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Environment variables naming the shell commands run on the events.
+var hookVariables = map[string]string{
+	"tx":           "HOOK_TX",
+	"rx":           "HOOK_RX",
+	"frequency":    "HOOK_FREQUENCY",
+	"mode":         "HOOK_MODE",
+	"band":         "HOOK_BAND",
+	"stall":        "HOOK_STALL",
+	"unresponsive": "HOOK_UNRESPONSIVE",
+	"disconnect":   "HOOK_DISCONNECT",
+	"reconnect":    "HOOK_RECONNECT",
+}
+
+// Hooks runs user commands on events, e.g. to switch antennas or to show
+// a notification. They get the event and the state of the rig in TRUSDX_*
+// environment variables.
+type Hooks struct {
+	ss       *SerialStream
+	commands map[string]string
+	mu       sync.Mutex
+	band     string
+}
+
+func NewHooks(ss *SerialStream) *Hooks {
+	h := &Hooks{ss: ss, commands: make(map[string]string)}
+	for event, name := range hookVariables {
+		if command, ok := os.LookupEnv(name); ok && command != "" {
+			h.commands[event] = command
+		}
+	}
+
+	return h
+}
+
+func (h *Hooks) Start() {
+	if len(h.commands) == 0 {
+		return
+	}
+
+	h.ss.Events.Subscribe(h.onEvent)
+}
+
+func (h *Hooks) onEvent(event Event) {
+	switch e := event.(type) {
+	case FrequencyChanged:
+		h.run("frequency")
+		band, _ := findBand(e.Frequency)
+		h.mu.Lock()
+		isNewBand := band.name != h.band
+		h.band = band.name
+		h.mu.Unlock()
+		if isNewBand {
+			h.run("band")
+		}
+	case ModeChanged:
+		h.run("mode")
+	case PttChanged:
+		if e.Transmitting {
+			h.run("tx")
+		} else {
+			h.run("rx")
+		}
+	case AudioStalled:
+		h.run("stall")
+	case RigUnresponsive:
+		h.run("unresponsive")
+	case ConnectionChanged:
+		if e.Connected {
+			h.run("reconnect")
+		} else {
+			h.run("disconnect")
+		}
+	}
+}
+
+// run starts the command of the event in the background.
+func (h *Hooks) run(event string) {
+	command, ok := h.commands[event]
+	if !ok {
+		return
+	}
+
+	band, _ := findBand(h.ss.State.Frequency())
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"TRUSDX_EVENT="+event,
+		fmt.Sprintf("TRUSDX_FREQUENCY=%d", h.ss.State.Frequency()),
+		"TRUSDX_MODE="+modeNames[h.ss.State.Mode()],
+		"TRUSDX_BAND="+band.name,
+		fmt.Sprintf("TRUSDX_TX=%t", h.ss.State.Transmitting()),
+	)
+	cmd.Stdout = os.Stdout
+	if driverMode() == driverModePipe {
+		// stdout carries the audio
+		cmd.Stdout = os.Stderr
+	}
+	cmd.Stderr = os.Stderr
+
+	log.Debugf("[Hook %s]: %s\n", event, command)
+	if err := cmd.Start(); err != nil {
+		log.Warnf("Hook %s: %s\n", event, err)
+		return
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			log.Warnf("Hook %s: %s\n", event, err)
+		}
+	}()
+}
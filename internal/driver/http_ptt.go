@@ -0,0 +1,102 @@
+package driver
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HttpPtt keys the rig on POST /ptt/on, /ptt/off and /ptt/toggle, for
+// Stream Deck buttons and phone shortcuts. A forgotten key is released
+// after the timeout.
+type HttpPtt struct {
+	ss       *SerialStream
+	token    string
+	timeout  time.Duration
+	mu       sync.Mutex
+	releaser *time.Timer
+	keyings  uint64 // a timer of an earlier keying doesn't release a new one
+}
+
+func NewHttpPtt(ss *SerialStream, token string, timeout time.Duration) *HttpPtt {
+	return &HttpPtt{ss: ss, token: token, timeout: timeout}
+}
+
+// hasToken takes the token from an "Authorization: Bearer" header or the
+// token parameter, browsers can't set headers on WebSockets.
+func hasToken(r *http.Request, expected string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.FormValue("token")
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+func (hp *HttpPtt) key(isOn bool) {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+
+	hp.keyings++
+	if hp.releaser != nil {
+		hp.releaser.Stop()
+		hp.releaser = nil
+	}
+	if !isOn {
+		hp.ss.PushCommand("RX")
+		return
+	}
+	hp.ss.PushCommand("TX")
+	if hp.timeout > 0 {
+		// Stop doesn't help once the timer has fired and waits for the lock
+		keying := hp.keyings
+		hp.releaser = time.AfterFunc(hp.timeout, func() {
+			hp.mu.Lock()
+			defer hp.mu.Unlock()
+			if hp.keyings != keying {
+				return
+			}
+			log.Warnf("HTTP PTT on for %s, releasing it\n", hp.timeout)
+			hp.releaser = nil
+			hp.ss.PushCommand("RX")
+		})
+	}
+}
+
+func (hp *HttpPtt) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !hasToken(r, hp.token) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var isOn bool
+	switch strings.TrimPrefix(r.URL.Path, "/ptt/") {
+	case "on":
+		isOn = true
+	case "off":
+		isOn = false
+	case "toggle":
+		isOn = !hp.ss.IsTransmitting()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	hp.key(isOn)
+
+	// the rig follows shortly
+	w.Header().Set("Content-Type", "text/plain")
+	if isOn {
+		fmt.Fprintln(w, "tx")
+	} else {
+		fmt.Fprintln(w, "rx")
+	}
+}
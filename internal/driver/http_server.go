@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HttpServer serves the web endpoints of the driver on HTTP_LISTEN. A nil
+// HttpServer takes the handlers and does nothing.
+type HttpServer struct {
+	mux    *http.ServeMux
+	server *http.Server
+	access *AccessList
+	// the endpoints in HTTP_DISABLE, by their paths without the slashes
+	disabled map[string]bool
+}
+
+func newHttpServerFromEnv() *HttpServer {
+	address, ok := os.LookupEnv("HTTP_LISTEN")
+	if !ok || address == "" {
+		return nil
+	}
+
+	hs := &HttpServer{mux: http.NewServeMux(), access: newAccessListFromEnv("HTTP")}
+	hs.server = &http.Server{Addr: address, Handler: hs.mux}
+	hs.disabled = make(map[string]bool)
+	for _, name := range strings.Split(os.Getenv("HTTP_DISABLE"), ",") {
+		hs.disabled[strings.Trim(strings.TrimSpace(name), "/")] = true
+	}
+
+	return hs
+}
+
+func (hs *HttpServer) Handle(pattern string, handler http.HandlerFunc) {
+	if hs == nil || hs.disabled[strings.Trim(pattern, "/")] {
+		return
+	}
+
+	hs.mux.HandleFunc(pattern, handler)
+}
+
+func (hs *HttpServer) Start() error {
+	if hs == nil {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", hs.server.Addr)
+	if err != nil {
+		return err
+	}
+	listener = hs.access.Listener(listener)
+	log.Printf("HTTP server: http://%s/\n", listener.Addr())
+	go func() {
+		if err := hs.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorln(err)
+		}
+	}()
+
+	return nil
+}
+
+func (hs *HttpServer) Close() {
+	if hs == nil {
+		return
+	}
+
+	hs.server.Close()
+}
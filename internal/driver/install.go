@@ -0,0 +1,181 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/leshniak/trusdx-go/internal/config"
+)
+
+const launchdLabel = "io.github.leshniak.trusdx-go"
+
+var launchdPlist = template.Must(template.New("plist").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{.Label}}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{.Executable}}</string>
+	</array>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>TRUSDX_CONFIG</key>
+		<string>{{.Config}}</string>
+	</dict>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>ProcessType</key>
+	<string>Interactive</string>
+	<key>StandardOutPath</key>
+	<string>{{.Log}}</string>
+	<key>StandardErrorPath</key>
+	<string>{{.Log}}</string>
+</dict>
+</plist>
+`))
+
+// systemdQuote quotes a value of a unit file, spaces would split it, and
+// escapes the specifiers systemd would expand.
+func systemdQuote(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%").Replace(value)
+
+	return `"` + value + `"`
+}
+
+var systemdUnit = template.Must(template.New("unit").Funcs(template.FuncMap{"quote": systemdQuote}).Parse(`[Unit]
+Description=truSDX driver
+After=sound.target
+
+[Service]
+ExecStart={{quote .Executable}}
+Environment={{quote (printf "TRUSDX_CONFIG=%s" .Config)}}
+Restart=always
+RestartSec=5
+
+[Install]
+WantedBy=default.target
+`))
+
+type serviceParams struct {
+	Label      string
+	Executable string
+	Config     string
+	Log        string
+}
+
+func runInstall(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "--launchd":
+		return installLaunchd()
+	case "--systemd":
+		return installSystemd()
+	default:
+		return errUsage
+	}
+}
+
+// writeService writes the service file from the template.
+func writeService(path string, tmpl *template.Template, params serviceParams) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(file, params); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// installSystemd installs the driver as a systemd user service, started on
+// login and restarted when it exits.
+func installSystemd() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	configPath, err := filepath.Abs(config.FilePath())
+	if err != nil {
+		return err
+	}
+
+	params := serviceParams{
+		Executable: executable,
+		Config:     configPath,
+	}
+
+	path := filepath.Join(configDir, "systemd", "user", "trusdx-go.service")
+	if err := writeService(path, systemdUnit, params); err != nil {
+		return err
+	}
+	fmt.Printf("Installed %s, configured by %s\n", path, configPath)
+
+	for _, args := range [][]string{{"daemon-reload"}, {"enable", "--now", "trusdx-go.service"}, {"restart", "trusdx-go.service"}} {
+		cmd := exec.Command("systemctl", append([]string{"--user"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return errors.Join(err, errors.New(string(output)))
+		}
+	}
+	fmt.Println("To keep it running while logged out: loginctl enable-linger")
+
+	return nil
+}
+
+// installLaunchd installs the driver as a launchd user agent, started on
+// login and restarted when it exits.
+func installLaunchd() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	configPath, err := filepath.Abs(config.FilePath())
+	if err != nil {
+		return err
+	}
+
+	params := serviceParams{
+		Label:      launchdLabel,
+		Executable: executable,
+		Config:     configPath,
+		Log:        filepath.Join(home, "Library", "Logs", "trusdx-go.log"),
+	}
+
+	path := filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist")
+	if err := writeService(path, launchdPlist, params); err != nil {
+		return err
+	}
+	fmt.Printf("Installed %s, configured by %s\n", path, configPath)
+
+	// reload, in case an older version is running
+	exec.Command("launchctl", "unload", path).Run()
+	if output, err := exec.Command("launchctl", "load", "-w", path).CombinedOutput(); err != nil {
+		return errors.Join(err, errors.New(string(output)))
+	}
+
+	return nil
+}
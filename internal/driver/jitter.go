@@ -0,0 +1,77 @@
+package driver
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// JitterEstimator measures how irregularly audio chunks arrive from the rig
+// and derives how many chunks should be buffered before playing them.
+type JitterEstimator struct {
+	mu         sync.Mutex
+	lastAt     time.Time
+	interval   float64 // smoothed interval between chunks, in seconds
+	jitter     float64 // smoothed deviation from it, in seconds
+	minDepth   int
+	maxDepth   int
+	lastTarget int
+}
+
+func NewJitterEstimator(minDepth int, maxDepth int) *JitterEstimator {
+	je := new(JitterEstimator)
+	je.minDepth = minDepth
+	je.maxDepth = maxDepth
+	je.lastTarget = minDepth
+
+	return je
+}
+
+func (je *JitterEstimator) Observe() {
+	je.mu.Lock()
+	defer je.mu.Unlock()
+
+	now := time.Now()
+	if je.lastAt.IsZero() {
+		je.lastAt = now
+		return
+	}
+
+	interval := now.Sub(je.lastAt).Seconds()
+	je.lastAt = now
+	// a pause in the stream (TX, a CAT reply) isn't jitter
+	if je.interval > 0 && interval > 20*je.interval {
+		return
+	}
+
+	const gain = 1.0 / 16
+	if je.interval == 0 {
+		je.interval = interval
+	}
+	je.jitter += (math.Abs(interval-je.interval) - je.jitter) * gain
+	je.interval += (interval - je.interval) * gain
+}
+
+// Target is the number of chunks to keep queued, enough to cover a few
+// times the measured jitter.
+func (je *JitterEstimator) Target() int {
+	je.mu.Lock()
+	defer je.mu.Unlock()
+
+	target := je.minDepth
+	if je.interval > 0 {
+		target += int(math.Ceil(4 * je.jitter / je.interval))
+	}
+	if target > je.maxDepth {
+		target = je.maxDepth
+	}
+
+	if target != je.lastTarget {
+		log.Debugf("[RX Buffer]: %d chunks, jitter %.1f ms\n", target, je.jitter*1000)
+		je.lastTarget = target
+	}
+
+	return target
+}
@@ -0,0 +1,48 @@
+package driver
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// keepAlive asks the rig for its ID when it has been quiet on CAT for the
+// interval. When it doesn't answer several times in a row, the serial port
+// is opened again, which also resets the rig.
+func (ss *SerialStream) keepAlive(stop chan struct{}, interval time.Duration, failures int) {
+	if interval <= 0 || ss.transparentCat {
+		return
+	}
+
+	ss.lastReplyAt.Store(time.Now().UnixNano())
+	failed := 0
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+
+		// the rig doesn't answer while transmitting
+		if ss.link.IsTransmitting() || ss.passthrough.Load() ||
+			time.Since(time.Unix(0, ss.lastReplyAt.Load())) < interval {
+			failed = 0
+			continue
+		}
+
+		if _, err := ss.Query("ID"); err == nil {
+			failed = 0
+			continue
+		}
+		failed++
+		log.Warnf("The rig doesn't answer (%d/%d)\n", failed, failures)
+		if failed < failures {
+			continue
+		}
+
+		log.Errorf("The rig has stopped answering, opening %s again\n", ss.PortName())
+		ss.Events.Publish(RigUnresponsive{})
+		go ss.reconnect()
+		return
+	}
+}
@@ -0,0 +1,94 @@
+package driver
+
+import (
+	"math"
+	"time"
+)
+
+// Limiter keeps the peaks below the threshold without clipping them: the
+// audio is delayed by the look-ahead, so the gain can come down smoothly
+// before a peak arrives, and recovers over the release time.
+type Limiter struct {
+	threshold float64
+	attack    float64
+	release   float64
+	delay     []float64 // the look-ahead, a ring buffer
+	gains     []float64 // the gain each delayed sample needs
+	position  int
+	gain      float64
+}
+
+func NewLimiter(threshold float64, lookAhead, release time.Duration, sampleRate float64) *Limiter {
+	length := int(lookAhead.Seconds() * sampleRate)
+	if length < 1 {
+		length = 1
+	}
+	l := &Limiter{
+		threshold: threshold,
+		// most of the way down within the look-ahead
+		attack:  math.Exp(-3 / float64(length)),
+		release: math.Exp(-1 / (release.Seconds() * sampleRate)),
+		delay:   make([]float64, length),
+		gains:   make([]float64, length),
+		gain:    1,
+	}
+	for i := range l.gains {
+		l.gains[i] = 1
+	}
+
+	return l
+}
+
+// newLimiterFromEnv returns nil unless TX_LIMITER is set, in dBFS.
+func newLimiterFromEnv() *Limiter {
+	return newTxLimiter(envInt("TX_LIMITER", 0))
+}
+
+// newTxLimiter returns nil unless the threshold is below 0 dBFS.
+func newTxLimiter(threshold int) *Limiter {
+	if threshold >= 0 {
+		return nil
+	}
+
+	return NewLimiter(
+		math.Pow(10, float64(threshold)/20),
+		envDuration("TX_LIMITER_LOOKAHEAD", 2*time.Millisecond),
+		envDuration("TX_LIMITER_RELEASE", 100*time.Millisecond),
+		11520,
+	)
+}
+
+func (l *Limiter) Process(sample float64) float64 {
+	needed := 1.0
+	if peak := math.Abs(sample); peak > l.threshold {
+		needed = l.threshold / peak
+	}
+
+	delayed := l.delay[l.position]
+	l.delay[l.position] = sample
+	l.gains[l.position] = needed
+	l.position = (l.position + 1) % len(l.delay)
+
+	// the lowest gain needed by anything in the look-ahead
+	target := 1.0
+	for _, gain := range l.gains {
+		if gain < target {
+			target = gain
+		}
+	}
+	if target < l.gain {
+		l.gain = target + (l.gain-target)*l.attack
+	} else {
+		l.gain = target + (l.gain-target)*l.release
+	}
+
+	output := delayed * l.gain
+	// the attack may not have made it all the way down
+	if output > l.threshold {
+		output = l.threshold
+	} else if output < -l.threshold {
+		output = -l.threshold
+	}
+
+	return output
+}
@@ -0,0 +1,125 @@
+package driver
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LinkState is where the serial link to the rig stands.
+type LinkState int32
+
+const (
+	// receiving, the rig sends CAT replies
+	StateRx LinkState = iota
+	// in the middle of an audio message from the rig
+	StateRxStream
+	// transmitting, the audio goes to the rig
+	StateTx
+	// back to receiving, until the rig answers or streams again
+	StateTxTail
+	// the serial port is lost or released
+	StateFault
+)
+
+var linkStateNames = [...]string{"RX", "RX_STREAM", "TX", "TX_TAIL", "FAULT"}
+
+func (s LinkState) String() string {
+	return linkStateNames[s]
+}
+
+// the states each state may go to
+var linkTransitions = map[LinkState][]LinkState{
+	StateRx:       {StateRxStream, StateTx, StateFault},
+	StateRxStream: {StateRx, StateTx, StateFault},
+	StateTx:       {StateTxTail, StateFault},
+	StateTxTail:   {StateRx, StateRxStream, StateTx, StateFault},
+	StateFault:    {StateRx},
+}
+
+// the rig doesn't always say when it's back from TX
+const txTailTimeout = 500 * time.Millisecond
+
+// LinkStateMachine keeps the state of the link, every transition is checked
+// against the table above and logged.
+type LinkStateMachine struct {
+	mu    sync.Mutex
+	state LinkState
+	since time.Time
+	timer *time.Timer
+}
+
+func NewLinkStateMachine() *LinkStateMachine {
+	return &LinkStateMachine{state: StateRx, since: time.Now()}
+}
+
+func (sm *LinkStateMachine) State() LinkState {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	return sm.state
+}
+
+func (sm *LinkStateMachine) Is(state LinkState) bool {
+	return sm.State() == state
+}
+
+// IsTransmitting tells whether the rig is, or may still be, transmitting,
+// when it doesn't answer.
+func (sm *LinkStateMachine) IsTransmitting() bool {
+	state := sm.State()
+	return state == StateTx || state == StateTxTail
+}
+
+// To moves to the state, it returns false when the transition isn't
+// allowed. Staying in the same state is always allowed.
+func (sm *LinkStateMachine) To(next LinkState, reason string) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	return sm.to(next, reason)
+}
+
+// Transition moves from the state to the next one, it returns false when
+// the link is in another state by now.
+func (sm *LinkStateMachine) Transition(from LinkState, next LinkState, reason string) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.state != from {
+		return false
+	}
+
+	return sm.to(next, reason)
+}
+
+func (sm *LinkStateMachine) to(next LinkState, reason string) bool {
+	if sm.state == next {
+		return true
+	}
+
+	isAllowed := false
+	for _, state := range linkTransitions[sm.state] {
+		isAllowed = isAllowed || state == next
+	}
+	if !isAllowed {
+		log.Warnf("[State]: %s -> %s refused (%s)\n", sm.state, next, reason)
+		return false
+	}
+
+	log.Debugf("[State]: %s -> %s after %s (%s)\n", sm.state, next, time.Since(sm.since).Round(time.Millisecond), reason)
+	sm.state = next
+	sm.since = time.Now()
+	if sm.timer != nil {
+		sm.timer.Stop()
+		sm.timer = nil
+	}
+	if next == StateTxTail {
+		sm.timer = time.AfterFunc(txTailTimeout, func() {
+			sm.Transition(StateTxTail, StateRx, "timeout")
+		})
+	}
+
+	return true
+}
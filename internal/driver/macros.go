@@ -0,0 +1,247 @@
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Contest macros, sent as CW or played from a voice keyer bank. The CW
+// texts are Go templates with .MyCall, .Call, .Rst and .Serial available,
+// the serial number goes up after every macro which has sent it.
+var defaultMacros = map[string]string{
+	"cq":   "cw CQ TEST {{.MyCall}} {{.MyCall}} TEST",
+	"exch": "cw {{.Call}} {{.Rst}} {{.Serial}}",
+	"tu":   "cw TU {{.MyCall}}",
+	"agn":  "cw AGN",
+}
+
+type macro struct {
+	kind string // cw or voice
+	text *template.Template
+}
+
+type Macros struct {
+	mu       sync.Mutex
+	macros   map[string]macro
+	myCall   string
+	call     string
+	rst      string
+	serial   int
+	wpm      int
+	tone     float64
+	ss       *SerialStream
+	vk       *VoiceKeyer
+	isSerial bool
+}
+
+func NewMacros(ss *SerialStream, vk *VoiceKeyer) *Macros {
+	m := &Macros{
+		macros: make(map[string]macro),
+		myCall: os.Getenv("MY_CALL"),
+		rst:    "599",
+		serial: 1,
+		wpm:    envInt("CW_WPM", 25),
+		tone:   float64(envInt("CW_TONE", 700)),
+		ss:     ss,
+		vk:     vk,
+	}
+	for name, definition := range defaultMacros {
+		m.Set(name, definition)
+	}
+	// the CW follows the keyer of the rig, e.g. when a logger changes it
+	ss.Events.Subscribe(m.onEvent)
+
+	return m
+}
+
+func (m *Macros) onEvent(event Event) {
+	e, ok := event.(KeyerChanged)
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e.Wpm > 0 {
+		m.wpm = e.Wpm
+	}
+	if e.Tone > 0 {
+		m.tone = float64(e.Tone)
+	}
+}
+
+// Set defines a macro as "cw <text>" or "voice <bank>".
+func (m *Macros) Set(name, definition string) error {
+	kind, text, _ := strings.Cut(definition, " ")
+	if kind != "cw" && kind != "voice" {
+		return fmt.Errorf("macro %s: it's either cw or voice", name)
+	}
+
+	tmpl, err := template.New(name).Parse(strings.TrimSpace(text))
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.macros[name] = macro{kind, tmpl}
+
+	return nil
+}
+
+// Load reads "<name> cw <text>" and "<name> voice <bank>" lines, empty
+// lines and lines starting with # are ignored. A name alone removes the
+// macro.
+func (m *Macros) Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, definition, _ := strings.Cut(line, " ")
+		if definition = strings.TrimSpace(definition); definition == "" {
+			m.mu.Lock()
+			delete(m.macros, name)
+			m.mu.Unlock()
+			continue
+		}
+		if err := m.Set(name, definition); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// macroArgs is what the templates see, it notices the serial number being
+// used.
+type macroArgs struct {
+	MyCall string
+	Call   string
+	Rst    string
+	m      *Macros
+}
+
+func (a macroArgs) Serial() string {
+	a.m.isSerial = true
+	return fmt.Sprintf("%03d", a.m.serial)
+}
+
+// render fills in the macro, it must be called with the lock held.
+func (m *Macros) render(name string) (macro, string, error) {
+	mac, ok := m.macros[name]
+	if !ok {
+		return mac, "", fmt.Errorf("unknown macro %q", name)
+	}
+
+	var text bytes.Buffer
+	m.isSerial = false
+	args := macroArgs{MyCall: m.myCall, Call: m.call, Rst: m.rst, m: m}
+	if err := mac.text.Execute(&text, args); err != nil {
+		return mac, "", err
+	}
+
+	return mac, strings.TrimSpace(text.String()), nil
+}
+
+// Send transmits the macro, it returns what was sent.
+func (m *Macros) Send(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mac, text, err := m.render(name)
+	if err != nil {
+		return "", err
+	}
+
+	if mac.kind == "voice" {
+		err = m.vk.Play(text)
+	} else {
+		_, err = m.ss.SendMorse(text, m.wpm, m.tone)
+	}
+	if err != nil {
+		return "", err
+	}
+	if m.isSerial {
+		m.serial++
+	}
+
+	return text, nil
+}
+
+// List describes the macros and the state of the exchange.
+func (m *Macros) List() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.macros))
+	for name := range m.macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var output strings.Builder
+	fmt.Fprintf(&output, "call=%s rst=%s serial=%d\n", m.call, m.rst, m.serial)
+	for _, name := range names {
+		fmt.Fprintf(&output, "%-6s %-5s %s\n", name, m.macros[name].kind, m.macros[name].text.Root)
+	}
+
+	return output.String()
+}
+
+// SetExchange sets call, rst or serial.
+func (m *Macros) SetExchange(name, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch name {
+	case "call":
+		m.call = strings.ToUpper(value)
+	case "rst":
+		m.rst = value
+	case "serial":
+		serial, err := strconv.Atoi(value)
+		if err != nil || serial < 1 {
+			return fmt.Errorf("invalid serial number %q", value)
+		}
+		m.serial = serial
+	default:
+		return errUsage
+	}
+
+	return nil
+}
+
+func registerMacroCommands(cs *ControlServer, m *Macros) {
+	cs.Handle("macro", func(args []string) (string, error) {
+		switch len(args) {
+		case 0:
+			return m.List(), nil
+		case 1:
+			text, err := m.Send(args[0])
+			if err != nil {
+				return "", err
+			}
+			return text + "\n", nil
+		case 2:
+			return "", m.SetExchange(args[0], args[1])
+		}
+
+		return "", errUsage
+	})
+}
@@ -0,0 +1,377 @@
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/leshniak/trusdx-go/internal/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const dataChunkLength = 48
+
+// set on shutdown, the loops of the driver stop
+var isShuttingDown atomic.Bool
+
+func isRunning() bool {
+	return !isShuttingDown.Load()
+}
+
+func sendCatToPort(port *os.File, ss *SerialStream, script *Script, offset *FrequencyOffset, session *CatSession) {
+	for isRunning() {
+		cmd := offset.ToClient(script.FilterReply(<-ss.RepliesBuf))
+		log.Debugf("[CAT %s <- Rig]: %s\n", session.Tag(), cmd)
+		if session.IsGone() {
+			continue
+		}
+		port.SetWriteDeadline(time.Now().Add(catWriteTimeout))
+		if _, err := port.Write([]byte(cmd)); errors.Is(err, os.ErrDeadlineExceeded) {
+			session.Hangup()
+		}
+	}
+}
+
+func getCatFromPort(port *os.File, ss *SerialStream, script *Script, offset *FrequencyOffset, session *CatSession) {
+	const bufferSize = 64
+	// more than this without a ';' isn't a command to wait for
+	const maxCatCommandLength = 256
+
+	// a command may come in several reads, it waits for its ';'
+	var pending []byte
+	for isRunning() {
+		buffer := make([]byte, bufferSize)
+		// don't block forever, so the loop notices the shutdown
+		port.SetReadDeadline(time.Now().Add(time.Second))
+		readCount, err := port.Read(buffer)
+		if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+			// the client has hung up
+			session.Hangup()
+			pending = nil
+			time.Sleep(100 * time.Millisecond)
+		}
+		if readCount > 0 {
+			session.Seen()
+			pending = append(pending, buffer[:readCount]...)
+			end := bytes.LastIndexByte(pending, ';') + 1
+			// the passthrough bridges bytes, which aren't CAT commands
+			if ss.passthrough.Load() || len(pending) > maxCatCommandLength {
+				end = len(pending)
+			}
+			if end == 0 {
+				continue
+			}
+			cmdString := string(pending[:end])
+			pending = append(pending[:0], pending[end:]...)
+			log.Debugf("[CAT %s -> Rig]: %s\n", session.Tag(), cmdString)
+			cmdString, rejected := offset.ToRig(cmdString)
+			cmdString, replies := script.FilterCommands(cmdString)
+			if replies = rejected + replies; replies != "" {
+				port.Write([]byte(replies))
+			}
+			if cmdString != "" {
+				ss.PushCommand(cmdString)
+			}
+		}
+	}
+}
+
+func configurePort(port *os.File) {
+	attrs, err := getTermios(port)
+	if err != nil {
+		log.Warnln(err)
+		return
+	}
+	attrs.Lflag &^= unix.ECHO | unix.ECHOE | unix.ECHOKE | unix.ECHOCTL
+	attrs.Cflag &^= unix.HUPCL
+	attrs.Ispeed = unix.B115200
+	attrs.Ospeed = unix.B115200
+	setTermios(port, attrs)
+}
+
+func setLogLevel() {
+	levelText, ok := os.LookupEnv("LOG_LEVEL")
+
+	if !ok {
+		levelText = "info"
+	}
+
+	logLevel, err := log.ParseLevel(levelText)
+	if err != nil {
+		logLevel = log.InfoLevel
+	}
+
+	log.SetLevel(logLevel)
+}
+
+func runCommand(args []string) int {
+	var err error
+
+	switch args[0] {
+	case "settings":
+		err = runSettingsCommand(args[1:])
+	case "firmware-update":
+		err = runFirmwareUpdate(args[1:])
+	case "install":
+		err = runInstall(args[1:])
+	case "setup-udev":
+		err = runSetupUdev(args[1:])
+	case "ports":
+		err = runListPorts(args[1:])
+	case "cat":
+		err = runCatCommand(args[1:])
+	case "console":
+		err = runConsole(args[1:])
+	case "panel":
+		err = runPanel(args[1:])
+	case "doctor":
+		err = runDoctor(args[1:])
+	case "history":
+		err = runHistory(args[1:])
+	case "bench":
+		setLogLevel()
+		err = runBench(args[1:])
+	case "soak":
+		setLogLevel()
+		err = runSoak(args[1:])
+	case "selftest":
+		setLogLevel()
+		err = runSelfTest(args[1:])
+	case "sniff":
+		setLogLevel()
+		err = runSniffer(args[1:])
+	default:
+		err = runControlCommand(args)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// Main runs the combined binary, the driver without arguments, otherwise
+// the subcommand.
+func Main() {
+	loadConfig()
+
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	run()
+}
+
+// RunDaemon only runs the driver, for the trusdxd binary next to the
+// trusdxctl client.
+func RunDaemon() {
+	loadConfig()
+
+	if len(os.Args) > 1 {
+		fmt.Fprintln(os.Stderr, "trusdxd takes no arguments, the commands go through trusdxctl")
+		os.Exit(2)
+	}
+
+	run()
+}
+
+func loadConfig() {
+	if err := config.Load(config.FilePath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalln(err)
+	}
+}
+
+func run() {
+	sig := make(chan os.Signal, 1)
+	done := make(chan bool, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	setLogLevel()
+	preventSleep()
+
+	devicePort := serialPortName()
+
+	log.Println("Waiting for the rig...")
+	ss := NewSerialStream(devicePort)
+	ss.Start()
+
+	control := NewControlServer(config.ControlSocketPath())
+	registerExtCommands(control, ss)
+	registerSettingsCommands(control, ss)
+	registerGetSetCommands(control, ss)
+	registerSuspendCommands(control, ss)
+	registerStatsCommands(control, ss)
+	registerCatCommands(control, ss)
+	registerCwCommands(control, ss)
+	registerRxCommands(control, ss)
+	registerToneCommands(control, ss)
+	registerSweepCommands(control, ss)
+	registerCalibrateCommands(control, ss)
+	beacon := NewBeacon(ss)
+	registerBeaconCommands(control, beacon)
+	voiceKeyer := NewVoiceKeyer(ss)
+	registerVoiceKeyerCommands(control, voiceKeyer)
+	macros := NewMacros(ss, voiceKeyer)
+	if path, ok := os.LookupEnv("MACROS"); ok {
+		if err := macros.Load(path); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	registerMacroCommands(control, macros)
+	recorder := NewRecorder(ss)
+	registerRecorderCommands(control, recorder)
+	if err := control.Start(); err != nil {
+		log.Fatalln(err)
+	}
+	log.Println("Driver ready! Press Ctrl-C to stop.")
+
+	// clients open the slave side, the driver talks to them through the
+	// master side directly
+	ptmCat, ptsCat, err := openPty()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("CAT serial port: %s\n", ptsCat.Name())
+	registerStatusCommands(control, ss, ptsCat.Name(), sig)
+	configurePort(ptsCat)
+	ptmCat, err = pollable(ptmCat)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	script := loadScriptFromEnv(ss)
+	offset, err := newFrequencyOffsetFromEnv()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	catClients := newCatClientsFromEnv(ptsCat.Name())
+	catSession := NewCatSession(ss, ptsCat, catClients)
+	ss.goGuarded("CAT from the port", func() { getCatFromPort(ptmCat, ss, script, offset, catSession) })
+	ss.goGuarded("CAT to the port", func() { sendCatToPort(ptmCat, ss, script, offset, catSession) })
+
+	catListener, err := startCatListenerFromEnv(ss, script, offset, catClients)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if ss.withAudio {
+		ss.PushCommand(";MD2;UA2;RX;")
+	}
+	isRateMeasured := ss.withAudio && ss.setupSampleRates()
+	rigStatePersister := startRigStatePersisterFromEnv(ss)
+
+	var stopAudio func()
+	var stopAudioMu sync.Mutex
+	var pipeTx *NetworkTx
+	if ss.withAudio && driverMode() == driverModePipe {
+		pipeTx = startPipeAudio(ss)
+	} else if ss.withAudio && driverMode() != driverModeServer {
+		stopAudio, err = startAudio(ss)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	} else if ss.withAudio {
+		// nothing plays the audio, it only goes to the network
+		go func() {
+			for isRunning() {
+				<-ss.AudioOutBuf
+			}
+		}()
+	}
+
+	if isRateMeasured {
+		// the sound card is opened at the nominal rates first, and again
+		// once the rate the rig streams at has been measured
+		go func() {
+			if !ss.measureSampleRates(2 * time.Second) {
+				return
+			}
+			stopAudioMu.Lock()
+			defer stopAudioMu.Unlock()
+			if stopAudio == nil {
+				return
+			}
+			stopAudio()
+			stop, err := startAudio(ss)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			stopAudio = stop
+		}()
+	}
+
+	var networkTx *NetworkTx
+	var mumble *Mumble
+	httpServer := newHttpServerFromEnv()
+	if ss.withAudio {
+		networkTx = startNetworkTxFromEnv(ss)
+		mumble = startMumbleFromEnv(ss)
+		startRxFifoFromEnv(ss)
+		httpServer.Handle("/rx.wav", serveRxAudio(ss))
+		httpServer.Handle("/rx", serveRxVolume(ss))
+		httpServer.Handle("/rx.ws", serveRxWebSocket(ss))
+	}
+	httpServer.Handle("/health", serveHealth(ss))
+	if token := os.Getenv("HTTP_PTT_TOKEN"); token != "" {
+		httpPtt := NewHttpPtt(ss, token, envDuration("HTTP_PTT_TIMEOUT", 3*time.Minute))
+		httpServer.Handle("/ptt/", newAccessListFromEnv("HTTP_PTT").Handler(httpPtt.ServeHTTP))
+	}
+	if path := os.Getenv("HISTORY_FILE"); path != "" {
+		httpServer.Handle("/history", serveHistory(path))
+	}
+	if err := httpServer.Start(); err != nil {
+		log.Fatalln(err)
+	}
+	go reportStats(ss, envDuration("STATS_INTERVAL", time.Minute))
+	beacon.Start()
+	startPttInputFromEnv(ss)
+	startMidiFromEnv(ss)
+	dbusService := startDbusFromEnv(ss)
+	n1mm := startN1mmFromEnv(ss)
+	stateUdp := startStateUdpFromEnv(ss)
+	history := startHistoryFromEnv(ss)
+	NewHooks(ss).Start()
+	bandData := startBandDataFromEnv(ss)
+	go catClients.Watch(ss)
+
+	go func() {
+		<-sig
+		isShuttingDown.Store(true)
+		beacon.Stop()
+		recorder.Stop()
+		networkTx.Close()
+		mumble.Close()
+		pipeTx.Close()
+		httpServer.Close()
+		catListener.Close()
+		bandData.Close()
+		dbusService.Close()
+		n1mm.Close()
+		stateUdp.Close()
+		rigStatePersister.Close()
+		history.Close()
+		control.Close()
+		ss.PushCommand(";UA0;")
+		ss.Close()
+		ptmCat.Close()
+		ptsCat.Close()
+		stopAudioMu.Lock()
+		if stopAudio != nil {
+			stopAudio()
+			stopAudio = nil
+		}
+		stopAudioMu.Unlock()
+		log.Println("Bye-bye!")
+		done <- true
+	}()
+
+	<-done
+}
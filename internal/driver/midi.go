@@ -0,0 +1,173 @@
+package driver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// midiControl is a knob ("cc:<number>") or a button ("note:<number>") of a
+// MIDI controller, on any channel.
+type midiControl struct {
+	isNote bool
+	number byte
+}
+
+func parseMidiControl(text string) (midiControl, bool, error) {
+	if text == "" {
+		return midiControl{}, false, nil
+	}
+
+	kind, numberText, _ := strings.Cut(text, ":")
+	number, err := strconv.ParseUint(numberText, 10, 7)
+	if err != nil || (kind != "cc" && kind != "note") {
+		return midiControl{}, false, fmt.Errorf("invalid MIDI control %q, it's cc:<number> or note:<number>", text)
+	}
+
+	return midiControl{kind == "note", byte(number)}, true, nil
+}
+
+// MidiController turns a MIDI control surface into a front panel of the
+// rig: a relative encoder tunes, a knob sets the volume, buttons key the
+// rig and switch bands.
+type MidiController struct {
+	ss        *SerialStream
+	device    string
+	tuneStep  int64
+	volumeMax int
+	controls  map[midiControl]string
+}
+
+// startMidiFromEnv starts reading MIDI_DEVICE, if it's set.
+func startMidiFromEnv(ss *SerialStream) {
+	device, ok := os.LookupEnv("MIDI_DEVICE")
+	if !ok {
+		return
+	}
+
+	mc := &MidiController{
+		ss:        ss,
+		device:    device,
+		tuneStep:  int64(envInt("MIDI_TUNE_STEP", 10)),
+		volumeMax: envInt("MIDI_VOLUME_MAX", 255),
+		controls:  make(map[midiControl]string),
+	}
+	for action, name := range map[string]string{
+		"tune":      "MIDI_TUNE",
+		"volume":    "MIDI_VOLUME",
+		"ptt":       "MIDI_PTT",
+		"band-up":   "MIDI_BAND_UP",
+		"band-down": "MIDI_BAND_DOWN",
+	} {
+		control, ok, err := parseMidiControl(os.Getenv(name))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if ok {
+			mc.controls[control] = action
+		}
+	}
+
+	if err := mc.Start(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// Start reads the raw MIDI device, e.g. /dev/snd/midiC1D0 on Linux.
+func (mc *MidiController) Start() error {
+	device, err := os.Open(mc.device)
+	if err != nil {
+		return err
+	}
+	log.Printf("MIDI controller %s\n", mc.device)
+
+	go func() {
+		defer device.Close()
+		reader := bufio.NewReader(device)
+
+		var status byte
+		var data []byte
+		for {
+			b, err := reader.ReadByte()
+			if err != nil {
+				log.Warnf("MIDI controller %s: %s\n", mc.device, err)
+				return
+			}
+
+			switch {
+			case b >= 0xF8:
+				// real-time messages can come anywhere
+				continue
+			case b >= 0xF0:
+				// system messages cancel the running status
+				status = 0
+				continue
+			case b&0x80 != 0:
+				status, data = b, data[:0]
+				continue
+			case status == 0:
+				continue
+			}
+
+			data = append(data, b)
+			if kind := status & 0xF0; (kind == 0x80 || kind == 0x90 || kind == 0xB0) && len(data) == 2 {
+				mc.handleMessage(kind, data[0], data[1])
+				data = data[:0]
+			} else if len(data) >= 2 {
+				data = data[:0]
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (mc *MidiController) handleMessage(kind, number, value byte) {
+	control := midiControl{isNote: kind != 0xB0, number: number}
+	action, ok := mc.controls[control]
+	if !ok {
+		return
+	}
+	// a note on with zero velocity is a note off
+	isPressed := kind == 0x90 && value > 0
+
+	switch action {
+	case "tune":
+		// relative encoders send 1-63 for clockwise and 65-127 for
+		// counterclockwise steps
+		steps := int64(value)
+		if value >= 64 {
+			steps -= 128
+		}
+		if frequency := mc.frequency(); frequency != 0 {
+			mc.ss.PushCommand(fmt.Sprintf("FA%011d", frequency+steps*mc.tuneStep))
+		}
+	case "volume":
+		mc.ss.SetExtended("volume", int(value)*mc.volumeMax/127)
+	case "ptt":
+		if isPressed {
+			mc.ss.PushCommand("TX")
+		} else {
+			mc.ss.PushCommand("RX")
+		}
+	case "band-up", "band-down":
+		if frequency := mc.frequency(); isPressed && frequency != 0 {
+			b := nextBand(frequency, action == "band-up")
+			mc.ss.PushCommand(fmt.Sprintf("FA%011d", b.home))
+			log.Infof("Band %s\n", b.name)
+		}
+	}
+}
+
+func (mc *MidiController) frequency() int64 {
+	if mc.ss.State.Frequency() == 0 {
+		// nobody has asked yet, the reply updates the state
+		mc.ss.Query("FA")
+	}
+
+	return mc.ss.State.Frequency()
+}
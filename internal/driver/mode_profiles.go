@@ -0,0 +1,207 @@
+package driver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// modeSettings apply the settings a mode profile can change.
+var modeSettings = map[string]func(ss *SerialStream, value string) error{
+	"tx_audio": func(ss *SerialStream, value string) error {
+		isOn, err := parseOnOff(value)
+		if err != nil {
+			return err
+		}
+		ss.isTxAudioOff.Store(!isOn)
+		return nil
+	},
+	"rx_gain": func(ss *SerialStream, value string) error {
+		db, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		ss.rxChain.SetGain(db)
+		return nil
+	},
+	"tx_highpass": func(ss *SerialStream, value string) error {
+		cutoff, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		ss.txChain.SetHighPass(cutoff)
+		return nil
+	},
+	"tx_limiter": func(ss *SerialStream, value string) error {
+		threshold, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		ss.txChain.SetLimiter(threshold)
+		return nil
+	},
+}
+
+func parseOnOff(value string) (bool, error) {
+	switch value {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("expected on or off, not %q", value)
+}
+
+// ModeProfiles reconfigure the audio chain when the mode of the rig
+// changes. A setting the profile of the new mode doesn't mention goes back
+// to its value at startup, as long as the profile of the old mode has
+// changed it.
+type ModeProfiles struct {
+	mu       sync.Mutex
+	profiles map[byte]map[string]string
+	defaults map[string]string
+	mode     byte
+}
+
+// newModeProfilesFromEnv returns nil unless MODE_PROFILES is set.
+func newModeProfilesFromEnv() (*ModeProfiles, error) {
+	path, ok := os.LookupEnv("MODE_PROFILES")
+	if !ok {
+		return nil, nil
+	}
+
+	mp := &ModeProfiles{
+		profiles: make(map[byte]map[string]string),
+		defaults: map[string]string{
+			"tx_audio":    "on",
+			"rx_gain":     strconv.Itoa(envInt("RX_GAIN", 0)),
+			"tx_highpass": strconv.Itoa(envInt("TX_HIGHPASS", 0)),
+			"tx_limiter":  strconv.Itoa(envInt("TX_LIMITER", 0)),
+		},
+	}
+	if err := mp.Load(path); err != nil {
+		return nil, err
+	}
+
+	return mp, nil
+}
+
+// Load reads a table of "<mode> <setting>=<value> ..." lines, e.g.
+// "CW tx_audio=off rx_gain=6". Empty lines and lines starting with # are
+// ignored.
+func (mp *ModeProfiles) Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		mode, ok := parseModeName(fields[0])
+		if !ok {
+			return fmt.Errorf("%s:%d: unknown mode %q", path, lineNo, fields[0])
+		}
+		profile := make(map[string]string)
+		for _, field := range fields[1:] {
+			name, value, _ := strings.Cut(field, "=")
+			if _, ok := modeSettings[name]; !ok {
+				return fmt.Errorf("%s:%d: unknown setting %q", path, lineNo, name)
+			}
+			if err := checkModeSetting(name, value); err != nil {
+				return fmt.Errorf("%s:%d: %s: %w", path, lineNo, name, err)
+			}
+			profile[name] = value
+		}
+		mp.profiles[mode] = profile
+	}
+
+	return scanner.Err()
+}
+
+func checkModeSetting(name string, value string) error {
+	var err error
+	switch name {
+	case "tx_audio":
+		_, err = parseOnOff(value)
+	case "rx_gain":
+		_, err = strconv.ParseFloat(value, 64)
+	default:
+		_, err = strconv.Atoi(value)
+	}
+
+	return err
+}
+
+// AdjustsTx tells whether a profile changes the filters of the TX audio.
+func (mp *ModeProfiles) AdjustsTx() bool {
+	if mp == nil {
+		return false
+	}
+
+	for _, profile := range mp.profiles {
+		for name := range profile {
+			if name == "tx_highpass" || name == "tx_limiter" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// changes returns the settings to apply when the rig goes to the mode.
+func (mp *ModeProfiles) changes(mode byte) map[string]string {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	changes := make(map[string]string)
+	for name := range mp.profiles[mp.mode] {
+		changes[name] = mp.defaults[name]
+	}
+	for name, value := range mp.profiles[mode] {
+		changes[name] = value
+	}
+	mp.mode = mode
+
+	return changes
+}
+
+// applyModeProfile is subscribed to the events when there are profiles.
+func (ss *SerialStream) applyModeProfile(event Event) {
+	e, ok := event.(ModeChanged)
+	if !ok {
+		return
+	}
+
+	for name, value := range ss.modeProfiles.changes(e.Mode) {
+		if err := modeSettings[name](ss, value); err != nil {
+			log.Warnf("Mode profile of %s: %s: %s\n", modeNames[e.Mode], name, err)
+			continue
+		}
+		log.Debugf("Mode profile of %s: %s=%s\n", modeNames[e.Mode], name, value)
+	}
+}
+
+// parseModeName takes the name of a mode, e.g. USB, or its number.
+func parseModeName(name string) (byte, bool) {
+	for mode, modeName := range modeNames {
+		if strings.EqualFold(name, modeName) || name == string(mode) {
+			return mode, true
+		}
+	}
+
+	return 0, false
+}
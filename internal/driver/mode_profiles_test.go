@@ -0,0 +1,126 @@
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestModeProfilesLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    map[byte]map[string]string
+		wantErr string
+	}{
+		{
+			name: "profiles",
+			text: "# digital modes\nUSB tx_highpass=300 tx_limiter=100\n\ncw tx_audio=off rx_gain=6.5\n",
+			want: map[byte]map[string]string{
+				'2': {"tx_highpass": "300", "tx_limiter": "100"},
+				'3': {"tx_audio": "off", "rx_gain": "6.5"},
+			},
+		},
+		{
+			name: "by number",
+			text: "5 rx_gain=-3",
+			want: map[byte]map[string]string{'5': {"rx_gain": "-3"}},
+		},
+		{
+			name: "no settings",
+			text: "FM",
+			want: map[byte]map[string]string{'4': {}},
+		},
+		{
+			name:    "unknown mode",
+			text:    "USB tx_audio=on\nDIGU tx_audio=on",
+			wantErr: `:2: unknown mode "DIGU"`,
+		},
+		{
+			name:    "unknown setting",
+			text:    "USB volume=3",
+			wantErr: `unknown setting "volume"`,
+		},
+		{
+			name:    "invalid on/off",
+			text:    "CW tx_audio=yes",
+			wantErr: "tx_audio: expected on or off",
+		},
+		{
+			name:    "invalid number",
+			text:    "USB tx_highpass=300Hz",
+			wantErr: "tx_highpass:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "profiles")
+			if err := os.WriteFile(path, []byte(tt.text), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			mp := &ModeProfiles{profiles: make(map[byte]map[string]string)}
+			err := mp.Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Load() error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(mp.profiles, tt.want) {
+				t.Errorf("Load() = %v, %v, want %v", mp.profiles, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestModeProfilesChanges(t *testing.T) {
+	mp := &ModeProfiles{
+		profiles: map[byte]map[string]string{
+			'2': {"tx_highpass": "300"},
+			'3': {"tx_audio": "off", "rx_gain": "6"},
+		},
+		defaults: map[string]string{"tx_audio": "on", "rx_gain": "0", "tx_highpass": "0", "tx_limiter": "0"},
+	}
+
+	tests := []struct {
+		mode byte
+		want map[string]string
+	}{
+		{mode: '2', want: map[string]string{"tx_highpass": "300"}},
+		{mode: '3', want: map[string]string{"tx_highpass": "0", "tx_audio": "off", "rx_gain": "6"}},
+		{mode: '3', want: map[string]string{"tx_audio": "off", "rx_gain": "6"}},
+		{mode: '4', want: map[string]string{"tx_audio": "on", "rx_gain": "0"}},
+		{mode: '1', want: map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		if got := mp.changes(tt.mode); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("changes(%s) = %v, want %v", modeNames[tt.mode], got, tt.want)
+		}
+	}
+}
+
+func TestParseModeName(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   byte
+		wantOk bool
+	}{
+		{name: "USB", want: '2', wantOk: true},
+		{name: "cw-r", want: '7', wantOk: true},
+		{name: "9", want: '9', wantOk: true},
+		{name: "8", wantOk: false},
+		{name: "DIGU", wantOk: false},
+		{name: "", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		if got, ok := parseModeName(tt.name); got != tt.want || ok != tt.wantOk {
+			t.Errorf("parseModeName(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+//go:build !noportaudio
+
+package driver
+
+import (
+	"sync/atomic"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// startMonitor plays the received audio on the route as well, e.g. the
+// speakers, the returned function stops it.
+func startMonitor(ss *SerialStream, route audioRoute, format string, framesPerBuffer int) (func(), error) {
+	// the underruns of the monitor don't matter for the digimode programs
+	stats := new(Stats)
+	params := portaudio.HighLatencyParameters(nil, route.device)
+	stream, ring, err := openOutput(route, params, ss.RxRate(), format, framesPerBuffer, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	rx := ss.RxAudio.Add("monitor", cap(ss.AudioOutBuf))
+	var sidetone *AudioSink
+	var sidetoneBuf chan []byte
+	if envInt("MONITOR_SIDETONE", 0) != 0 {
+		sidetone = ss.TxAudio.Add("sidetone", cap(ss.AudioInBuf))
+		sidetoneBuf = sidetone.C
+	}
+	monitorBuf := make(chan []byte, cap(ss.AudioOutBuf))
+	stop := make(chan struct{})
+	go feedMonitor(ss, rx.C, sidetoneBuf, monitorBuf, stop)
+	go getAudioFromRig(ring, monitorBuf, framesPerBuffer, stats, ss.RxJitter)
+	stream.Start()
+
+	return func() {
+		close(stop)
+		ss.RxAudio.Remove(rx)
+		if sidetone != nil {
+			ss.TxAudio.Remove(sidetone)
+		}
+		stream.Close()
+		ring.Close()
+	}, nil
+}
+
+// feedMonitor mutes the monitor while transmitting, so the speakers don't
+// feed back into the microphone. With a sidetone, the transmitted audio is
+// played instead.
+func feedMonitor(ss *SerialStream, rx, sidetone <-chan []byte, out chan<- []byte, stop <-chan struct{}) {
+	rxRate, txRate := ss.RxRate(), ss.TxRate()
+	var isTransmitting atomic.Bool
+	ss.Events.Subscribe(func(event Event) {
+		if e, ok := event.(PttChanged); ok {
+			isTransmitting.Store(e.Transmitting)
+		}
+	})
+
+	for {
+		var chunk []byte
+		select {
+		case chunk = <-rx:
+			if isTransmitting.Load() {
+				continue
+			}
+		case chunk = <-sidetone:
+			chunk = resample(chunk, txRate, rxRate)
+		case <-stop:
+			return
+		}
+
+		select {
+		case out <- chunk:
+		default:
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package driver
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+var morseCode = map[rune]string{
+	'A': ".-", 'B': "-...", 'C': "-.-.", 'D': "-..", 'E': ".", 'F': "..-.",
+	'G': "--.", 'H': "....", 'I': "..", 'J': ".---", 'K': "-.-", 'L': ".-..",
+	'M': "--", 'N': "-.", 'O': "---", 'P': ".--.", 'Q': "--.-", 'R': ".-.",
+	'S': "...", 'T': "-", 'U': "..-", 'V': "...-", 'W': ".--", 'X': "-..-",
+	'Y': "-.--", 'Z': "--..",
+	'0': "-----", '1': ".----", '2': "..---", '3': "...--", '4': "....-",
+	'5': ".....", '6': "-....", '7': "--...", '8': "---..", '9': "----.",
+	'/': "-..-.", '?': "..--..", '=': "-...-", '.': ".-.-.-", ',': "--..--",
+	'+': ".-.-.",
+}
+
+// morseSamples keys a tone with the text as Morse code, at wpm words per
+// minute by the PARIS timing. The edges are shaped to avoid key clicks.
+func morseSamples(text string, wpm int, frequency, level, sampleRate float64) []byte {
+	dot := int(1.2 / float64(wpm) * sampleRate)
+	ramp := int(0.005 * sampleRate)
+	amplitude := 127 * level
+
+	var samples []byte
+	gap := func(dots int) {
+		for i := 0; i < dots*dot; i++ {
+			samples = append(samples, 128)
+		}
+	}
+	mark := func(dots int) {
+		length := dots * dot
+		for i := 0; i < length; i++ {
+			envelope := 1.0
+			if i < ramp {
+				envelope = 0.5 - 0.5*math.Cos(math.Pi*float64(i)/float64(ramp))
+			} else if length-i < ramp {
+				envelope = 0.5 - 0.5*math.Cos(math.Pi*float64(length-i)/float64(ramp))
+			}
+			value := amplitude * envelope * math.Sin(2*math.Pi*frequency*float64(len(samples))/sampleRate)
+			samples = append(samples, byte(math.Round(128+value)))
+		}
+	}
+
+	for w, word := range strings.Fields(strings.ToUpper(text)) {
+		if w > 0 {
+			gap(7)
+		}
+		for c, char := range word {
+			code, ok := morseCode[char]
+			if !ok {
+				continue
+			}
+			if c > 0 {
+				gap(3)
+			}
+			for e, element := range code {
+				if e > 0 {
+					gap(1)
+				}
+				if element == '.' {
+					mark(1)
+				} else {
+					mark(3)
+				}
+			}
+		}
+	}
+
+	return samples
+}
+
+// SendMorse transmits the text as a keyed tone, so the rig has to be in USB
+// or LSB. The returned channel is closed when it's sent.
+func (ss *SerialStream) SendMorse(text string, wpm int, tone float64) (<-chan struct{}, error) {
+	if ss.State.Mode() == 0 {
+		// nobody has asked yet, the reply updates the state
+		ss.Query("MD")
+	}
+	if mode := ss.State.Mode(); mode != '1' && mode != '2' {
+		return nil, errors.New("the rig isn't in USB or LSB")
+	}
+
+	return ss.Play(morseSamples(text, wpm, tone, 0.8, float64(ss.TxRate())), false, 0)
+}
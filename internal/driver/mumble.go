@@ -0,0 +1,533 @@
+//go:build mumble
+
+package driver
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"layeh.com/gopus"
+)
+
+// Just enough of the Mumble protocol to bridge the rig to a channel: the
+// received audio is spoken in the channel, and the users allowed to
+// transmit key the rig by talking. The voice is tunneled through the TLS
+// connection, as the legacy packets of the 1.2 clients, without UDP.
+
+// 1.2.4, the last version before the protobuf voice packets
+const mumbleVersion = 1<<16 | 2<<8 | 4
+
+const (
+	mumbleSampleRate   = 48000
+	mumbleFrameSize    = 960 // 20 ms
+	mumbleBitrate      = 24000
+	mumblePingInterval = 15 * time.Second
+	mumbleRetryDelay   = 10 * time.Second
+	// the longest control message taken from the server
+	mumbleMaxMessage = 1 << 20
+	// another user may talk when the one talking has been quiet this long
+	mumbleTalkerTimeout = 500 * time.Millisecond
+)
+
+// the types of the control messages
+const (
+	mumbleMsgVersion          = 0
+	mumbleMsgUDPTunnel        = 1
+	mumbleMsgAuthenticate     = 2
+	mumbleMsgPing             = 3
+	mumbleMsgReject           = 4
+	mumbleMsgServerSync       = 5
+	mumbleMsgChannelState     = 7
+	mumbleMsgUserRemove       = 8
+	mumbleMsgUserState        = 9
+	mumbleMsgPermissionDenied = 12
+)
+
+const (
+	mumbleVoiceOpus = 4
+	// in the length of an Opus frame, the last one of a transmission
+	mumbleOpusTerminator = 0x2000
+)
+
+// protoMessage writes the fields of a protobuf message.
+type protoMessage []byte
+
+func (m protoMessage) varint(field int, value uint64) protoMessage {
+	m = binary.AppendUvarint(m, uint64(field)<<3)
+	return binary.AppendUvarint(m, value)
+}
+
+func (m protoMessage) bool(field int, value bool) protoMessage {
+	if value {
+		return m.varint(field, 1)
+	}
+	return m.varint(field, 0)
+}
+
+func (m protoMessage) string(field int, value string) protoMessage {
+	m = binary.AppendUvarint(m, uint64(field)<<3|2)
+	m = binary.AppendUvarint(m, uint64(len(value)))
+	return append(m, value...)
+}
+
+// protoFields reads the varint and the length-delimited fields of a
+// protobuf message, the last value of each, which is all the client needs.
+func protoFields(data []byte) (map[int]uint64, map[int][]byte, error) {
+	numbers, texts := make(map[int]uint64), make(map[int][]byte)
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, nil, errors.New("invalid protobuf key")
+		}
+		data = data[n:]
+		field := int(key >> 3)
+
+		switch key & 7 {
+		case 0:
+			value, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, nil, errors.New("invalid protobuf varint")
+			}
+			numbers[field], data = value, data[n:]
+		case 1, 5:
+			size := 8
+			if key&7 == 5 {
+				size = 4
+			}
+			if len(data) < size {
+				return nil, nil, errors.New("protobuf message too short")
+			}
+			data = data[size:]
+		case 2:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return nil, nil, errors.New("protobuf message too short")
+			}
+			texts[field], data = data[n:n+int(length)], data[n+int(length):]
+		default:
+			return nil, nil, fmt.Errorf("unsupported protobuf wire type %d", key&7)
+		}
+	}
+
+	return numbers, texts, nil
+}
+
+// appendMumbleVarint writes the variable length integers of the voice
+// packets, big endian with the length in the leading bits.
+func appendMumbleVarint(b []byte, value uint64) []byte {
+	switch {
+	case value < 0x80:
+		return append(b, byte(value))
+	case value < 0x4000:
+		return append(b, byte(value>>8)|0x80, byte(value))
+	case value < 0x200000:
+		return append(b, byte(value>>16)|0xc0, byte(value>>8), byte(value))
+	case value < 0x10000000:
+		return append(b, byte(value>>24)|0xe0, byte(value>>16), byte(value>>8), byte(value))
+	case value < 1<<32:
+		return binary.BigEndian.AppendUint32(append(b, 0xf0), uint32(value))
+	}
+
+	return binary.BigEndian.AppendUint64(append(b, 0xf4), value)
+}
+
+// readMumbleVarint returns the value and its length, the negative ones
+// aren't used in voice packets and are refused.
+func readMumbleVarint(b []byte) (uint64, int, bool) {
+	if len(b) == 0 {
+		return 0, 0, false
+	}
+
+	var size int
+	var value uint64
+	switch first := b[0]; {
+	case first < 0x80:
+		return uint64(first), 1, true
+	case first < 0xc0:
+		size, value = 2, uint64(first&0x3f)
+	case first < 0xe0:
+		size, value = 3, uint64(first&0x1f)
+	case first < 0xf0:
+		size, value = 4, uint64(first&0x0f)
+	case first&0xfc == 0xf0:
+		size = 5
+	case first&0xfc == 0xf4:
+		size = 9
+	default:
+		return 0, 0, false
+	}
+	if len(b) < size {
+		return 0, 0, false
+	}
+	for _, c := range b[1:size] {
+		value = value<<8 | uint64(c)
+	}
+
+	return value, size, true
+}
+
+// Mumble is the connection to the server, made again when it's lost.
+type Mumble struct {
+	ss        *SerialStream
+	address   string
+	user      string
+	password  string
+	channel   string
+	txUsers   map[string]bool
+	tlsConfig *tls.Config
+	tx        *NetworkTx
+	closed    atomic.Bool
+
+	mu       sync.Mutex // the messages are written one at a time
+	conn     net.Conn
+	session  uint32
+	users    map[uint32]string
+	channels map[string]uint32
+	decoders map[uint32]*gopus.Decoder
+	talker   uint32
+	talkerAt time.Time
+}
+
+// startMumbleFromEnv connects to MUMBLE_SERVER, it returns nil when it's
+// not set.
+func startMumbleFromEnv(ss *SerialStream) *Mumble {
+	address, ok := os.LookupEnv("MUMBLE_SERVER")
+	if !ok || address == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "64738")
+	}
+	host, _, _ := net.SplitHostPort(address)
+
+	m := &Mumble{
+		ss:        ss,
+		address:   address,
+		user:      os.Getenv("MUMBLE_USER"),
+		password:  os.Getenv("MUMBLE_PASSWORD"),
+		channel:   os.Getenv("MUMBLE_CHANNEL"),
+		txUsers:   make(map[string]bool),
+		tlsConfig: &tls.Config{ServerName: host, InsecureSkipVerify: os.Getenv("MUMBLE_INSECURE") == "1"},
+	}
+	if m.user == "" {
+		m.user = "truSDX"
+	}
+	for _, name := range strings.Split(os.Getenv("MUMBLE_TX_USERS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			m.txUsers[name] = true
+		}
+	}
+	// talking keys the rig, which goes back to RX after the gap
+	m.tx = NewNetworkTx(ss)
+	m.tx.withPtt = true
+
+	log.Printf("Mumble: %s as %s\n", address, m.user)
+	go m.run()
+
+	return m
+}
+
+func (m *Mumble) run() {
+	for !m.closed.Load() {
+		err := m.connect()
+		if m.closed.Load() {
+			return
+		}
+		log.Warnf("Mumble: %s, connecting again in %s\n", err, mumbleRetryDelay)
+		time.Sleep(mumbleRetryDelay)
+	}
+}
+
+// connect talks to the server until the connection is lost.
+func (m *Mumble) connect() error {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", m.address, m.tlsConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	m.mu.Lock()
+	m.conn = conn
+	m.session = 0
+	m.users = make(map[uint32]string)
+	m.channels = make(map[string]uint32)
+	m.decoders = make(map[uint32]*gopus.Decoder)
+	m.talker = 0
+	m.mu.Unlock()
+	if m.closed.Load() {
+		return net.ErrClosed
+	}
+
+	m.send(mumbleMsgVersion, protoMessage(nil).
+		varint(1, mumbleVersion).
+		string(2, "trusdx-go").
+		string(3, "trusdx-go"))
+	m.send(mumbleMsgAuthenticate, protoMessage(nil).
+		string(1, m.user).
+		string(2, m.password).
+		bool(5, true))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go m.ping(stop)
+
+	for {
+		kind, payload, err := readMumbleMessage(conn)
+		if err != nil {
+			return err
+		}
+		if err := m.handle(kind, payload, stop); err != nil {
+			return err
+		}
+	}
+}
+
+func readMumbleMessage(r io.Reader) (uint16, []byte, error) {
+	var header [6]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[2:])
+	if length > mumbleMaxMessage {
+		return 0, nil, fmt.Errorf("message of %d bytes", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+
+	return binary.BigEndian.Uint16(header[:2]), payload, nil
+}
+
+func (m *Mumble) send(kind uint16, payload []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	message := binary.BigEndian.AppendUint16(nil, kind)
+	message = binary.BigEndian.AppendUint32(message, uint32(len(payload)))
+	m.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := m.conn.Write(append(message, payload...))
+
+	return err
+}
+
+// ping keeps the connection, the server drops the clients quiet for 30
+// seconds.
+func (m *Mumble) ping(stop chan struct{}) {
+	ticker := time.NewTicker(mumblePingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.send(mumbleMsgPing, protoMessage(nil).varint(1, uint64(time.Now().Unix())))
+		}
+	}
+}
+
+func (m *Mumble) handle(kind uint16, payload []byte, stop chan struct{}) error {
+	if kind == mumbleMsgUDPTunnel {
+		m.receiveVoice(payload)
+		return nil
+	}
+	if kind != mumbleMsgReject && kind != mumbleMsgServerSync && kind != mumbleMsgChannelState &&
+		kind != mumbleMsgUserState && kind != mumbleMsgUserRemove && kind != mumbleMsgPermissionDenied {
+		return nil
+	}
+
+	numbers, texts, err := protoFields(payload)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case mumbleMsgReject:
+		return fmt.Errorf("refused by the server: %s", texts[2])
+	case mumbleMsgServerSync:
+		m.mu.Lock()
+		session := uint32(numbers[1])
+		m.session = session
+		id, ok := m.channels[m.channel]
+		m.mu.Unlock()
+		log.Printf("Mumble: connected to %s\n", m.address)
+		if m.channel != "" && !ok {
+			log.Warnf("Mumble: no channel %q\n", m.channel)
+		} else if m.channel != "" {
+			m.send(mumbleMsgUserState, protoMessage(nil).varint(1, uint64(session)).varint(5, uint64(id)))
+		}
+		go m.sendVoice(stop)
+	case mumbleMsgChannelState:
+		if name, ok := texts[3]; ok {
+			m.mu.Lock()
+			m.channels[string(name)] = uint32(numbers[1])
+			m.mu.Unlock()
+		}
+	case mumbleMsgUserState:
+		if name, ok := texts[3]; ok {
+			m.mu.Lock()
+			m.users[uint32(numbers[1])] = string(name)
+			m.mu.Unlock()
+		}
+	case mumbleMsgUserRemove:
+		m.mu.Lock()
+		delete(m.users, uint32(numbers[1]))
+		delete(m.decoders, uint32(numbers[1]))
+		m.mu.Unlock()
+	case mumbleMsgPermissionDenied:
+		log.Warnf("Mumble: permission denied, %s\n", texts[4])
+	}
+
+	return nil
+}
+
+// receiveVoice transmits what the users allowed to talk say, one at a time.
+func (m *Mumble) receiveVoice(packet []byte) {
+	if len(packet) == 0 || packet[0]>>5 != mumbleVoiceOpus {
+		return
+	}
+	packet = packet[1:]
+	session, n, ok := readMumbleVarint(packet)
+	if !ok {
+		return
+	}
+	packet = packet[n:]
+	// the sequence number
+	if _, n, ok = readMumbleVarint(packet); !ok {
+		return
+	}
+	packet = packet[n:]
+	header, n, ok := readMumbleVarint(packet)
+	length := int(header &^ mumbleOpusTerminator)
+	if !ok || len(packet)-n < length {
+		return
+	}
+	frame, isLast := packet[n:n+length], header&mumbleOpusTerminator != 0
+
+	m.mu.Lock()
+	name := m.users[uint32(session)]
+	isOther := m.talker != 0 && m.talker != uint32(session) && time.Since(m.talkerAt) < mumbleTalkerTimeout
+	if !m.txUsers[name] || isOther {
+		m.mu.Unlock()
+		return
+	}
+	if m.talker != uint32(session) {
+		log.Infof("Mumble: %s is talking\n", name)
+	}
+	m.talker, m.talkerAt = uint32(session), time.Now()
+	if isLast {
+		m.talker = 0
+	}
+	decoder, ok := m.decoders[uint32(session)]
+	if !ok {
+		var err error
+		if decoder, err = gopus.NewDecoder(mumbleSampleRate, 1); err != nil {
+			m.mu.Unlock()
+			log.Errorln(err)
+			return
+		}
+		m.decoders[uint32(session)] = decoder
+	}
+	m.mu.Unlock()
+
+	if len(frame) == 0 {
+		return
+	}
+	// up to 120 ms in a packet
+	pcm, err := decoder.Decode(frame, 6*mumbleFrameSize, false)
+	if err != nil {
+		log.Debugf("Mumble: %s\n", err)
+		return
+	}
+	m.tx.write(resample(newQuantizerFromEnv().Quantize(pcm), mumbleSampleRate, m.ss.TxRate()))
+}
+
+// sendVoice speaks the received audio in the channel, until the connection
+// is lost.
+func (m *Mumble) sendVoice(stop chan struct{}) {
+	encoder, err := gopus.NewEncoder(mumbleSampleRate, 1, gopus.Voip)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	encoder.SetBitrate(mumbleBitrate)
+
+	sink := m.ss.RxAudio.Add("mumble", cap(m.ss.AudioOutBuf))
+	defer m.ss.RxAudio.Remove(sink)
+
+	var pcm []int16
+	var sequence uint64
+	isTalking := false
+	for {
+		select {
+		case <-stop:
+			return
+		case samples := <-sink.C:
+			for _, sample := range resample(samples, m.ss.RxRate(), mumbleSampleRate) {
+				pcm = append(pcm, (int16(sample)-128)<<8)
+			}
+		case <-time.After(200 * time.Millisecond):
+			// the rig has stopped streaming, e.g. while transmitting
+			if isTalking {
+				m.sendVoicePacket(sequence, nil, true)
+				isTalking, pcm = false, pcm[:0]
+			}
+			continue
+		}
+
+		for len(pcm) >= mumbleFrameSize {
+			frame, err := encoder.Encode(pcm[:mumbleFrameSize], mumbleFrameSize, 1000)
+			pcm = pcm[mumbleFrameSize:]
+			if err != nil {
+				log.Debugf("Mumble: %s\n", err)
+				continue
+			}
+			// counted in 10 ms frames
+			sequence += 2
+			if err := m.sendVoicePacket(sequence, frame, false); err != nil {
+				return
+			}
+			isTalking = true
+		}
+	}
+}
+
+func (m *Mumble) sendVoicePacket(sequence uint64, frame []byte, isLast bool) error {
+	header := uint64(len(frame))
+	if isLast {
+		header |= mumbleOpusTerminator
+	}
+
+	packet := []byte{mumbleVoiceOpus << 5}
+	packet = appendMumbleVarint(packet, sequence)
+	packet = appendMumbleVarint(packet, header)
+
+	return m.send(mumbleMsgUDPTunnel, append(packet, frame...))
+}
+
+func (m *Mumble) Close() {
+	if m == nil {
+		return
+	}
+
+	m.closed.Store(true)
+	m.mu.Lock()
+	if m.conn != nil {
+		m.conn.Close()
+	}
+	m.mu.Unlock()
+	m.tx.Close()
+}
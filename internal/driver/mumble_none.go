@@ -0,0 +1,21 @@
+//go:build !mumble
+
+package driver
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Mumble struct{}
+
+func startMumbleFromEnv(ss *SerialStream) *Mumble {
+	if os.Getenv("MUMBLE_SERVER") != "" {
+		log.Warnln("MUMBLE_SERVER needs a build with the mumble tag")
+	}
+
+	return nil
+}
+
+func (m *Mumble) Close() {}
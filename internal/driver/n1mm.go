@@ -0,0 +1,275 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The UDP datagrams of the N1MM+ logger, which other contest loggers speak
+// too. Frequencies are in tens of Hz.
+
+const n1mmApp = "trusdx-go"
+
+type n1mmRadioInfo struct {
+	XMLName        xml.Name `xml:"RadioInfo"`
+	App            string   `xml:"app"`
+	StationName    string
+	RadioNr        int
+	Freq           int64
+	TXFreq         int64
+	Mode           string
+	IsRunning      string
+	FocusRadioNr   int
+	ActiveRadioNr  int
+	IsTransmitting string
+	RadioName      string
+	IsConnected    string
+}
+
+type n1mmContactInfo struct {
+	XMLName     xml.Name `xml:"contactinfo"`
+	App         string   `xml:"app"`
+	StationName string   `xml:"StationName"`
+	Call        string   `xml:"call"`
+	Band        string   `xml:"band"`
+	RxFreq      int64    `xml:"rxfreq"`
+	Mode        string   `xml:"mode"`
+}
+
+// the modes of the rig as N1MM+ names them
+var n1mmModes = map[byte]string{
+	'1': "LSB",
+	'2': "USB",
+	'3': "CW",
+	'4': "FM",
+	'5': "AM",
+	'6': "RTTY",
+	'7': "CW",
+	'9': "RTTY",
+}
+
+func n1mmBool(value bool) string {
+	if value {
+		return "True"
+	}
+
+	return "False"
+}
+
+// N1mm sends the RadioInfo of the rig to the loggers on N1MM_SEND and takes
+// the datagrams of the loggers on N1MM_LISTEN: the RadioInfo of the station
+// in N1MM_FOLLOW tunes the rig, the contacts are logged.
+type N1mm struct {
+	ss       *SerialStream
+	station  string
+	follow   string
+	sender   net.PacketConn
+	targets  []*net.UDPAddr
+	listener net.PacketConn
+	access   *AccessList
+	mu       sync.Mutex
+	stop     chan struct{}
+}
+
+// startN1mmFromEnv returns nil when neither N1MM_SEND nor N1MM_LISTEN is
+// set.
+func startN1mmFromEnv(ss *SerialStream) *N1mm {
+	send := strings.TrimSpace(os.Getenv("N1MM_SEND"))
+	listen := strings.TrimSpace(os.Getenv("N1MM_LISTEN"))
+	if send == "" && listen == "" {
+		return nil
+	}
+
+	station, ok := os.LookupEnv("N1MM_STATION")
+	if !ok {
+		station, _ = os.Hostname()
+	}
+	n := &N1mm{ss: ss, station: station, follow: os.Getenv("N1MM_FOLLOW"), stop: make(chan struct{})}
+
+	if send != "" {
+		targets, err := resolveUdpAddresses(send)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		n.targets = targets
+		sender, err := openUdpSender()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		n.sender = sender
+		ss.Events.Subscribe(n.onEvent)
+		go n.sendPeriodically(envDuration("N1MM_INTERVAL", 5*time.Second))
+	}
+
+	if listen != "" {
+		listener, err := net.ListenPacket("udp", listen)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		n.listener = listener
+		n.access = newAccessListFromEnv("N1MM")
+		log.Printf("N1MM+ datagrams on udp://%s\n", listener.LocalAddr())
+		go n.receive()
+	}
+
+	return n
+}
+
+func (n *N1mm) onEvent(event Event) {
+	switch event.(type) {
+	case FrequencyChanged, ModeChanged, PttChanged, ConnectionChanged:
+		n.sendRadioInfo()
+	}
+}
+
+// sendPeriodically keeps the loggers started later in sync.
+func (n *N1mm) sendPeriodically(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-ticker.C:
+			n.sendRadioInfo()
+		}
+	}
+}
+
+func (n *N1mm) sendRadioInfo() {
+	frequency := n.ss.State.Frequency()
+	if frequency == 0 {
+		return
+	}
+	info := n1mmRadioInfo{
+		App:            n1mmApp,
+		StationName:    n.station,
+		RadioNr:        1,
+		Freq:           frequency / 10,
+		TXFreq:         frequency / 10,
+		Mode:           n1mmModes[n.ss.State.Mode()],
+		IsRunning:      n1mmBool(false),
+		FocusRadioNr:   1,
+		ActiveRadioNr:  1,
+		IsTransmitting: n1mmBool(n.ss.State.Transmitting()),
+		RadioName:      "truSDX",
+		IsConnected:    n1mmBool(n.ss.IsRunning()),
+	}
+	data, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		log.Warnf("N1MM+: %s\n", err)
+		return
+	}
+	data = append([]byte(xml.Header), data...)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, target := range n.targets {
+		if _, err := n.sender.WriteTo(data, target); err != nil {
+			log.Warnf("N1MM+ %s: %s\n", target, err)
+		}
+	}
+}
+
+func (n *N1mm) receive() {
+	buffer := make([]byte, 65536)
+	for {
+		count, from, err := n.listener.ReadFrom(buffer)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			log.Warnf("N1MM+: %s\n", err)
+			continue
+		}
+		if !n.access.Allows(from) {
+			continue
+		}
+		if err := n.handle(buffer[:count]); err != nil {
+			log.Debugf("[N1MM+]: datagram from %s ignored, %s\n", from, err)
+		}
+	}
+}
+
+// handle takes a datagram, the ones which aren't about the radio or the
+// contacts are ignored.
+func (n *N1mm) handle(data []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "RadioInfo":
+			var info n1mmRadioInfo
+			if err := decoder.DecodeElement(&info, &start); err != nil {
+				return err
+			}
+			n.followRadio(info)
+		case "contactinfo":
+			var contact n1mmContactInfo
+			if err := decoder.DecodeElement(&contact, &start); err != nil {
+				return err
+			}
+			log.Infof("N1MM+ contact: %s on %d Hz %s, logged by %s\n",
+				contact.Call, contact.RxFreq*10, contact.Mode, contact.StationName)
+		}
+		return nil
+	}
+}
+
+// followRadio tunes the rig to the radio of the followed station.
+func (n *N1mm) followRadio(info n1mmRadioInfo) {
+	if n.follow == "" || info.App == n1mmApp || !strings.EqualFold(info.StationName, n.follow) {
+		return
+	}
+
+	cmd := ""
+	if frequency := info.Freq * 10; frequency > 0 && frequency != n.ss.State.Frequency() {
+		cmd += fmt.Sprintf("FA%011d;", frequency)
+	}
+	if info.Mode != n1mmModes[n.ss.State.Mode()] {
+		for mode, name := range n1mmModes {
+			// the reverse modes are never picked
+			if name == info.Mode && mode != '7' && mode != '9' {
+				cmd += fmt.Sprintf("MD%c;", mode)
+			}
+		}
+	}
+	if cmd != "" {
+		log.Debugf("[N1MM+]: following %s, %s\n", info.StationName, cmd)
+		n.ss.PushCommand(cmd)
+	}
+}
+
+func (n *N1mm) Close() {
+	if n == nil {
+		return
+	}
+
+	close(n.stop)
+	if n.sender != nil {
+		n.sender.Close()
+	}
+	if n.listener != nil {
+		n.listener.Close()
+	}
+}
@@ -0,0 +1,317 @@
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// NetworkTx takes the audio to transmit from network clients instead of the
+// sound card, as raw 8-bit unsigned mono samples at 11520 Hz.
+type NetworkTx struct {
+	ss       *SerialStream
+	withPtt  bool
+	pttGap   time.Duration
+	mu       sync.Mutex
+	isKeyed  bool
+	releaser *time.Timer
+	keyings  uint64 // a timer of an earlier keying doesn't release a new one
+	closers  []io.Closer
+	access   *AccessList
+	token    string // TX_AUDIO_TOKEN, needed from the network
+}
+
+// the TX audio is sent in the same chunks as from the sound card
+const networkTxChunk = dataChunkLength
+
+// how long a TCP client has for sending the token
+const networkTxTokenTimeout = 5 * time.Second
+
+func NewNetworkTx(ss *SerialStream) *NetworkTx {
+	return &NetworkTx{
+		ss:      ss,
+		withPtt: envInt("TX_AUDIO_PTT", 0) != 0,
+		pttGap:  envDuration("TX_AUDIO_PTT_GAP", 300*time.Millisecond),
+	}
+}
+
+// startNetworkTxFromEnv listens on the addresses in TX_AUDIO_LISTEN, it
+// returns nil when there are none.
+func startNetworkTxFromEnv(ss *SerialStream) *NetworkTx {
+	spec, ok := os.LookupEnv("TX_AUDIO_LISTEN")
+	if !ok || strings.TrimSpace(spec) == "" {
+		return nil
+	}
+
+	nt := NewNetworkTx(ss)
+	nt.access = newAccessListFromEnv("TX_AUDIO")
+	nt.token = os.Getenv("TX_AUDIO_TOKEN")
+	for _, address := range strings.Split(spec, ",") {
+		if err := nt.Listen(strings.TrimSpace(address)); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	return nt
+}
+
+// loopbackByDefault binds an address without a host, e.g. :7356, to the
+// loopback interface, listening on the network has to be asked for.
+func loopbackByDefault(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err == nil && host == "" {
+		return net.JoinHostPort("127.0.0.1", port)
+	}
+
+	return address
+}
+
+func isLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// Listen takes the audio from tcp://host:port, udp://host:port,
+// ws://host:port/path or fifo:///path, a named pipe. Without a host, the
+// network ones listen on the loopback interface only, and anything else
+// needs the token, as anyone who can reach it can key the rig.
+func (nt *NetworkTx) Listen(address string) error {
+	u, err := url.Parse(address)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "fifo" {
+		u.Host = loopbackByDefault(u.Host)
+		if !isLoopbackAddress(u.Host) && nt.token == "" {
+			return fmt.Errorf("TX audio from %s needs TX_AUDIO_TOKEN, anyone on the network could transmit", address)
+		}
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		listener, err := net.Listen("tcp", u.Host)
+		if err != nil {
+			return err
+		}
+		listener = nt.access.Listener(listener)
+		nt.addCloser(listener)
+		go nt.serveTcp(listener)
+	case "udp":
+		conn, err := net.ListenPacket("udp", u.Host)
+		if err != nil {
+			return err
+		}
+		nt.addCloser(conn)
+		go nt.serveUdp(conn)
+	case "ws":
+		path := u.Path
+		if path == "" {
+			path = "/"
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc(path, nt.serveWebSocket)
+		listener, err := net.Listen("tcp", u.Host)
+		if err != nil {
+			return err
+		}
+		server := &http.Server{Handler: mux}
+		nt.addCloser(server)
+		go server.Serve(nt.access.Listener(listener))
+	case "fifo":
+		if err := makeFifo(u.Path); err != nil {
+			return err
+		}
+		go nt.serveFifo(u.Path)
+	default:
+		return fmt.Errorf("unsupported TX audio address %q, expected tcp://, udp://, ws:// or fifo://", address)
+	}
+	log.Printf("TX audio from %s\n", u)
+
+	return nil
+}
+
+func (nt *NetworkTx) addCloser(closer io.Closer) {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	nt.closers = append(nt.closers, closer)
+}
+
+func (nt *NetworkTx) serveTcp(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			log.Errorln(err)
+			continue
+		}
+		go func() {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			if !nt.readToken(conn, reader) {
+				log.Warnf("TX audio client %s refused, wrong token\n", conn.RemoteAddr())
+				return
+			}
+			log.Infof("TX audio client %s connected\n", conn.RemoteAddr())
+			buffer := make([]byte, networkTxChunk)
+			for {
+				n, err := io.ReadFull(reader, buffer)
+				nt.write(buffer[:n])
+				if err != nil {
+					break
+				}
+			}
+			log.Infof("TX audio client %s disconnected\n", conn.RemoteAddr())
+		}()
+	}
+}
+
+func (nt *NetworkTx) serveUdp(conn net.PacketConn) {
+	buffer := make([]byte, 65536)
+	for {
+		n, from, err := conn.ReadFrom(buffer)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			log.Errorln(err)
+			continue
+		}
+		if !nt.access.Allows(from) {
+			continue
+		}
+		samples := buffer[:n]
+		// every datagram carries the token, there's no connection to
+		// remember it
+		if nt.token != "" {
+			var ok bool
+			if samples, ok = bytes.CutPrefix(samples, []byte(nt.token+"\n")); !ok {
+				log.Debugf("TX audio datagram from %s dropped, wrong token\n", from)
+				continue
+			}
+		}
+		nt.write(samples)
+	}
+}
+
+// readToken takes the first line of a TCP client, which is the token when
+// TX_AUDIO_TOKEN is set.
+func (nt *NetworkTx) readToken(conn net.Conn, reader *bufio.Reader) bool {
+	if nt.token == "" {
+		return true
+	}
+
+	conn.SetReadDeadline(time.Now().Add(networkTxTokenTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(strings.TrimRight(line, "\r\n")), []byte(nt.token)) == 1
+}
+
+func (nt *NetworkTx) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	if nt.token != "" && !hasToken(r, nt.token) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		log.Warnln(err)
+		return
+	}
+	defer ws.Close()
+
+	log.Infof("TX audio client %s connected\n", r.RemoteAddr)
+	for {
+		message, err := ws.ReadMessage()
+		if err != nil {
+			break
+		}
+		nt.write(message)
+	}
+	log.Infof("TX audio client %s disconnected\n", r.RemoteAddr)
+}
+
+// write queues the samples for the rig, keying it first with TX_AUDIO_PTT.
+func (nt *NetworkTx) write(samples []byte) {
+	if len(samples) == 0 {
+		return
+	}
+	if nt.withPtt {
+		nt.key()
+	}
+
+	for len(samples) > 0 {
+		n := networkTxChunk
+		if n > len(samples) {
+			n = len(samples)
+		}
+		chunk := make([]byte, n)
+		copy(chunk, samples)
+		samples = samples[n:]
+		nt.ss.AudioInBuf <- chunk
+	}
+}
+
+// key keeps the rig transmitting until the audio stops for the gap.
+func (nt *NetworkTx) key() {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+
+	if !nt.isKeyed {
+		nt.isKeyed = true
+		nt.ss.PushCommand("TX")
+	}
+	if nt.releaser != nil {
+		nt.releaser.Stop()
+	}
+	// Stop doesn't help once the timer has fired and waits for the lock
+	nt.keyings++
+	keying := nt.keyings
+	nt.releaser = time.AfterFunc(nt.pttGap, func() {
+		nt.mu.Lock()
+		defer nt.mu.Unlock()
+		if nt.keyings != keying || !nt.isKeyed {
+			return
+		}
+		nt.isKeyed = false
+		nt.ss.PushCommand("RX")
+	})
+}
+
+func (nt *NetworkTx) Close() {
+	if nt == nil {
+		return
+	}
+
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	for _, closer := range nt.closers {
+		closer.Close()
+	}
+	nt.keyings++
+	if nt.releaser != nil {
+		nt.releaser.Stop()
+	}
+}
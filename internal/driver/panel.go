@@ -0,0 +1,245 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// the modes m and M cycle through, the reverse ones aren't worth a key
+var panelModes = []string{"LSB", "USB", "CW", "FM", "AM"}
+
+const panelHelp = "←→ tune  ↑↓ step  1-9,0 160m-10m  b/B band  m/M mode  q quit"
+
+type panelKey int
+
+const (
+	keyLeft panelKey = iota + 256
+	keyRight
+	keyUp
+	keyDown
+)
+
+// parsePanelSteps takes a comma separated list of steps in Hz, a k suffix
+// for kHz, e.g. 10,100,1k,10k.
+func parsePanelSteps(text string) ([]int64, error) {
+	var steps []int64
+	for _, item := range strings.Split(text, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		multiplier := int64(1)
+		if number, ok := strings.CutSuffix(item, "k"); ok {
+			item, multiplier = number, 1000
+		}
+		step, err := strconv.ParseInt(item, 10, 64)
+		if err != nil || step <= 0 {
+			return nil, fmt.Errorf("invalid step %q", item)
+		}
+		steps = append(steps, step*multiplier)
+	}
+
+	return steps, nil
+}
+
+// tuneBy moves the frequency to the next multiple of the step, as the knob
+// of a rig does, so the steps land on round frequencies.
+func tuneBy(frequency, step int64, up bool) int64 {
+	if up {
+		return (frequency/step + 1) * step
+	}
+	if frequency%step != 0 {
+		return frequency / step * step
+	}
+
+	return frequency - step
+}
+
+// formatPanelFrequency groups the digits like the display of a rig,
+// 14.074.000.
+func formatPanelFrequency(frequency int64) string {
+	return fmt.Sprintf("%d.%03d.%03d", frequency/1000000, frequency/1000%1000, frequency%1000)
+}
+
+// readPanelKeys turns the bytes of the terminal into keys, the arrows come
+// as escape sequences.
+func readPanelKeys(keys chan<- panelKey) {
+	defer close(keys)
+
+	buffer := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buffer)
+		if err != nil {
+			return
+		}
+		input := buffer[:n]
+		for len(input) > 0 {
+			if len(input) >= 3 && input[0] == 0x1b && (input[1] == '[' || input[1] == 'O') {
+				switch input[2] {
+				case 'A':
+					keys <- keyUp
+				case 'B':
+					keys <- keyDown
+				case 'C':
+					keys <- keyRight
+				case 'D':
+					keys <- keyLeft
+				}
+				input = input[3:]
+				continue
+			}
+			keys <- panelKey(input[0])
+			input = input[1:]
+		}
+	}
+}
+
+// panel is the state shown on the line of the front panel.
+type panel struct {
+	frequency int64
+	mode      string
+	ptt       string
+	steps     []int64
+	step      int
+	message   string
+}
+
+// refresh reads the state of the rig through the driver.
+func (p *panel) refresh() error {
+	output, err := controlRequest([]string{"get", "freq", "mode", "ptt"})
+	if err != nil {
+		return err
+	}
+
+	values := strings.Fields(output)
+	if len(values) != 3 {
+		return fmt.Errorf("unexpected answer %q", output)
+	}
+	if p.frequency, err = strconv.ParseInt(values[0], 10, 64); err != nil {
+		return fmt.Errorf("unexpected frequency %q", values[0])
+	}
+	p.mode, p.ptt = values[1], values[2]
+
+	return nil
+}
+
+func (p *panel) set(name, value string) {
+	if _, err := controlRequest([]string{"set", name, value}); err != nil {
+		p.message = err.Error()
+	}
+}
+
+// handle acts on a key, false is to quit.
+func (p *panel) handle(key panelKey) bool {
+	p.message = ""
+	switch {
+	case key == 'q' || key == 'Q' || key == 3: // Ctrl-C
+		return false
+	case key == keyLeft || key == keyRight:
+		p.frequency = tuneBy(p.frequency, p.steps[p.step], key == keyRight)
+		p.set("freq", strconv.FormatInt(p.frequency, 10))
+	case key == keyUp && p.step < len(p.steps)-1:
+		p.step++
+	case key == keyDown && p.step > 0:
+		p.step--
+	case key >= '0' && key <= '9':
+		// 1 is 160m and 0 is 10m, in the order of the keys
+		index := int(key-'0'+9) % 10
+		if index < len(hamBands) {
+			p.frequency = hamBands[index].home
+			p.set("freq", strconv.FormatInt(p.frequency, 10))
+		}
+	case key == 'b' || key == 'B':
+		p.frequency = nextBand(p.frequency, key == 'b').home
+		p.set("freq", strconv.FormatInt(p.frequency, 10))
+	case key == 'm' || key == 'M':
+		index := 0
+		for i, mode := range panelModes {
+			if mode == p.mode {
+				index = i
+			}
+		}
+		if key == 'm' {
+			index = (index + 1) % len(panelModes)
+		} else {
+			index = (index + len(panelModes) - 1) % len(panelModes)
+		}
+		p.mode = panelModes[index]
+		p.set("mode", p.mode)
+	}
+
+	return true
+}
+
+// draw rewrites the line of the panel in place.
+func (p *panel) draw() {
+	band, ok := findBand(p.frequency)
+	if !ok {
+		band.name = "-"
+	}
+	direction := "RX"
+	if p.ptt == "on" {
+		direction = "TX"
+	}
+	fmt.Printf("\r\033[K%s Hz  %-4s %-4s %s  step %s Hz  %s",
+		formatPanelFrequency(p.frequency), p.mode, band.name, direction, strconv.FormatInt(p.steps[p.step], 10), p.message)
+}
+
+// runPanel is a minimal front panel in the terminal, tuning the rig with
+// the keyboard through the running driver.
+func runPanel(args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	text, ok := os.LookupEnv("PANEL_STEPS")
+	if !ok {
+		text = "10,100,1k,10k"
+	}
+	steps, err := parsePanelSteps(text)
+	if err != nil {
+		return err
+	}
+	p := &panel{steps: steps, step: len(steps) / 2}
+	if err := p.refresh(); err != nil {
+		return err
+	}
+
+	// the keys come one by one, without echo, and Ctrl-C as a key so the
+	// terminal is always restored
+	saved, err := getTermios(os.Stdin)
+	if err != nil {
+		return errors.New("the panel needs a terminal")
+	}
+	raw := *saved
+	raw.Lflag &^= unix.ICANON | unix.ECHO | unix.ISIG
+	raw.Cc[unix.VMIN], raw.Cc[unix.VTIME] = 1, 0
+	if err := setTermios(os.Stdin, &raw); err != nil {
+		return err
+	}
+	defer setTermios(os.Stdin, saved)
+
+	fmt.Println(panelHelp)
+	keys := make(chan panelKey)
+	go readPanelKeys(keys)
+	// changes made elsewhere, e.g. on the rig itself, show up
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		p.draw()
+		select {
+		case key, ok := <-keys:
+			if !ok || !p.handle(key) {
+				fmt.Println()
+				return nil
+			}
+		case <-ticker.C:
+			if err := p.refresh(); err != nil {
+				p.message = err.Error()
+			}
+		}
+	}
+}
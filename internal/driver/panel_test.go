@@ -0,0 +1,54 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePanelSteps(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    []int64
+		wantErr bool
+	}{
+		{text: "10,100,1k,10k", want: []int64{10, 100, 1000, 10000}},
+		{text: " 50 , 2.5k", wantErr: true},
+		{text: "500,5K", want: []int64{500, 5000}},
+		{text: "100", want: []int64{100}},
+		{text: "0", wantErr: true},
+		{text: "-10", wantErr: true},
+		{text: "10,,100", wantErr: true},
+		{text: "k", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePanelSteps(tt.text)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePanelSteps(%q) = %v, %v, want %v, error %v", tt.text, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestTuneBy(t *testing.T) {
+	tests := []struct {
+		frequency int64
+		step      int64
+		up        bool
+		want      int64
+	}{
+		{frequency: 7074000, step: 1000, up: true, want: 7075000},
+		{frequency: 7074000, step: 1000, up: false, want: 7073000},
+		{frequency: 7074123, step: 1000, up: true, want: 7075000},
+		{frequency: 7074123, step: 1000, up: false, want: 7074000},
+		{frequency: 7074123, step: 10, up: true, want: 7074130},
+		{frequency: 7074123, step: 10, up: false, want: 7074120},
+		{frequency: 14074000, step: 10000, up: true, want: 14080000},
+		{frequency: 14074000, step: 10000, up: false, want: 14070000},
+	}
+
+	for _, tt := range tests {
+		if got := tuneBy(tt.frequency, tt.step, tt.up); got != tt.want {
+			t.Errorf("tuneBy(%d, %d, %v) = %d, want %d", tt.frequency, tt.step, tt.up, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"sync"
+	"time"
+)
+
+// Every captured packet starts with a direction byte and a kind byte,
+// followed by the CAT message or the audio samples as they go over the wire.
+const (
+	pcapToRig   byte = 0
+	pcapFromRig byte = 1
+	pcapCat     byte = 'C'
+	pcapAudio   byte = 'A'
+
+	pcapLinkTypeUser0 = 147
+)
+
+const (
+	pcapBlockSection   = 0x0A0D0D0A
+	pcapBlockInterface = 0x00000001
+	pcapBlockPacket    = 0x00000006
+	pcapOptionFlags    = 2
+	pcapFlagInbound    = 1
+	pcapFlagOutbound   = 2
+)
+
+// PcapWriter writes the traffic in the pcapng format. A nil writer
+// discards everything.
+type PcapWriter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewPcapWriter(path string) (*PcapWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	pw := &PcapWriter{file: file}
+
+	section := new(bytes.Buffer)
+	binary.Write(section, binary.LittleEndian, uint32(0x1A2B3C4D)) // byte order magic
+	binary.Write(section, binary.LittleEndian, uint16(1))          // major version
+	binary.Write(section, binary.LittleEndian, uint16(0))          // minor version
+	binary.Write(section, binary.LittleEndian, int64(-1))          // unknown section length
+	if err := pw.writeBlock(pcapBlockSection, section.Bytes()); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	iface := new(bytes.Buffer)
+	binary.Write(iface, binary.LittleEndian, uint16(pcapLinkTypeUser0))
+	binary.Write(iface, binary.LittleEndian, uint16(0)) // reserved
+	binary.Write(iface, binary.LittleEndian, uint32(0)) // no snap length
+	if err := pw.writeBlock(pcapBlockInterface, iface.Bytes()); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return pw, nil
+}
+
+func (pw *PcapWriter) writeBlock(blockType uint32, body []byte) error {
+	length := uint32(12 + len(body))
+	block := new(bytes.Buffer)
+	binary.Write(block, binary.LittleEndian, blockType)
+	binary.Write(block, binary.LittleEndian, length)
+	block.Write(body)
+	binary.Write(block, binary.LittleEndian, length)
+
+	_, err := pw.file.Write(block.Bytes())
+	return err
+}
+
+func (pw *PcapWriter) Write(direction byte, kind byte, data []byte) {
+	if pw == nil {
+		return
+	}
+
+	timestamp := uint64(time.Now().UnixMicro())
+	packetLength := 2 + len(data)
+	flags := uint32(pcapFlagOutbound)
+	if direction == pcapFromRig {
+		flags = pcapFlagInbound
+	}
+
+	body := new(bytes.Buffer)
+	binary.Write(body, binary.LittleEndian, uint32(0)) // interface
+	binary.Write(body, binary.LittleEndian, uint32(timestamp>>32))
+	binary.Write(body, binary.LittleEndian, uint32(timestamp))
+	binary.Write(body, binary.LittleEndian, uint32(packetLength))
+	binary.Write(body, binary.LittleEndian, uint32(packetLength))
+	body.WriteByte(direction)
+	body.WriteByte(kind)
+	body.Write(data)
+	for body.Len()%4 != 0 {
+		body.WriteByte(0)
+	}
+	binary.Write(body, binary.LittleEndian, uint16(pcapOptionFlags))
+	binary.Write(body, binary.LittleEndian, uint16(4))
+	binary.Write(body, binary.LittleEndian, flags)
+	binary.Write(body, binary.LittleEndian, uint32(0)) // end of options
+
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	pw.writeBlock(pcapBlockPacket, body.Bytes())
+}
+
+func (pw *PcapWriter) Close() error {
+	if pw == nil {
+		return nil
+	}
+
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	return pw.file.Close()
+}
@@ -0,0 +1,105 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type pcapBlock struct {
+	blockType uint32
+	body      []byte
+}
+
+// readPcapBlocks splits a pcapng file into its blocks, checking the
+// lengths around each of them.
+func readPcapBlocks(t *testing.T, data []byte) []pcapBlock {
+	var blocks []pcapBlock
+	for len(data) > 0 {
+		if len(data) < 12 {
+			t.Fatalf("%d bytes left, too few for a block", len(data))
+		}
+		length := binary.LittleEndian.Uint32(data[4:8])
+		if length%4 != 0 || int(length) > len(data) {
+			t.Fatalf("invalid block length %d", length)
+		}
+		if trailing := binary.LittleEndian.Uint32(data[length-4 : length]); trailing != length {
+			t.Fatalf("block length %d at the end, %d at the start", trailing, length)
+		}
+		blocks = append(blocks, pcapBlock{binary.LittleEndian.Uint32(data[0:4]), data[8 : length-4]})
+		data = data[length:]
+	}
+
+	return blocks
+}
+
+func TestPcapWriter(t *testing.T) {
+	packets := []struct {
+		direction byte
+		kind      byte
+		data      []byte
+		wantFlags uint32
+	}{
+		{direction: pcapToRig, kind: pcapCat, data: []byte("FA;"), wantFlags: pcapFlagOutbound},
+		{direction: pcapFromRig, kind: pcapCat, data: []byte("FA00007074000;"), wantFlags: pcapFlagInbound},
+		{direction: pcapFromRig, kind: pcapAudio, data: []byte{0x80, 0x81}, wantFlags: pcapFlagInbound},
+		{direction: pcapToRig, kind: pcapAudio, data: nil, wantFlags: pcapFlagOutbound},
+	}
+
+	path := filepath.Join(t.TempDir(), "trace.pcapng")
+	pw, err := NewPcapWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range packets {
+		pw.Write(p.direction, p.kind, p.data)
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := readPcapBlocks(t, data)
+	if len(blocks) != 2+len(packets) {
+		t.Fatalf("%d blocks, want %d", len(blocks), 2+len(packets))
+	}
+	if blocks[0].blockType != pcapBlockSection || binary.LittleEndian.Uint32(blocks[0].body) != 0x1A2B3C4D {
+		t.Errorf("the first block isn't a little endian section header")
+	}
+	if blocks[1].blockType != pcapBlockInterface || binary.LittleEndian.Uint16(blocks[1].body) != pcapLinkTypeUser0 {
+		t.Errorf("the second block isn't the interface")
+	}
+
+	for i, p := range packets {
+		block := blocks[2+i]
+		if block.blockType != pcapBlockPacket {
+			t.Errorf("packet %d: block type %#x", i, block.blockType)
+			continue
+		}
+		captured := binary.LittleEndian.Uint32(block.body[12:16])
+		payload := block.body[20 : 20+captured]
+		want := append([]byte{p.direction, p.kind}, p.data...)
+		if !bytes.Equal(payload, want) {
+			t.Errorf("packet %d: % x, want % x", i, payload, want)
+		}
+
+		options := block.body[20+(captured+3)/4*4:]
+		if len(options) != 12 || binary.LittleEndian.Uint16(options[0:2]) != pcapOptionFlags ||
+			binary.LittleEndian.Uint32(options[4:8]) != p.wantFlags {
+			t.Errorf("packet %d: options % x, want the flags %d", i, options, p.wantFlags)
+		}
+	}
+}
+
+func TestPcapWriterNil(t *testing.T) {
+	var pw *PcapWriter
+	pw.Write(pcapToRig, pcapCat, []byte("FA;"))
+	if err := pw.Close(); err != nil {
+		t.Errorf("Close() of a nil writer = %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package driver
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// startPipeAudio writes the audio of the rig to stdout and transmits the
+// audio from stdin, raw 8-bit unsigned mono at the RX and TX sample rates.
+// The logs go to stderr as always.
+func startPipeAudio(ss *SerialStream) *NetworkTx {
+	log.Printf("RX audio to stdout at %d Hz, TX audio from stdin at %d Hz\n", ss.RxRate(), ss.TxRate())
+
+	go func() {
+		for isRunning() {
+			if _, err := os.Stdout.Write(<-ss.AudioOutBuf); err != nil {
+				log.Errorln(err)
+				// nothing reads it anymore, but the rig keeps streaming
+				for isRunning() {
+					<-ss.AudioOutBuf
+				}
+			}
+		}
+	}()
+
+	nt := NewNetworkTx(ss)
+	go func() {
+		nt.readFrom(os.Stdin, make([]byte, networkTxChunk))
+		log.Infoln("TX audio from stdin ended")
+	}()
+
+	return nt
+}
@@ -0,0 +1,85 @@
+package driver
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrPlaying = errors.New("already transmitting a recording or a tone")
+
+// Play keys the rig and transmits the samples in place of the sound card
+// audio, looped until the duration passes if loop is set. It returns right
+// away, the returned channel is closed when the playback ends.
+func (ss *SerialStream) Play(samples []byte, loop bool, duration time.Duration) (<-chan struct{}, error) {
+	ss.playMu.Lock()
+	defer ss.playMu.Unlock()
+	if ss.playStop != nil {
+		return nil, ErrPlaying
+	}
+	stop := make(chan struct{})
+	ss.playStop = stop
+
+	// whole chunks only, padded with silence
+	samples = append([]byte(nil), samples...)
+	for len(samples)%dataChunkLength != 0 {
+		samples = append(samples, 128)
+	}
+
+	ss.isPlaying.Store(true)
+	ss.PushCommand("TX")
+
+	go func() {
+		ticker := time.NewTicker(time.Second * dataChunkLength / time.Duration(ss.TxRate()))
+		defer ticker.Stop()
+		var timeout <-chan time.Time
+		if loop {
+			timeout = time.After(duration)
+		}
+
+		for i := 0; loop || i < len(samples); i += dataChunkLength {
+			if loop {
+				i %= len(samples)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-timeout:
+				ss.stopPlayback(stop)
+				return
+			case <-stop:
+				return
+			}
+
+			select {
+			case ss.playBuf <- samples[i : i+dataChunkLength]:
+			default:
+			}
+		}
+
+		// let the queued chunks go out before going back to RX
+		for i := 0; i <= cap(ss.playBuf); i++ {
+			<-ticker.C
+		}
+		ss.stopPlayback(stop)
+	}()
+
+	return stop, nil
+}
+
+func (ss *SerialStream) StopPlayback() {
+	ss.stopPlayback(nil)
+}
+
+// stopPlayback stops the given playback, or any if it's nil.
+func (ss *SerialStream) stopPlayback(stop chan struct{}) {
+	ss.playMu.Lock()
+	defer ss.playMu.Unlock()
+	if ss.playStop == nil || (stop != nil && ss.playStop != stop) {
+		return
+	}
+
+	close(ss.playStop)
+	ss.playStop = nil
+	ss.PushCommand("RX")
+	ss.isPlaying.Store(false)
+}
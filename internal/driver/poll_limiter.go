@@ -0,0 +1,148 @@
+package driver
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// queries which are safe to coalesce and to answer from a recent reply
+var pollQueries = map[string]bool{
+	"FA":  true,
+	"FB":  true,
+	"IF":  true,
+	"MD":  true,
+	"SM0": true,
+}
+
+type pollEntry struct {
+	reply     []byte
+	repliedAt time.Time
+	sentAt    time.Time
+	inFlight  bool
+	waiting   []*CatClient
+}
+
+type PollLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	patience func(cmd string) time.Duration
+	entries  map[string]*pollEntry
+}
+
+// patience tells how long the rig may take to answer a query, retries
+// included.
+func NewPollLimiter(interval time.Duration, patience func(cmd string) time.Duration) *PollLimiter {
+	pl := new(PollLimiter)
+	pl.interval = interval
+	pl.patience = patience
+	pl.entries = make(map[string]*pollEntry)
+
+	return pl
+}
+
+// Admit decides what to do with a command coming from a client. It returns
+// a cached reply when the query has been answered recently, and forward is
+// false when the rig shouldn't see the command at all.
+func (pl *PollLimiter) Admit(cmd string, client *CatClient) (reply []byte, forward bool) {
+	if pl.interval <= 0 {
+		return nil, true
+	}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if !pollQueries[cmd] {
+		// a set command may change the rig state, so cached replies are
+		// stale now, unlike other queries
+		if cmd != "" && !isQuery(cmd) {
+			for _, entry := range pl.entries {
+				entry.reply = nil
+			}
+		}
+		return nil, true
+	}
+
+	entry, ok := pl.entries[cmd]
+	if !ok {
+		entry = new(pollEntry)
+		pl.entries[cmd] = entry
+	}
+
+	now := time.Now()
+	if entry.inFlight && now.Sub(entry.sentAt) < pl.patience(cmd) {
+		entry.waiting = append(entry.waiting, client)
+		return nil, false
+	}
+
+	if entry.reply != nil && now.Sub(entry.repliedAt) < pl.interval {
+		return entry.reply, false
+	}
+
+	entry.inFlight = true
+	entry.waiting = nil
+	entry.sentAt = now
+
+	return nil, true
+}
+
+// Resolve records a reply from the rig to the client and returns the
+// clients waiting for it, that one included.
+func (pl *PollLimiter) Resolve(reply []byte, client *CatClient) []*CatClient {
+	clients := []*CatClient{client}
+	if pl.interval <= 0 || len(reply) < 2 {
+		return clients
+	}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	entry, ok := pl.entries[pollQueryOf(reply)]
+	if !ok {
+		return clients
+	}
+
+	if entry.inFlight {
+		clients = append(clients, entry.waiting...)
+	}
+	entry.inFlight = false
+	entry.waiting = nil
+	entry.reply = append([]byte(nil), reply...)
+	entry.repliedAt = time.Now()
+
+	return clients
+}
+
+// pollQueryOf is the poll query the reply answers, it starts with it.
+func pollQueryOf(reply []byte) string {
+	for cmd := range pollQueries {
+		if bytes.HasPrefix(reply, []byte(cmd)) {
+			return cmd
+		}
+	}
+
+	return ""
+}
+
+// Fail gives up on a query the rig hasn't answered and returns the clients
+// waiting for it, the one which sent it included.
+func (pl *PollLimiter) Fail(cmd string, client *CatClient) []*CatClient {
+	clients := []*CatClient{client}
+	if pl.interval <= 0 {
+		return clients
+	}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	entry, ok := pl.entries[cmd]
+	if !ok || !entry.inFlight {
+		return clients
+	}
+
+	clients = append(clients, entry.waiting...)
+	entry.inFlight = false
+	entry.waiting = nil
+
+	return clients
+}
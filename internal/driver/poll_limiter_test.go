@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPollLimiter(t *testing.T) {
+	type step struct {
+		// admit, resolve or fail
+		action      string
+		cmd         string
+		client      *CatClient
+		wantForward bool
+		wantReply   string
+		wantClients int
+	}
+	a, b := &CatClient{Tag: "a"}, &CatClient{Tag: "b"}
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		steps    []step
+	}{
+		{
+			name:     "disabled",
+			interval: 0,
+			steps: []step{
+				{action: "admit", cmd: "FA", client: a, wantForward: true},
+				{action: "admit", cmd: "FA", client: b, wantForward: true},
+			},
+		},
+		{
+			name:     "coalesced while in flight",
+			interval: time.Minute,
+			steps: []step{
+				{action: "admit", cmd: "FA", client: a, wantForward: true},
+				{action: "admit", cmd: "FA", client: b, wantForward: false},
+				{action: "resolve", cmd: "FA00007074000", client: a, wantClients: 2},
+			},
+		},
+		{
+			name:     "answered from the cache",
+			interval: time.Minute,
+			steps: []step{
+				{action: "admit", cmd: "IF", client: a, wantForward: true},
+				{action: "resolve", cmd: "IF00007074000", client: a, wantClients: 1},
+				{action: "admit", cmd: "IF", client: b, wantForward: false, wantReply: "IF00007074000"},
+			},
+		},
+		{
+			name:     "stale after a set command",
+			interval: time.Minute,
+			steps: []step{
+				{action: "admit", cmd: "FA", client: a, wantForward: true},
+				{action: "resolve", cmd: "FA00007074000", client: a, wantClients: 1},
+				{action: "admit", cmd: "FA00014074000", client: a, wantForward: true},
+				{action: "admit", cmd: "FA", client: b, wantForward: true},
+			},
+		},
+		{
+			name:     "stale after keying",
+			interval: time.Minute,
+			steps: []step{
+				{action: "admit", cmd: "SM0", client: a, wantForward: true},
+				{action: "resolve", cmd: "SM00005", client: a, wantClients: 1},
+				{action: "admit", cmd: "TX", client: a, wantForward: true},
+				{action: "admit", cmd: "SM0", client: b, wantForward: true},
+			},
+		},
+		{
+			name:     "not stale after a read with a parameter",
+			interval: time.Minute,
+			steps: []step{
+				{action: "admit", cmd: "SM0", client: a, wantForward: true},
+				{action: "resolve", cmd: "SM00005", client: a, wantClients: 1},
+				{action: "admit", cmd: "AG0", client: a, wantForward: true},
+				{action: "admit", cmd: "SM0", client: b, wantForward: false, wantReply: "SM00005"},
+			},
+		},
+		{
+			name:     "not a poll query",
+			interval: time.Minute,
+			steps: []step{
+				{action: "admit", cmd: "ID", client: a, wantForward: true},
+				{action: "admit", cmd: "ID", client: b, wantForward: true},
+			},
+		},
+		{
+			name:     "failed with waiters",
+			interval: time.Minute,
+			steps: []step{
+				{action: "admit", cmd: "MD", client: a, wantForward: true},
+				{action: "admit", cmd: "MD", client: b, wantForward: false},
+				{action: "fail", cmd: "MD", client: a, wantClients: 2},
+				{action: "admit", cmd: "MD", client: b, wantForward: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl := NewPollLimiter(tt.interval, func(string) time.Duration { return time.Minute })
+			for i, s := range tt.steps {
+				switch s.action {
+				case "admit":
+					reply, forward := pl.Admit(s.cmd, s.client)
+					if forward != s.wantForward || string(reply) != s.wantReply {
+						t.Errorf("step %d: Admit(%q) = %q, %v, want %q, %v", i, s.cmd, reply, forward, s.wantReply, s.wantForward)
+					}
+				case "resolve":
+					if clients := pl.Resolve([]byte(s.cmd), s.client); len(clients) != s.wantClients {
+						t.Errorf("step %d: Resolve(%q) returned %d clients, want %d", i, s.cmd, len(clients), s.wantClients)
+					}
+				case "fail":
+					if clients := pl.Fail(s.cmd, s.client); len(clients) != s.wantClients {
+						t.Errorf("step %d: Fail(%q) returned %d clients, want %d", i, s.cmd, len(clients), s.wantClients)
+					}
+				}
+			}
+		})
+	}
+}
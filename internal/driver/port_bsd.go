@@ -0,0 +1,6 @@
+//go:build freebsd || openbsd
+
+package driver
+
+// uchcom(4) attaches the CH340 as a ucom device
+const defaultSerialPort = "/dev/cuaU0"
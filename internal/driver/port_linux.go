@@ -0,0 +1,3 @@
+package driver
+
+const defaultSerialPort = "/dev/ttyUSB0"
@@ -0,0 +1,87 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Linux input event codes of the keys a footswitch or a hotkey is likely
+// to be on, any other key can be given by its number.
+var pttKeyCodes = map[string]uint16{
+	"ESC": 1, "TAB": 15, "ENTER": 28, "SPACE": 57, "CAPSLOCK": 58,
+	"LEFTCTRL": 29, "RIGHTCTRL": 97, "LEFTALT": 56, "RIGHTALT": 100,
+	"LEFTSHIFT": 42, "RIGHTSHIFT": 54, "SCROLLLOCK": 70, "PAUSE": 119,
+	"F1": 59, "F2": 60, "F3": 61, "F4": 62, "F5": 63, "F6": 64,
+	"F7": 65, "F8": 66, "F9": 67, "F10": 68, "F11": 87, "F12": 88,
+	"A": 30, "B": 48, "C": 46, "D": 32, "E": 18, "F": 33, "G": 34,
+	"H": 35, "I": 23, "J": 36, "K": 37, "L": 38, "M": 50, "N": 49,
+	"O": 24, "P": 25, "Q": 16, "R": 19, "S": 31, "T": 20, "U": 22,
+	"V": 47, "W": 17, "X": 45, "Y": 21, "Z": 44,
+	"BTN_LEFT": 0x110, "BTN_RIGHT": 0x111, "BTN_MIDDLE": 0x112,
+}
+
+// PttInput keys the rig from a key of an input device, while it's held or
+// toggled by presses.
+type PttInput struct {
+	ss       *SerialStream
+	device   string
+	key      uint16
+	isToggle bool
+	grab     bool
+}
+
+func parsePttKey(name string) (uint16, error) {
+	if code, ok := pttKeyCodes[strings.TrimPrefix(strings.ToUpper(name), "KEY_")]; ok {
+		return code, nil
+	}
+	if code, ok := pttKeyCodes[strings.ToUpper(name)]; ok {
+		return code, nil
+	}
+	code, err := strconv.ParseUint(name, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown key %q", name)
+	}
+
+	return uint16(code), nil
+}
+
+// startPttInputFromEnv starts watching PTT_INPUT, if it's set.
+func startPttInputFromEnv(ss *SerialStream) {
+	device, ok := os.LookupEnv("PTT_INPUT")
+	if !ok {
+		return
+	}
+
+	key, err := parsePttKey(os.Getenv("PTT_KEY"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	pi := &PttInput{
+		ss:       ss,
+		device:   device,
+		key:      key,
+		isToggle: os.Getenv("PTT_KEY_MODE") == "toggle",
+		grab:     os.Getenv("PTT_INPUT_GRAB") == "1",
+	}
+	if err := pi.Start(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func (pi *PttInput) handleKey(isPressed bool) {
+	switch {
+	case pi.isToggle && isPressed && pi.ss.State.Transmitting():
+		pi.ss.PushCommand("RX")
+	case pi.isToggle && isPressed:
+		pi.ss.PushCommand("TX")
+	case pi.isToggle:
+	case isPressed:
+		pi.ss.PushCommand("TX")
+	default:
+		pi.ss.PushCommand("RX")
+	}
+}
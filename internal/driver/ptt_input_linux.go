@@ -0,0 +1,53 @@
+package driver
+
+import (
+	"io"
+	"os"
+	"unsafe"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// _IOW('E', 0x90, int), takes the device away from other programs
+const eviocgrab = 0x40044590
+
+// Start reads the events of the input device, evdev(4).
+func (pi *PttInput) Start() error {
+	device, err := os.Open(pi.device)
+	if err != nil {
+		return err
+	}
+	if pi.grab {
+		if err := unix.IoctlSetInt(int(device.Fd()), eviocgrab, 1); err != nil {
+			device.Close()
+			return err
+		}
+	}
+	log.Printf("PTT on key %d of %s\n", pi.key, pi.device)
+
+	go func() {
+		defer device.Close()
+
+		var event struct {
+			time  unix.Timeval
+			kind  uint16
+			code  uint16
+			value int32
+		}
+		buf := (*[unsafe.Sizeof(event)]byte)(unsafe.Pointer(&event))[:]
+		for {
+			if _, err := io.ReadFull(device, buf); err != nil {
+				log.Warnf("PTT input %s: %s\n", pi.device, err)
+				return
+			}
+
+			// 2 is an autorepeat
+			if event.kind == unix.EV_KEY && event.code == pi.key && event.value != 2 {
+				pi.handleKey(event.value == 1)
+			}
+		}
+	}()
+
+	return nil
+}
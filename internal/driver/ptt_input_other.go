@@ -0,0 +1,11 @@
+//go:build !linux
+
+package driver
+
+import (
+	"errors"
+)
+
+func (pi *PttInput) Start() error {
+	return errors.New("PTT_INPUT is only supported on Linux")
+}
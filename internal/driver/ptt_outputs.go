@@ -0,0 +1,152 @@
+package driver
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PttOutput is a line switched together with the transmitter, e.g. the PTT
+// input of an amplifier or a preamp bypass relay.
+type PttOutput interface {
+	Set(on bool) error
+	Close() error
+}
+
+// PttOutputs keys the outputs before the rig goes to TX and releases them
+// after it's back on RX, one after another in the order they were added and
+// in reverse. A nil PttOutputs has no outputs.
+type PttOutputs struct {
+	mu       sync.Mutex
+	outputs  []PttOutput
+	lead     time.Duration
+	lag      time.Duration
+	step     time.Duration
+	isKeyed  bool
+	releaser *time.Timer
+}
+
+func NewPttOutputs(lead, lag time.Duration) *PttOutputs {
+	return &PttOutputs{lead: lead, lag: lag}
+}
+
+// newPttOutputsFromEnv sets up the outputs from GPIO_PTT and SERIAL_PTT, it
+// returns nil when there are none.
+func newPttOutputsFromEnv() *PttOutputs {
+	gpioSpec := strings.TrimSpace(os.Getenv("GPIO_PTT"))
+	serialSpec := strings.TrimSpace(os.Getenv("SERIAL_PTT"))
+	if gpioSpec == "" && serialSpec == "" {
+		return nil
+	}
+
+	po := NewPttOutputs(envDuration("PTT_LEAD", 0), envDuration("PTT_LAG", 0))
+	po.step = envDuration("PTT_STEP", 0)
+	if gpioSpec != "" {
+		for _, pin := range strings.Split(gpioSpec, ",") {
+			output, err := NewGpioOutput(strings.TrimSpace(pin))
+			if err != nil {
+				log.Fatalln(err)
+			}
+			po.Add(output)
+		}
+	}
+	if serialSpec != "" {
+		for _, port := range strings.Split(serialSpec, ",") {
+			output, err := NewSerialPttOutput(strings.TrimSpace(port))
+			if err != nil {
+				log.Fatalln(err)
+			}
+			po.Add(output)
+		}
+	}
+
+	return po
+}
+
+func (po *PttOutputs) Add(output PttOutput) {
+	po.mu.Lock()
+	defer po.mu.Unlock()
+	po.outputs = append(po.outputs, output)
+}
+
+// Key switches the outputs on and waits the lead time, so relays have
+// settled before the rig transmits.
+func (po *PttOutputs) Key() {
+	if po == nil {
+		return
+	}
+
+	po.mu.Lock()
+	if po.releaser != nil {
+		po.releaser.Stop()
+		po.releaser = nil
+	}
+	wasKeyed := po.isKeyed
+	po.isKeyed = true
+	po.set(true)
+	po.mu.Unlock()
+
+	if !wasKeyed {
+		time.Sleep(po.lead)
+	}
+}
+
+// Unkey switches the outputs off after the lag time, unless the rig is
+// keyed again in the meantime.
+func (po *PttOutputs) Unkey() {
+	if po == nil {
+		return
+	}
+
+	po.mu.Lock()
+	defer po.mu.Unlock()
+	if !po.isKeyed || po.releaser != nil {
+		return
+	}
+	po.releaser = time.AfterFunc(po.lag, func() {
+		po.mu.Lock()
+		defer po.mu.Unlock()
+		po.releaser = nil
+		po.isKeyed = false
+		po.set(false)
+	})
+}
+
+// set must be called with the lock held.
+func (po *PttOutputs) set(on bool) {
+	for i := range po.outputs {
+		output := po.outputs[i]
+		if !on {
+			output = po.outputs[len(po.outputs)-1-i]
+		}
+		if i > 0 {
+			time.Sleep(po.step)
+		}
+		if err := output.Set(on); err != nil {
+			log.Warnln(err)
+		}
+	}
+}
+
+// Close switches the outputs off right away.
+func (po *PttOutputs) Close() {
+	if po == nil {
+		return
+	}
+
+	po.mu.Lock()
+	defer po.mu.Unlock()
+	if po.releaser != nil {
+		po.releaser.Stop()
+		po.releaser = nil
+	}
+	po.isKeyed = false
+	po.set(false)
+	for _, output := range po.outputs {
+		output.Close()
+	}
+	po.outputs = nil
+}
@@ -0,0 +1,23 @@
+//go:build freebsd || openbsd
+
+package driver
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func getTermios(f *os.File) (*unix.Termios, error) {
+	return unix.IoctlGetTermios(int(f.Fd()), unix.TIOCGETA)
+}
+
+func setTermios(f *os.File, attrs *unix.Termios) error {
+	return unix.IoctlSetTermios(int(f.Fd()), unix.TIOCSETA, attrs)
+}
+
+// flushInput drops what has been written to the terminal but not read yet.
+func flushInput(f *os.File) error {
+	const fread = 0x1
+	return unix.IoctlSetPointerInt(int(f.Fd()), unix.TIOCFLUSH, fread)
+}
@@ -0,0 +1,56 @@
+//go:build (freebsd || openbsd) && cgo
+
+package driver
+
+/*
+#define _XOPEN_SOURCE 600
+#include <fcntl.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+// ptsname isn't reentrant
+var ptsnameMu sync.Mutex
+
+// openPty opens a new pseudo terminal pair, the slave side is what the
+// clients get. pkg/term doesn't get it right on the BSDs, libc does.
+func openPty() (*os.File, *os.File, error) {
+	fd, err := C.posix_openpt(C.O_RDWR | C.O_NOCTTY)
+	if fd < 0 {
+		return nil, nil, err
+	}
+	ptm := os.NewFile(uintptr(fd), "/dev/ptm")
+
+	if rc, err := C.grantpt(fd); rc != 0 {
+		ptm.Close()
+		return nil, nil, err
+	}
+	if rc, err := C.unlockpt(fd); rc != 0 {
+		ptm.Close()
+		return nil, nil, err
+	}
+
+	ptsnameMu.Lock()
+	name, err := C.ptsname(fd)
+	if name == nil {
+		ptsnameMu.Unlock()
+		ptm.Close()
+		return nil, nil, err
+	}
+	ptsName := C.GoString(name)
+	ptsnameMu.Unlock()
+
+	pts, err := os.OpenFile(ptsName, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		ptm.Close()
+		return nil, nil, err
+	}
+
+	return ptm, pts, nil
+}
@@ -0,0 +1,35 @@
+//go:build freebsd && !cgo
+
+package driver
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPty opens a new pseudo terminal pair, the slave side is what the
+// clients get. Without cgo, posix_openpt is called directly, grantpt and
+// unlockpt have nothing to do for the pts(4) devices.
+func openPty() (*os.File, *os.File, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_POSIX_OPENPT, unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0, 0)
+	if errno != 0 {
+		return nil, nil, os.NewSyscallError("posix_openpt", errno)
+	}
+	ptm := os.NewFile(fd, "/dev/ptmx")
+
+	n, err := unix.IoctlGetInt(int(fd), unix.TIOCGPTN)
+	if err != nil {
+		ptm.Close()
+		return nil, nil, err
+	}
+
+	pts, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		ptm.Close()
+		return nil, nil, err
+	}
+
+	return ptm, pts, nil
+}
@@ -0,0 +1,45 @@
+//go:build openbsd && !cgo
+
+package driver
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// struct ptmget of <sys/tty.h>, filled by the PTMGET ioctl of /dev/ptm
+type ptmget struct {
+	cfd int32
+	sfd int32
+	cn  [16]byte
+	sn  [16]byte
+}
+
+// _IOR('t', 1, struct ptmget)
+const ioctlPtmget = 0x40287401
+
+// openPty opens a new pseudo terminal pair, the slave side is what the
+// clients get. Without cgo, the pair is asked from /dev/ptm, as the libc
+// does.
+func openPty() (*os.File, *os.File, error) {
+	ptmDev, err := os.OpenFile("/dev/ptm", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer ptmDev.Close()
+
+	var pair ptmget
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, ptmDev.Fd(), ioctlPtmget, uintptr(unsafe.Pointer(&pair)))
+	if errno != 0 {
+		return nil, nil, os.NewSyscallError("ioctl", errno)
+	}
+	unix.CloseOnExec(int(pair.cfd))
+	unix.CloseOnExec(int(pair.sfd))
+
+	ptm := os.NewFile(uintptr(pair.cfd), unix.ByteSliceToString(pair.cn[:]))
+	pts := os.NewFile(uintptr(pair.sfd), unix.ByteSliceToString(pair.sn[:]))
+
+	return ptm, pts, nil
+}
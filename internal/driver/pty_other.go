@@ -0,0 +1,30 @@
+//go:build !freebsd && !openbsd
+
+package driver
+
+import (
+	"os"
+
+	"github.com/pkg/term/termios"
+	"golang.org/x/sys/unix"
+)
+
+// openPty opens a new pseudo terminal pair, the slave side is what the
+// clients get.
+func openPty() (*os.File, *os.File, error) {
+	return termios.Pty()
+}
+
+func getTermios(f *os.File) (*unix.Termios, error) {
+	attrs := &unix.Termios{}
+	return attrs, termios.Tcgetattr(f.Fd(), attrs)
+}
+
+func setTermios(f *os.File, attrs *unix.Termios) error {
+	return termios.Tcsetattr(f.Fd(), termios.TCSANOW, attrs)
+}
+
+// flushInput drops what has been written to the terminal but not read yet.
+func flushInput(f *os.File) error {
+	return termios.Tcflush(f.Fd(), termios.TCIFLUSH)
+}
@@ -0,0 +1,97 @@
+package driver
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Recorder writes the received audio to a WAV file.
+type Recorder struct {
+	ss   *SerialStream
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewRecorder(ss *SerialStream) *Recorder {
+	return &Recorder{ss: ss}
+}
+
+func (r *Recorder) Start(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stop != nil {
+		return errors.New("already recording")
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	// the sizes are filled in when the recording stops
+	if _, err := file.Write(wavHeader(0, r.ss.RxRate())); err != nil {
+		file.Close()
+		return err
+	}
+
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	sink := r.ss.RxAudio.Add("recorder", cap(r.ss.AudioOutBuf))
+	go r.record(file, sink, r.stop, r.done)
+	log.Infof("Recording the received audio to %s\n", path)
+
+	return nil
+}
+
+func (r *Recorder) record(file *os.File, sink *AudioSink, stop, done chan struct{}) {
+	defer close(done)
+	defer r.ss.RxAudio.Remove(sink)
+
+	length := 0
+	for {
+		select {
+		case chunk := <-sink.C:
+			if _, err := file.Write(chunk); err != nil {
+				log.Errorln(err)
+				file.Close()
+				return
+			}
+			length += len(chunk)
+		case <-stop:
+			file.WriteAt(wavHeader(length, r.ss.RxRate()), 0)
+			file.Close()
+			log.Infof("Recorded %s\n", file.Name())
+			return
+		}
+	}
+}
+
+func (r *Recorder) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	r.stop = nil
+}
+
+func registerRecorderCommands(cs *ControlServer, r *Recorder) {
+	cs.Handle("record", func(args []string) (string, error) {
+		switch {
+		case len(args) == 1 && args[0] == "stop":
+			r.Stop()
+			return "", nil
+		case len(args) == 1:
+			return "", r.Start(args[0])
+		}
+
+		return "", errUsage
+	})
+}
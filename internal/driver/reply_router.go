@@ -0,0 +1,162 @@
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const catReplyTimeout = time.Second
+
+type replyWaiter struct {
+	cmd      string
+	reply    chan []byte // nil when it's a CAT client waiting
+	client   *CatClient
+	since    time.Time
+	attempts int
+	// the reply to a query which has been sent again, dropped
+	isDuplicate bool
+}
+
+// ReplyRouter remembers who asked the rig for what, so replies to the
+// driver's own queries don't end up at CAT clients, and those of a client
+// don't end up at another one. The rig answers in order, so waiters are
+// kept in a FIFO queue per query, which the reply starts with. A query the rig doesn't answer in time
+// is sent again, up to a number of retries.
+type ReplyRouter struct {
+	mu       sync.Mutex
+	pending  map[string][]*replyWaiter
+	timeout  time.Duration
+	timeouts map[string]time.Duration
+	retries  int
+}
+
+func NewReplyRouter(timeout time.Duration, retries int) *ReplyRouter {
+	rr := new(ReplyRouter)
+	rr.pending = make(map[string][]*replyWaiter)
+	rr.timeout = timeout
+	rr.timeouts = make(map[string]time.Duration)
+	rr.retries = retries
+
+	return rr
+}
+
+func newReplyRouterFromEnv() (*ReplyRouter, error) {
+	timeout := envDuration("CAT_REPLY_TIMEOUT", catReplyTimeout)
+	if timeout <= 0 {
+		timeout = catReplyTimeout
+	}
+	rr := NewReplyRouter(timeout, envInt("CAT_RETRIES", 1))
+
+	// e.g. IF:2s,ID:500ms
+	if text, ok := os.LookupEnv("CAT_REPLY_TIMEOUTS"); ok && text != "" {
+		for _, field := range strings.Split(text, ",") {
+			cmd, value, ok := strings.Cut(strings.TrimSpace(field), ":")
+			timeout, err := time.ParseDuration(value)
+			if !ok || len(cmd) != 2 || err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("invalid CAT_REPLY_TIMEOUTS entry %q", field)
+			}
+			rr.timeouts[strings.ToUpper(cmd)] = timeout
+		}
+	}
+
+	return rr, nil
+}
+
+// Timeout is how long the rig has to answer the command.
+func (rr *ReplyRouter) Timeout(cmd string) time.Duration {
+	if timeout, ok := rr.timeouts[cmd[:2]]; ok {
+		return timeout
+	}
+
+	return rr.timeout
+}
+
+// Patience is how long the rig has to answer the command, retries
+// included.
+func (rr *ReplyRouter) Patience(cmd string) time.Duration {
+	return time.Duration(rr.retries+1) * rr.Timeout(cmd)
+}
+
+func (rr *ReplyRouter) Expect(cmd string, reply chan []byte, client *CatClient) {
+	if len(cmd) < 2 {
+		return
+	}
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	rr.pending[cmd] = append(rr.pending[cmd], &replyWaiter{cmd: cmd, reply: reply, client: client, since: time.Now()})
+}
+
+// Route returns the waiter the reply belongs to, nil when nobody has asked
+// for it, e.g. for the notifications of the rig.
+func (rr *ReplyRouter) Route(data []byte) *replyWaiter {
+	if len(data) < 2 {
+		return nil
+	}
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	// the longest query the reply starts with, SM0 rather than SM
+	prefix := ""
+	for query := range rr.pending {
+		if len(query) > len(prefix) && bytes.HasPrefix(data, []byte(query)) {
+			prefix = query
+		}
+	}
+	waiters := rr.pending[prefix]
+	if len(waiters) == 0 {
+		return nil
+	}
+	if len(waiters) == 1 {
+		delete(rr.pending, prefix)
+	} else {
+		rr.pending[prefix] = waiters[1:]
+	}
+
+	return waiters[0]
+}
+
+// Expire goes through the queries the rig hasn't answered in time, it
+// returns those to send again and those which have run out of retries.
+func (rr *ReplyRouter) Expire() (retries []*replyWaiter, failures []*replyWaiter) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	now := time.Now()
+	for query, waiters := range rr.pending {
+		kept := waiters[:0]
+		for _, waiter := range waiters {
+			switch {
+			case now.Sub(waiter.since) < rr.Timeout(query):
+				kept = append(kept, waiter)
+			case waiter.isDuplicate:
+			case waiter.attempts < rr.retries:
+				waiter.attempts++
+				waiter.since = now
+				kept = append(kept, waiter)
+				retries = append(retries, waiter)
+			default:
+				failures = append(failures, waiter)
+			}
+		}
+		if len(kept) == 0 {
+			delete(rr.pending, query)
+		} else {
+			rr.pending[query] = kept
+		}
+	}
+
+	// when the first query gets answered after all, the reply to the
+	// retry is one too many
+	for _, waiter := range retries {
+		rr.pending[waiter.cmd] = append(rr.pending[waiter.cmd], &replyWaiter{cmd: waiter.cmd, since: now, isDuplicate: true})
+	}
+
+	return retries, failures
+}
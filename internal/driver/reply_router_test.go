@@ -0,0 +1,137 @@
+package driver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplyRouterRoute(t *testing.T) {
+	a, b := &CatClient{Tag: "a"}, &CatClient{Tag: "b"}
+
+	tests := []struct {
+		name   string
+		expect []string
+		route  []string
+		// the tag of the client each reply goes to, "" for nobody
+		want []string
+	}{
+		{
+			name:   "in order",
+			expect: []string{"FA", "FA"},
+			route:  []string{"FA00007074000", "FA00007074000"},
+			want:   []string{"a", "b"},
+		},
+		{
+			name:   "by command",
+			expect: []string{"FA", "MD"},
+			route:  []string{"MD2", "FA00007074000"},
+			want:   []string{"b", "a"},
+		},
+		{
+			name:   "with a parameter",
+			expect: []string{"SM0", "AG0", "EX012000"},
+			route:  []string{"AG0100", "SM00005", "EX0130001", "EX0120001"},
+			want:   []string{"b", "a", "", "a"},
+		},
+		{
+			name:   "not asked for",
+			expect: []string{"FA"},
+			route:  []string{"IF00007074000", "FA00007074000", "FA00007074000"},
+			want:   []string{"", "a", ""},
+		},
+		{
+			name:   "too short",
+			expect: []string{"F"},
+			route:  []string{"F", ""},
+			want:   []string{"", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := NewReplyRouter(time.Minute, 0)
+			clients := []*CatClient{a, b}
+			for i, cmd := range tt.expect {
+				rr.Expect(cmd, nil, clients[i%2])
+			}
+			for i, reply := range tt.route {
+				got := ""
+				if waiter := rr.Route([]byte(reply)); waiter != nil {
+					got = waiter.client.Tag
+				}
+				if got != tt.want[i] {
+					t.Errorf("Route(%q) went to %q, want %q", reply, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReplyRouterExpire(t *testing.T) {
+	tests := []struct {
+		name        string
+		retries     int
+		expired     bool
+		wantRetries int
+		wantFailed  int
+		// the queries waiting afterwards, duplicates included
+		wantPending int
+	}{
+		{name: "in time", retries: 1, expired: false, wantPending: 1},
+		{name: "retried", retries: 1, expired: true, wantRetries: 1, wantPending: 2},
+		{name: "no retries", retries: 0, expired: true, wantFailed: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := NewReplyRouter(time.Second, tt.retries)
+			rr.Expect("FA", nil, &CatClient{Tag: "a"})
+			if tt.expired {
+				rr.pending["FA"][0].since = time.Now().Add(-time.Minute)
+			}
+
+			retries, failures := rr.Expire()
+			if len(retries) != tt.wantRetries || len(failures) != tt.wantFailed || len(rr.pending["FA"]) != tt.wantPending {
+				t.Errorf("Expire() = %d retries, %d failures, %d pending, want %d, %d, %d",
+					len(retries), len(failures), len(rr.pending["FA"]), tt.wantRetries, tt.wantFailed, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestReplyRouterRetryDuplicate(t *testing.T) {
+	rr := NewReplyRouter(time.Second, 1)
+	rr.Expect("FA", nil, &CatClient{Tag: "a"})
+	rr.pending["FA"][0].since = time.Now().Add(-time.Minute)
+	rr.Expire()
+
+	// the first query answered late, then the retry
+	if waiter := rr.Route([]byte("FA00007074000")); waiter == nil || waiter.isDuplicate {
+		t.Fatalf("the first reply went to %+v, want the client", waiter)
+	}
+	if waiter := rr.Route([]byte("FA00007074000")); waiter == nil || !waiter.isDuplicate {
+		t.Fatalf("the second reply went to %+v, want the duplicate", waiter)
+	}
+}
+
+func TestIsQuery(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want bool
+	}{
+		{"FA", true},
+		{"TX", false},
+		{"FA00007074000", false},
+		{"SM0", true},
+		{"AG0", true},
+		{"AG0100", false},
+		{"EX012000", true},
+		{"EX0120001", false},
+	}
+
+	for _, tt := range tests {
+		if got := isQuery(tt.cmd); got != tt.want {
+			t.Errorf("isQuery(%q) = %v, want %v", tt.cmd, got, tt.want)
+		}
+	}
+}
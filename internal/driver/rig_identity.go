@@ -0,0 +1,61 @@
+package driver
+
+import (
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// rigIdentity is the Kenwood model the driver tells its clients it is, some
+// of them behave better with a particular one. It only changes the ID
+// reply and the canned EX reads, the rig speaks the TS-480 protocol.
+type rigIdentity struct {
+	name   string
+	id     string // the number in the ID reply
+	exRead int    // the length of an EX command reading a menu
+}
+
+var rigIdentities = []rigIdentity{
+	{"TS-480", "020", 8},
+	{"TS-2000", "019", 9},
+	{"TS-590S", "021", 7},
+	{"TS-590SG", "023", 7},
+	{"TS-890", "024", 7},
+}
+
+// the truSDX says it's a TS-480
+var defaultRigIdentity = rigIdentities[0]
+
+// rigIdentityFromEnv reads RIG_IDENTITY, a model like ts2000 or the three
+// digits of the ID reply.
+func rigIdentityFromEnv() rigIdentity {
+	value, ok := os.LookupEnv("RIG_IDENTITY")
+	if !ok || value == "" {
+		return defaultRigIdentity
+	}
+
+	name := strings.ToUpper(strings.ReplaceAll(value, "-", ""))
+	for _, identity := range rigIdentities {
+		if strings.ReplaceAll(identity.name, "-", "") == name || identity.id == value {
+			return identity
+		}
+	}
+	if len(value) == 3 && strings.Trim(value, "0123456789") == "" {
+		return rigIdentity{"ID " + value, value, defaultRigIdentity.exRead}
+	}
+	log.Warnf("Invalid RIG_IDENTITY value %q, using %s\n", value, defaultRigIdentity.name)
+
+	return defaultRigIdentity
+}
+
+// rigModelName is the model with the ID, if known.
+func rigModelName(id string) string {
+	for _, identity := range rigIdentities {
+		if identity.id == id {
+			return identity.name
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,234 @@
+package driver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leshniak/trusdx-go/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// how long the rig stays put before its state is saved, tuning around
+// doesn't write the file on every step
+const rigStateSaveDelay = 2 * time.Second
+
+// savedRigState is what the driver remembers of the rig across restarts,
+// saved in the format of the settings export with the frequency and the
+// mode on top.
+type savedRigState struct {
+	frequency int64
+	mode      byte
+	settings  RigSettings
+}
+
+// rigStateFilePath is RIG_STATE_FILE if set. With RESTORE_RIG_STATE alone
+// it's the file next to the config file, e.g. ~/.config/trusdx-go/rig-state.
+// An empty path disables it, which it is unless one of them is set.
+func rigStateFilePath() string {
+	if path, ok := os.LookupEnv("RIG_STATE_FILE"); ok {
+		return path
+	}
+	if os.Getenv("RESTORE_RIG_STATE") != "1" {
+		return ""
+	}
+
+	configPath := config.FilePath()
+	if configPath == "" {
+		return ""
+	}
+
+	return filepath.Join(filepath.Dir(configPath), "rig-state")
+}
+
+func loadRigState(path string) (savedRigState, error) {
+	state := savedRigState{settings: make(RigSettings)}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return state, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return state, fmt.Errorf("%s:%d: expected a name and a value", path, lineNo)
+		}
+		switch fields[0] {
+		case "frequency":
+			state.frequency, err = strconv.ParseInt(fields[1], 10, 64)
+		case "mode":
+			mode, ok := parseModeName(fields[1])
+			if !ok {
+				err = fmt.Errorf("unknown mode %q", fields[1])
+			}
+			state.mode = mode
+		default:
+			if _, ok := findExtCommand(fields[0]); !ok {
+				err = fmt.Errorf("unknown setting %q", fields[0])
+				break
+			}
+			state.settings[fields[0]], err = strconv.Atoi(fields[1])
+		}
+		if err != nil {
+			return state, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+	}
+
+	return state, scanner.Err()
+}
+
+// save replaces the file at once, a crash never leaves half of it.
+func (state savedRigState) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# trusdx-go rig state, saved %s\n", time.Now().Format(time.RFC3339))
+	if state.frequency > 0 {
+		fmt.Fprintf(&sb, "frequency %d\n", state.frequency)
+	}
+	if name, ok := modeNames[state.mode]; ok {
+		fmt.Fprintf(&sb, "mode %s\n", name)
+	}
+	state.settings.Write(&sb)
+
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, []byte(sb.String()), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(temp, path)
+}
+
+// RigStatePersister saves the frequency and the mode of the rig whenever
+// they change, and the settings on shutdown, to be restored by
+// RESTORE_RIG_STATE when the driver or the rig comes back.
+type RigStatePersister struct {
+	ss        *SerialStream
+	path      string
+	isRestore bool
+	mu        sync.Mutex
+	state     savedRigState
+	timer     *time.Timer
+}
+
+// startRigStatePersisterFromEnv restores the saved state if asked to and
+// starts saving it, it returns nil when the path is empty. It is called
+// once the startup commands of the driver have gone out, which would
+// otherwise override the restored mode.
+func startRigStatePersisterFromEnv(ss *SerialStream) *RigStatePersister {
+	path := rigStateFilePath()
+	if path == "" {
+		return nil
+	}
+
+	rp := &RigStatePersister{ss: ss, path: path, isRestore: os.Getenv("RESTORE_RIG_STATE") == "1"}
+	state, err := loadRigState(path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Warnf("Rig state not restored, %s\n", err)
+	}
+	rp.state = state
+	if rp.isRestore {
+		rp.restore()
+	}
+	ss.Events.Subscribe(rp.onEvent)
+
+	return rp
+}
+
+func (rp *RigStatePersister) onEvent(event Event) {
+	switch event := event.(type) {
+	case FrequencyChanged:
+		rp.update(func(state *savedRigState) { state.frequency = event.Frequency })
+	case ModeChanged:
+		rp.update(func(state *savedRigState) { state.mode = event.Mode })
+	case ConnectionChanged:
+		// a power-cycled rig comes back on its defaults
+		if event.Connected && rp.isRestore {
+			rp.restore()
+		}
+	}
+}
+
+func (rp *RigStatePersister) update(change func(*savedRigState)) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	change(&rp.state)
+	if rp.timer == nil {
+		rp.timer = time.AfterFunc(rigStateSaveDelay, rp.save)
+	} else {
+		rp.timer.Reset(rigStateSaveDelay)
+	}
+}
+
+func (rp *RigStatePersister) save() {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if err := rp.state.save(rp.path); err != nil {
+		log.Warnf("Rig state not saved, %s\n", err)
+	}
+}
+
+func (rp *RigStatePersister) restore() {
+	rp.mu.Lock()
+	state := rp.state
+	rp.mu.Unlock()
+
+	cmd := ""
+	if state.frequency > 0 {
+		cmd += fmt.Sprintf("FA%011d;", state.frequency)
+	}
+	if _, ok := modeNames[state.mode]; ok {
+		cmd += fmt.Sprintf("MD%c;", state.mode)
+	}
+	if cmd != "" {
+		log.Printf("Restoring the rig to %d Hz, %s\n", state.frequency, modeNames[state.mode])
+		rp.ss.PushCommand(cmd)
+	}
+	if err := rp.ss.WriteSettings(state.settings); err != nil {
+		log.Warnf("Rig settings not restored, %s\n", err)
+	}
+}
+
+// Close reads the settings of the rig, which change without any events,
+// and saves the state for the last time. It must be called while the rig
+// is still connected, without it the settings saved earlier are kept.
+func (rp *RigStatePersister) Close() {
+	if rp == nil {
+		return
+	}
+
+	rp.mu.Lock()
+	if rp.timer != nil {
+		rp.timer.Stop()
+	}
+	rp.mu.Unlock()
+	if rp.ss.IsRunning() {
+		settings, err := rp.ss.ReadSettings()
+		rp.mu.Lock()
+		if err == nil {
+			rp.state.settings = settings
+		} else {
+			log.Warnf("Rig settings not saved, %s\n", err)
+		}
+		rp.mu.Unlock()
+	}
+
+	rp.save()
+}
@@ -0,0 +1,223 @@
+package driver
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// RigSimulator stands in for the serial port of the rig. It answers the
+// basic CAT queries and loops the transmitted audio back as received audio,
+// so the driver can be exercised without the hardware. Optionally, it
+// streams a tone as the received audio too.
+type RigSimulator struct {
+	state       *RigState
+	out         chan []byte
+	pending     []byte
+	cmd         []byte
+	mu          sync.Mutex // orders the RX stream with the replies
+	isTxAudio   bool
+	isLoopback  bool
+	isStreaming bool // UA1 or UA2
+	isRxAudio   bool // in the middle of a US message of the RX stream
+	readTimeout time.Duration
+	closed      chan struct{}
+}
+
+func NewRigSimulator() *RigSimulator {
+	rs := &RigSimulator{
+		state:       NewRigState(nil),
+		out:         make(chan []byte, 1024),
+		readTimeout: serial.NoTimeout,
+		closed:      make(chan struct{}),
+	}
+	rs.state.Observe([]byte("FA00014074000;"), true)
+	rs.state.Observe([]byte("MD2;"), true)
+
+	return rs
+}
+
+// newStreamingRigSimulator streams a 1 kHz tone while receiving with the
+// audio on.
+func newStreamingRigSimulator() *RigSimulator {
+	rs := NewRigSimulator()
+	go rs.streamRx()
+
+	return rs
+}
+
+// OpenSimulatedPort can be used in place of OpenSerialPort, the name is
+// ignored.
+func OpenSimulatedPort(name string) (*SerialPort, error) {
+	return &SerialPort{NewRigSimulator()}, nil
+}
+
+func (rs *RigSimulator) Read(p []byte) (int, error) {
+	if len(rs.pending) == 0 {
+		var timeout <-chan time.Time
+		if rs.readTimeout >= 0 {
+			timeout = time.After(rs.readTimeout)
+		}
+
+		select {
+		case data := <-rs.out:
+			rs.pending = data
+		case <-timeout:
+			return 0, nil
+		case <-rs.closed:
+			return 0, os.ErrClosed
+		}
+	}
+
+	n := copy(p, rs.pending)
+	rs.pending = rs.pending[n:]
+
+	return n, nil
+}
+
+func (rs *RigSimulator) Write(p []byte) (int, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	n := len(p)
+	if rs.isRxAudio {
+		// the rig ends the audio before anything else
+		rs.send([]byte(";"))
+		rs.isRxAudio = false
+	}
+	for len(p) > 0 {
+		if rs.isTxAudio {
+			end := bytes.IndexByte(p, ';')
+			if end < 0 {
+				rs.loopback(p)
+				break
+			}
+			rs.loopback(p[:end])
+			rs.isTxAudio = false
+			if rs.isLoopback {
+				rs.send([]byte(";"))
+				rs.isLoopback = false
+			}
+			p = p[end+1:]
+			continue
+		}
+
+		end := bytes.IndexByte(p, ';')
+		if end < 0 {
+			rs.cmd = append(rs.cmd, p...)
+			break
+		}
+		rs.cmd = append(rs.cmd, p[:end]...)
+		rs.handleCommand(string(rs.cmd))
+		rs.cmd = rs.cmd[:0]
+		p = p[end+1:]
+	}
+
+	return n, nil
+}
+
+func (rs *RigSimulator) handleCommand(cmd string) {
+	if len(cmd) < 2 {
+		return
+	}
+
+	rs.state.Observe([]byte(cmd), false)
+	switch {
+	case cmd == "ID":
+		rs.send([]byte("ID020;"))
+	case cmd[:2] == "UA" && len(cmd) > 2:
+		rs.isStreaming = cmd != "UA0"
+	case cmd[:2] == "TX":
+		// everything up to the next ';' is audio
+		rs.isTxAudio = txKindOf(cmd, rs.state.Mode()) == TxAudio
+	case len(cmd) == 2:
+		if reply, ok := rs.state.Reply(cmd); ok {
+			rs.send(reply)
+		}
+	}
+}
+
+func (rs *RigSimulator) loopback(samples []byte) {
+	if len(samples) == 0 {
+		return
+	}
+	if !rs.isLoopback {
+		rs.send([]byte("US"))
+		rs.isLoopback = true
+	}
+	rs.send(samples)
+}
+
+// streamRx sends the received audio at the pace of the rig.
+func (rs *RigSimulator) streamRx() {
+	ticker := time.NewTicker(time.Second * dataChunkLength / nominalRxRate)
+	defer ticker.Stop()
+	var phase float64
+	step := 2 * math.Pi * 1000 / nominalRxRate
+	samples := make([]byte, dataChunkLength)
+
+	for {
+		select {
+		case <-rs.closed:
+			return
+		case <-ticker.C:
+		}
+
+		for i := range samples {
+			// never a ';', 64 at the lowest
+			samples[i] = byte(128 + 64*math.Sin(phase))
+			phase = math.Mod(phase+step, 2*math.Pi)
+		}
+		rs.mu.Lock()
+		if rs.isStreaming && !rs.isTxAudio && !rs.state.Transmitting() {
+			if !rs.isRxAudio {
+				rs.send([]byte("US"))
+				rs.isRxAudio = true
+			}
+			rs.send(samples)
+		}
+		rs.mu.Unlock()
+	}
+}
+
+// stall stops the RX stream without the delimiter, like the firmware
+// sometimes does, until the audio is turned on again.
+func (rs *RigSimulator) stall() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.isStreaming = false
+	rs.isRxAudio = false
+}
+
+func (rs *RigSimulator) send(data []byte) {
+	select {
+	case rs.out <- append([]byte(nil), data...):
+	case <-rs.closed:
+	}
+}
+
+func (rs *RigSimulator) SetReadTimeout(t time.Duration) error {
+	rs.readTimeout = t
+	return nil
+}
+
+func (rs *RigSimulator) Close() error {
+	close(rs.closed)
+	return nil
+}
+
+func (rs *RigSimulator) SetMode(mode *serial.Mode) error { return nil }
+func (rs *RigSimulator) Drain() error                    { return nil }
+func (rs *RigSimulator) ResetInputBuffer() error         { return nil }
+func (rs *RigSimulator) ResetOutputBuffer() error        { return nil }
+func (rs *RigSimulator) SetDTR(dtr bool) error           { return nil }
+func (rs *RigSimulator) SetRTS(rts bool) error           { return nil }
+func (rs *RigSimulator) Break(time.Duration) error       { return nil }
+func (rs *RigSimulator) GetModemStatusBits() (*serial.ModemStatusBits, error) {
+	return &serial.ModemStatusBits{}, nil
+}
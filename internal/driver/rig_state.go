@@ -0,0 +1,185 @@
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RigState tracks what the rig is tuned to, as seen in the CAT traffic
+// passing through the driver.
+type RigState struct {
+	mu           sync.Mutex
+	frequency    int64
+	mode         byte
+	transmitting bool
+	cwSpeed      int
+	cwTone       int
+	refreshedAt  time.Time
+	events       *EventBus
+}
+
+// NewRigState publishes the changes of the state to events, which may be
+// nil.
+func NewRigState(events *EventBus) *RigState {
+	return &RigState{events: events}
+}
+
+// Observe updates the state from a command sent to the rig or from a reply
+// it has sent back. Only replies count as a confirmation of the state.
+func (rs *RigState) Observe(data []byte, fromRig bool) {
+	data = bytes.TrimSuffix(data, []byte(";"))
+	if len(data) < 2 {
+		return
+	}
+
+	rs.mu.Lock()
+	changes := rs.update(string(data[:2]), data[2:], fromRig)
+	rs.mu.Unlock()
+
+	for _, event := range changes {
+		rs.events.Publish(event)
+	}
+}
+
+func (rs *RigState) update(cmd string, args []byte, fromRig bool) []Event {
+	if cmd == "KS" || cmd == "PT" {
+		return rs.updateKeyer(cmd, args)
+	}
+
+	frequency, mode, transmitting := rs.frequency, rs.mode, rs.transmitting
+
+	switch cmd {
+	case "FA":
+		if value, err := strconv.ParseInt(string(args), 10, 64); err == nil {
+			frequency = value
+		}
+	case "MD":
+		if len(args) == 1 {
+			mode = args[0]
+		}
+	case "TX":
+		transmitting = true
+	case "RX":
+		transmitting = false
+	case "IF":
+		// IF[freq:11][5 spaces][rit:5][rit][xit][0][mem:2][tx][mode]...
+		if len(args) < 28 {
+			return nil
+		}
+		if value, err := strconv.ParseInt(string(args[:11]), 10, 64); err == nil {
+			frequency = value
+		}
+		transmitting = args[26] == '1'
+		mode = args[27]
+	default:
+		return nil
+	}
+
+	if fromRig {
+		rs.refreshedAt = time.Now()
+	}
+
+	var changes []Event
+	if frequency != rs.frequency {
+		rs.frequency = frequency
+		changes = append(changes, FrequencyChanged{frequency})
+	}
+	if mode != rs.mode {
+		rs.mode = mode
+		changes = append(changes, ModeChanged{mode})
+	}
+	if transmitting != rs.transmitting {
+		rs.transmitting = transmitting
+		changes = append(changes, PttChanged{transmitting})
+	}
+
+	return changes
+}
+
+// updateKeyer must be called with the lock held.
+func (rs *RigState) updateKeyer(cmd string, args []byte) []Event {
+	value, err := strconv.Atoi(string(args))
+	if err != nil {
+		return nil
+	}
+
+	speed, tone := rs.cwSpeed, rs.cwTone
+	if cmd == "KS" {
+		speed = value
+	} else {
+		tone = cwToneFromPitch(value)
+	}
+	if speed == rs.cwSpeed && tone == rs.cwTone {
+		return nil
+	}
+	rs.cwSpeed, rs.cwTone = speed, tone
+
+	return []Event{KeyerChanged{speed, tone}}
+}
+
+func (rs *RigState) Frequency() int64 {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	return rs.frequency
+}
+
+func (rs *RigState) Mode() byte {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	return rs.mode
+}
+
+func (rs *RigState) Transmitting() bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	return rs.transmitting
+}
+
+// IFReply builds the IF reply in the TS-480 format if the state is known and
+// has been confirmed by the rig not longer than maxAge ago.
+func (rs *RigState) IFReply(maxAge time.Duration) ([]byte, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if time.Since(rs.refreshedAt) > maxAge {
+		return nil, false
+	}
+
+	return rs.ifReply()
+}
+
+// Reply builds the reply the rig would give to the FA, MD or IF query.
+func (rs *RigState) Reply(cmd string) ([]byte, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	switch cmd {
+	case "FA":
+		return []byte(fmt.Sprintf("FA%011d;", rs.frequency)), rs.frequency != 0
+	case "MD":
+		return []byte(fmt.Sprintf("MD%c;", rs.mode)), rs.mode != 0
+	case "IF":
+		return rs.ifReply()
+	}
+
+	return nil, false
+}
+
+func (rs *RigState) ifReply() ([]byte, bool) {
+	if rs.frequency == 0 || rs.mode == 0 {
+		return nil, false
+	}
+
+	tx := 0
+	if rs.transmitting {
+		tx = 1
+	}
+
+	return []byte(fmt.Sprintf("IF%011d     +000000000%d%c0000000;", rs.frequency, tx, rs.mode)), true
+}
@@ -0,0 +1,136 @@
+package driver
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// RingBuffer passes the audio between the callbacks of the sound card and
+// the rest of the driver. The callbacks mustn't wait, they use TryRead and
+// TryWrite, the other side blocks until there's room or enough samples.
+type RingBuffer[T any] struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []T
+	start  int
+	length int
+	closed bool
+	// set when TryWrite hasn't found room for everything
+	Overflowed atomic.Bool
+}
+
+func NewRingBuffer[T any](size int) *RingBuffer[T] {
+	rb := &RingBuffer[T]{buf: make([]T, size)}
+	rb.cond = sync.NewCond(&rb.mu)
+
+	return rb
+}
+
+// Write blocks until all the samples are in, it returns false once the
+// buffer is closed.
+func (rb *RingBuffer[T]) Write(samples []T) bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	for len(samples) > 0 {
+		for rb.length == len(rb.buf) && !rb.closed {
+			rb.cond.Wait()
+		}
+		if rb.closed {
+			return false
+		}
+		n := rb.put(samples)
+		samples = samples[n:]
+		rb.cond.Broadcast()
+	}
+
+	return true
+}
+
+// TryWrite writes what fits and returns how much that was.
+func (rb *RingBuffer[T]) TryWrite(samples []T) int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	n := rb.put(samples)
+	if n < len(samples) {
+		rb.Overflowed.Store(true)
+	}
+	rb.cond.Broadcast()
+
+	return n
+}
+
+// Read blocks until the samples fill the slice, it returns false once the
+// buffer is closed.
+func (rb *RingBuffer[T]) Read(samples []T) bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	for len(samples) > 0 {
+		for rb.length == 0 && !rb.closed {
+			rb.cond.Wait()
+		}
+		if rb.closed {
+			return false
+		}
+		n := rb.take(samples)
+		samples = samples[n:]
+		rb.cond.Broadcast()
+	}
+
+	return true
+}
+
+// TryRead reads what's there and returns how much that was.
+func (rb *RingBuffer[T]) TryRead(samples []T) int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	n := rb.take(samples)
+	rb.cond.Broadcast()
+
+	return n
+}
+
+// Close wakes up whoever waits, for good.
+func (rb *RingBuffer[T]) Close() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.closed = true
+	rb.cond.Broadcast()
+}
+
+func (rb *RingBuffer[T]) put(samples []T) int {
+	n := 0
+	for n < len(samples) && rb.length < len(rb.buf) {
+		end := (rb.start + rb.length) % len(rb.buf)
+		// up to the end of the buffer, or to the start of the samples
+		free := len(rb.buf) - end
+		if end < rb.start {
+			free = rb.start - end
+		}
+		copied := copy(rb.buf[end:end+free], samples[n:])
+		rb.length += copied
+		n += copied
+	}
+
+	return n
+}
+
+func (rb *RingBuffer[T]) take(samples []T) int {
+	n := 0
+	for n < len(samples) && rb.length > 0 {
+		end := rb.start + rb.length
+		if end > len(rb.buf) {
+			end = len(rb.buf)
+		}
+		copied := copy(samples[n:], rb.buf[rb.start:end])
+		rb.start = (rb.start + copied) % len(rb.buf)
+		rb.length -= copied
+		n += copied
+	}
+
+	return n
+}
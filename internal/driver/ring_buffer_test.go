@@ -0,0 +1,118 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRingBufferWraparound(t *testing.T) {
+	type step struct {
+		write []int
+		// how many to read
+		read int
+		want []int
+		// how much got written or read
+		wantN int
+	}
+
+	tests := []struct {
+		name           string
+		size           int
+		steps          []step
+		wantOverflowed bool
+	}{
+		{
+			name: "in one piece",
+			size: 4,
+			steps: []step{
+				{write: []int{1, 2, 3}, wantN: 3},
+				{read: 3, want: []int{1, 2, 3}, wantN: 3},
+			},
+		},
+		{
+			name: "write across the end",
+			size: 4,
+			steps: []step{
+				{write: []int{1, 2, 3}, wantN: 3},
+				{read: 2, want: []int{1, 2}, wantN: 2},
+				{write: []int{4, 5, 6}, wantN: 3},
+				{read: 4, want: []int{3, 4, 5, 6}, wantN: 4},
+			},
+		},
+		{
+			name: "read across the end",
+			size: 4,
+			steps: []step{
+				{write: []int{1, 2, 3, 4}, wantN: 4},
+				{read: 3, want: []int{1, 2, 3}, wantN: 3},
+				{write: []int{5, 6}, wantN: 2},
+				{read: 2, want: []int{4, 5}, wantN: 2},
+				{write: []int{7, 8}, wantN: 2},
+				{read: 4, want: []int{6, 7, 8}, wantN: 3},
+			},
+		},
+		{
+			name: "full",
+			size: 4,
+			steps: []step{
+				{write: []int{1, 2, 3}, wantN: 3},
+				{read: 1, want: []int{1}, wantN: 1},
+				{write: []int{4, 5, 6}, wantN: 2},
+				{read: 4, want: []int{2, 3, 4, 5}, wantN: 4},
+			},
+			wantOverflowed: true,
+		},
+		{
+			name: "empty",
+			size: 4,
+			steps: []step{
+				{read: 2, want: []int{}, wantN: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rb := NewRingBuffer[int](tt.size)
+			for i, s := range tt.steps {
+				if s.write != nil {
+					if n := rb.TryWrite(s.write); n != s.wantN {
+						t.Fatalf("step %d: TryWrite(%v) = %d, want %d", i, s.write, n, s.wantN)
+					}
+					continue
+				}
+				samples := make([]int, s.read)
+				n := rb.TryRead(samples)
+				if n != s.wantN || !reflect.DeepEqual(samples[:n], s.want) {
+					t.Fatalf("step %d: TryRead() = %v, want %v", i, samples[:n], s.want)
+				}
+			}
+			if rb.Overflowed.Load() != tt.wantOverflowed {
+				t.Errorf("overflowed %v, want %v", rb.Overflowed.Load(), tt.wantOverflowed)
+			}
+		})
+	}
+}
+
+func TestRingBufferBlocking(t *testing.T) {
+	rb := NewRingBuffer[int](3)
+	done := make(chan []int)
+	go func() {
+		samples := make([]int, 5)
+		rb.Read(samples)
+		done <- samples
+	}()
+
+	// more than fits, the reader makes room
+	if !rb.Write([]int{1, 2, 3, 4, 5}) {
+		t.Fatal("Write() = false before Close")
+	}
+	if samples := <-done; !reflect.DeepEqual(samples, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("Read() = %v, want [1 2 3 4 5]", samples)
+	}
+
+	rb.Close()
+	if rb.Read(make([]int, 1)) || rb.Write([]int{1}) {
+		t.Error("Read() or Write() = true after Close")
+	}
+}
@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// RxChain processes the audio from the rig before it's handed out, as
+// floats from -1 to 1. An empty chain passes the samples through.
+type RxChain struct {
+	agc     *Agc
+	gain    atomic.Uint64 // float64 bits, linear
+	isMuted atomic.Bool
+}
+
+func newRxChainFromEnv() *RxChain {
+	rc := &RxChain{
+		agc: newAgcFromEnv(),
+	}
+	rc.SetGain(float64(envInt("RX_GAIN", 0)))
+
+	return rc
+}
+
+// SetGain sets the volume in dB.
+func (rc *RxChain) SetGain(db float64) {
+	rc.gain.Store(math.Float64bits(math.Pow(10, db/20)))
+}
+
+func (rc *RxChain) Gain() float64 {
+	return 20 * math.Log10(math.Float64frombits(rc.gain.Load()))
+}
+
+func (rc *RxChain) SetMuted(muted bool) {
+	rc.isMuted.Store(muted)
+}
+
+func (rc *RxChain) Muted() bool {
+	return rc.isMuted.Load()
+}
+
+func (rc *RxChain) Process(samples []byte) []byte {
+	gain := math.Float64frombits(rc.gain.Load())
+	if rc.isMuted.Load() {
+		gain = 0
+	}
+	if rc.agc == nil && gain == 1 {
+		return samples
+	}
+
+	processed := make([]byte, len(samples))
+	for i, sample := range samples {
+		value := (float64(sample) - 128) / 128
+		if rc.agc != nil {
+			value = rc.agc.Process(value)
+		}
+		processed[i] = toUnsigned8(value * gain)
+	}
+
+	return processed
+}
+
+// toUnsigned8 rounds and clips a sample from -1 to 1.
+func toUnsigned8(value float64) byte {
+	scaled := value*128 + 128.5
+	if scaled < 0 {
+		return 0
+	} else if scaled > 255 {
+		return 255
+	}
+
+	return byte(scaled)
+}
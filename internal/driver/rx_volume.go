@@ -0,0 +1,71 @@
+package driver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+func (rc *RxChain) String() string {
+	return fmt.Sprintf("gain %.1f\nmuted %t\n", rc.Gain(), rc.Muted())
+}
+
+// rx              shows the volume of the received audio
+// rx gain <dB>    sets it
+// rx mute|unmute  silences it
+func registerRxCommands(cs *ControlServer, ss *SerialStream) {
+	cs.Handle("rx", func(args []string) (string, error) {
+		switch {
+		case len(args) == 0:
+			return ss.rxChain.String(), nil
+		case len(args) == 2 && args[0] == "gain":
+			db, err := strconv.ParseFloat(args[1], 64)
+			if err != nil {
+				return "", fmt.Errorf("invalid gain %q", args[1])
+			}
+			ss.rxChain.SetGain(db)
+			return "", nil
+		case len(args) == 1 && args[0] == "mute":
+			ss.rxChain.SetMuted(true)
+			return "", nil
+		case len(args) == 1 && args[0] == "unmute":
+			ss.rxChain.SetMuted(false)
+			return "", nil
+		}
+
+		return "", errUsage
+	})
+}
+
+// serveRxVolume shows the volume on GET, a POST changes it with the gain
+// and mute form values.
+func serveRxVolume(ss *SerialStream) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			if text := r.FormValue("gain"); text != "" {
+				db, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					http.Error(w, "invalid gain", http.StatusBadRequest)
+					return
+				}
+				ss.rxChain.SetGain(db)
+			}
+			if text := r.FormValue("mute"); text != "" {
+				muted, err := strconv.ParseBool(text)
+				if err != nil {
+					http.Error(w, "invalid mute", http.StatusBadRequest)
+					return
+				}
+				ss.rxChain.SetMuted(muted)
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, ss.rxChain.String())
+	}
+}
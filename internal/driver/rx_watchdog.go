@@ -0,0 +1,43 @@
+package driver
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// watchRxStream restarts the audio stream of the rig when it stops while
+// receiving, a known hiccup of the firmware.
+func (ss *SerialStream) watchRxStream(stop chan struct{}, timeout time.Duration) {
+	if !ss.withAudio || ss.transparentCat || timeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+	ss.lastAudioAt.Store(time.Now().UnixNano())
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		// nothing is expected while transmitting, bridged or idle
+		if ss.link.IsTransmitting() || ss.passthrough.Load() || ss.isIdle.Load() {
+			ss.lastAudioAt.Store(time.Now().UnixNano())
+			continue
+		}
+
+		silence := time.Since(time.Unix(0, ss.lastAudioAt.Load()))
+		if silence < timeout {
+			continue
+		}
+		log.Warnf("No audio from the rig for %s, restarting the stream\n", silence.Round(time.Millisecond))
+		ss.Events.Publish(AudioStalled{silence})
+		// give it another timeout before trying again
+		ss.lastAudioAt.Store(time.Now().UnixNano())
+		ss.PushCommand(";UA2;RX;")
+	}
+}
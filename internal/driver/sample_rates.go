@@ -0,0 +1,117 @@
+package driver
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// the rates of the audio streamed by the usual firmware builds
+const (
+	nominalRxRate = 7820
+	nominalTxRate = 11520
+)
+
+// a rate further than this from the nominal one is a measurement gone wrong
+const maxRateDeviation = 0.15
+
+// setupSampleRates sets the rates of the audio from RX_SAMPLE_RATE and
+// TX_SAMPLE_RATE. With RX_SAMPLE_RATE=auto, the default, the nominal rates
+// are set and true is returned, the rate the rig streams at is left to be
+// measured by measureSampleRates. Both rates come from the same clock of
+// the rig, so the TX rate follows the RX one unless it's set.
+func (ss *SerialStream) setupSampleRates() bool {
+	text, ok := os.LookupEnv("RX_SAMPLE_RATE")
+	if !ok || text == "auto" {
+		ss.setSampleRates(nominalRxRate)
+		return true
+	}
+
+	rxRate := nominalRxRate
+	if value, err := strconv.Atoi(text); err == nil && value > 0 {
+		rxRate = value
+	} else {
+		log.Warnf("Invalid RX_SAMPLE_RATE value %q, using %d\n", text, rxRate)
+	}
+	ss.setSampleRates(rxRate)
+
+	return false
+}
+
+// measureSampleRates measures the rate the rig streams at over the window,
+// it has to be streaming already, and sets the rates from it. It returns
+// true when they have changed.
+func (ss *SerialStream) measureSampleRates(window time.Duration) bool {
+	measured, err := ss.measureRxRate(window)
+	switch {
+	case err != nil:
+		log.Warnf("Couldn't measure the RX sample rate, using %d Hz: %s\n", ss.RxRate(), err)
+		return false
+	case math.Abs(float64(measured)/nominalRxRate-1) > maxRateDeviation:
+		log.Warnf("The RX sample rate measured, %d Hz, is off, using %d Hz\n", measured, ss.RxRate())
+		return false
+	case measured == ss.RxRate():
+		return false
+	}
+	ss.setSampleRates(measured)
+
+	return true
+}
+
+func (ss *SerialStream) setSampleRates(rxRate int) {
+	txRate := envInt("TX_SAMPLE_RATE", roundRate(float64(nominalTxRate)*float64(rxRate)/nominalRxRate))
+	if txRate <= 0 {
+		txRate = nominalTxRate
+	}
+
+	ss.rxRate.Store(int32(rxRate))
+	ss.txRate.Store(int32(txRate))
+	ss.txPacer.SetRate(float64(txRate))
+	log.Printf("Sample rates: RX %d Hz, TX %d Hz\n", rxRate, txRate)
+}
+
+// measureRxRate counts the audio coming from the rig over the window. It
+// takes a copy of the audio, which keeps playing meanwhile.
+func (ss *SerialStream) measureRxRate(window time.Duration) (int, error) {
+	sink := ss.RxAudio.Add("rate", cap(ss.AudioOutBuf))
+	defer ss.RxAudio.Remove(sink)
+
+	timeout := time.After(window + ss.readyTimeout)
+	// the count starts with the first chunk, the stream may not be up yet
+	select {
+	case <-sink.C:
+	case <-timeout:
+		return 0, errors.New("no audio from the rig")
+	}
+
+	start := time.Now()
+	deadline := time.After(window)
+	count := 0
+	for {
+		select {
+		case samples := <-sink.C:
+			count += len(samples)
+		case <-deadline:
+			return roundRate(float64(count) / time.Since(start).Seconds()), nil
+		}
+	}
+}
+
+// roundRate rounds to 10 Hz, the measurement isn't any more precise.
+func roundRate(rate float64) int {
+	return int(math.Round(rate/10)) * 10
+}
+
+// RxRate is the sample rate of the audio from the rig.
+func (ss *SerialStream) RxRate() int {
+	return int(ss.rxRate.Load())
+}
+
+// TxRate is the sample rate of the audio for the rig.
+func (ss *SerialStream) TxRate() int {
+	return int(ss.txRate.Load())
+}
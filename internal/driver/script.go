@@ -0,0 +1,226 @@
+package driver
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Script is a Lua script hooked into the CAT traffic of the clients and
+// the state changes of the rig. It may define:
+//
+//	on_command(cmd)      -- a command from a client, without the ';'
+//	on_reply(reply)      -- a reply going to the clients
+//	on_event(name, value) -- "frequency", "mode", "tx" or "connected" changed
+//
+// on_command and on_reply return nothing to pass the message on as it is,
+// a string to replace it, "" to drop it. on_command may return a reply for
+// the client as the second value. The trusdx table gives access to the rig.
+//
+// The script runs one call at a time, but trusdx.send and trusdx.query let
+// the others run while they wait for the rig, or a query would hold up the
+// replies it waits for. Each call runs in a thread of its own, sharing the
+// globals, so the calls interleave like coroutines.
+type Script struct {
+	mu      sync.Mutex
+	state   *lua.LState
+	threads []*lua.LState // idle threads, reused by the calls
+	ss      *SerialStream
+	events  chan func()
+}
+
+// loadScriptFromEnv loads SCRIPT, it returns nil when there's none.
+func loadScriptFromEnv(ss *SerialStream) *Script {
+	path, ok := os.LookupEnv("SCRIPT")
+	if !ok {
+		return nil
+	}
+
+	script, err := LoadScript(path, ss)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	return script
+}
+
+func LoadScript(path string, ss *SerialStream) (*Script, error) {
+	s := &Script{state: lua.NewState(), ss: ss, events: make(chan func(), 64)}
+
+	api := s.state.NewTable()
+	s.state.SetFuncs(api, map[string]lua.LGFunction{
+		"send": func(L *lua.LState) int {
+			cmd := L.CheckString(1)
+			s.mu.Unlock()
+			defer s.mu.Lock()
+			ss.PushCommand(cmd)
+			return 0
+		},
+		"query": func(L *lua.LState) int {
+			cmd := strings.TrimSuffix(L.CheckString(1), ";")
+			s.mu.Unlock()
+			reply, err := ss.Query(cmd)
+			s.mu.Lock()
+			if err != nil {
+				L.Push(lua.LNil)
+				L.Push(lua.LString(err.Error()))
+				return 2
+			}
+			L.Push(lua.LString(string(reply) + ";"))
+			return 1
+		},
+		"frequency": func(L *lua.LState) int {
+			L.Push(lua.LNumber(ss.State.Frequency()))
+			return 1
+		},
+		"mode": func(L *lua.LState) int {
+			L.Push(lua.LString(modeNames[ss.State.Mode()]))
+			return 1
+		},
+		"transmitting": func(L *lua.LState) int {
+			L.Push(lua.LBool(ss.State.Transmitting()))
+			return 1
+		},
+		"log": func(L *lua.LState) int {
+			log.Infof("Script: %s\n", L.CheckString(1))
+			return 0
+		},
+	})
+	s.state.SetGlobal("trusdx", api)
+
+	// the functions of the trusdx table count on the lock being held
+	s.mu.Lock()
+	err := s.state.DoFile(path)
+	s.mu.Unlock()
+	if err != nil {
+		s.state.Close()
+		return nil, err
+	}
+
+	ss.Events.Subscribe(s.onEvent)
+	// events are delivered one by one, away from the CAT loops, so the
+	// script can query the rig
+	go func() {
+		for event := range s.events {
+			event()
+		}
+	}()
+
+	return s, nil
+}
+
+// call calls a global function of the script, if it's defined, and returns
+// its results. It must be called with the lock held.
+func (s *Script) call(name string, results int, args ...lua.LValue) ([]lua.LValue, bool) {
+	fn, ok := s.state.GetGlobal(name).(*lua.LFunction)
+	if !ok {
+		return nil, false
+	}
+
+	var thread *lua.LState
+	if n := len(s.threads); n > 0 {
+		thread, s.threads = s.threads[n-1], s.threads[:n-1]
+	} else {
+		thread, _ = s.state.NewThread()
+	}
+	defer func() { s.threads = append(s.threads, thread) }()
+
+	err := thread.CallByParam(lua.P{Fn: fn, NRet: results, Protect: true}, args...)
+	if err != nil {
+		log.Warnf("Script %s: %s\n", name, err)
+		return nil, false
+	}
+
+	values := make([]lua.LValue, results)
+	for i := results - 1; i >= 0; i-- {
+		values[i] = thread.Get(-1)
+		thread.Pop(1)
+	}
+
+	return values, true
+}
+
+// FilterCommands passes the commands of a client through on_command, it
+// returns the commands for the rig and the replies for the client.
+func (s *Script) FilterCommands(cmdString string) (string, string) {
+	if s == nil {
+		return cmdString, ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cmds, replies strings.Builder
+	for _, cmd := range strings.Split(cmdString, ";") {
+		if cmd == "" {
+			continue
+		}
+		values, ok := s.call("on_command", 2, lua.LString(cmd))
+		if !ok {
+			cmds.WriteString(cmd + ";")
+			continue
+		}
+
+		if replacement, isString := values[0].(lua.LString); isString {
+			cmd = string(replacement)
+		}
+		if cmd != "" {
+			cmds.WriteString(strings.TrimSuffix(cmd, ";") + ";")
+		}
+		if reply, isString := values[1].(lua.LString); isString {
+			replies.WriteString(string(reply))
+		}
+	}
+
+	return cmds.String(), replies.String()
+}
+
+// FilterReply passes a reply for the clients through on_reply.
+func (s *Script) FilterReply(reply []byte) []byte {
+	if s == nil {
+		return reply
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values, ok := s.call("on_reply", 1, lua.LString(reply))
+	if !ok {
+		return reply
+	}
+	if replacement, isString := values[0].(lua.LString); isString {
+		return []byte(replacement)
+	}
+
+	return reply
+}
+
+func (s *Script) onEvent(event Event) {
+	var name string
+	var value lua.LValue
+	switch e := event.(type) {
+	case FrequencyChanged:
+		name, value = "frequency", lua.LNumber(e.Frequency)
+	case ModeChanged:
+		name, value = "mode", lua.LString(modeNames[e.Mode])
+	case PttChanged:
+		name, value = "tx", lua.LBool(e.Transmitting)
+	case ConnectionChanged:
+		name, value = "connected", lua.LBool(e.Connected)
+	default:
+		return
+	}
+
+	select {
+	case s.events <- func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.call("on_event", 0, lua.LString(name), value)
+	}:
+	default:
+		log.Warnln("Script: events dropped, on_event is too slow")
+	}
+}
@@ -0,0 +1,89 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// runSelfTest sends a tone through the TX audio path to a simulated rig,
+// which loops it back, and checks what comes out of the RX audio path.
+func runSelfTest(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	ss := newSerialStream("simulator", OpenSimulatedPort)
+	// never key real outputs for the simulator
+	ss.pttOutputs.Close()
+	ss.pttOutputs = nil
+	// the loopback is compared untouched
+	ss.rxChain = new(RxChain)
+	ss.rxChain.SetGain(0)
+	ss.Start()
+	defer ss.Close()
+
+	ss.PushCommand(";UA2;TX;")
+	time.Sleep(50 * time.Millisecond)
+
+	sent := generateTone(11520, 0.5, 11520, 1000)
+	// the samples are remapped as they are written, a chunk at a time
+	var expected []byte
+	remap := newSemicolonRemapFromEnv()
+	for i := 0; i < len(sent); i += dataChunkLength {
+		expected = append(expected, remap.Apply(sent[i:i+dataChunkLength])...)
+	}
+	start := time.Now()
+	go func() {
+		// at the pace of the sound card
+		ticker := time.NewTicker(time.Second * dataChunkLength / 11520)
+		defer ticker.Stop()
+		for i := 0; i < len(sent); i += dataChunkLength {
+			<-ticker.C
+			ss.AudioInBuf <- sent[i : i+dataChunkLength]
+		}
+	}()
+
+	var received []byte
+	var latency time.Duration
+	timeout := time.After(5 * time.Second)
+receive:
+	for len(received) < len(expected) {
+		select {
+		case samples := <-ss.AudioOutBuf:
+			if received == nil {
+				latency = time.Since(start)
+			}
+			received = append(received, samples...)
+		case <-timeout:
+			break receive
+		}
+	}
+	ss.PushCommand("RX;")
+
+	differ := 0
+	for i := range received {
+		if i >= len(expected) || received[i] != expected[i] {
+			differ++
+		}
+	}
+	sentLevel := rmsLevel(expected)
+	receivedLevel := math.Inf(-1)
+	if len(received) > 0 {
+		receivedLevel = rmsLevel(received)
+	}
+
+	fmt.Printf("Samples:   %d sent, %d received\n", len(expected), len(received))
+	fmt.Printf("Integrity: %d samples differ\n", differ)
+	fmt.Printf("Level:     %.1f dBFS sent, %.1f dBFS received\n", sentLevel, receivedLevel)
+	fmt.Printf("Latency:   %s\n", latency.Round(time.Millisecond))
+
+	if len(received) != len(expected) || differ > 0 || math.Abs(sentLevel-receivedLevel) > 0.1 {
+		fmt.Println("FAIL")
+		return errors.New("self-test failed")
+	}
+	fmt.Println("PASS")
+
+	return nil
+}
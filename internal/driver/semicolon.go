@@ -0,0 +1,89 @@
+package driver
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ';' ends the TX audio, so samples can't take its value.
+const semicolon = 0x3b
+
+const (
+	// all of them become 0x3a
+	semicolonDown = "down"
+	// all of them become 0x3c
+	semicolonUp = "up"
+	// the one closer to the neighbours, following the waveform
+	semicolonSlope = "slope"
+	// 0x3a, with the error carried to the next sample
+	semicolonDiffuse = "diffuse"
+)
+
+// SemicolonRemap moves TX samples off the value of ';'. It keeps the last
+// sample across chunks.
+type SemicolonRemap struct {
+	strategy string
+	previous byte
+	carry    int
+}
+
+func newSemicolonRemapFromEnv() *SemicolonRemap {
+	strategy, ok := os.LookupEnv("TX_SEMICOLON")
+	if !ok {
+		strategy = semicolonDown
+	}
+
+	switch strategy {
+	case semicolonDown, semicolonUp, semicolonSlope, semicolonDiffuse:
+	default:
+		log.Warnf("Invalid TX_SEMICOLON value %q, using %s\n", strategy, semicolonDown)
+		strategy = semicolonDown
+	}
+
+	return &SemicolonRemap{strategy: strategy, previous: 128}
+}
+
+// Apply returns a remapped copy of the samples.
+func (sr *SemicolonRemap) Apply(samples []byte) []byte {
+	remapped := make([]byte, len(samples))
+	copy(remapped, samples)
+
+	for i, sample := range remapped {
+		if sr.strategy == semicolonDiffuse && sr.carry != 0 {
+			value := int(sample) + sr.carry
+			sr.carry = 0
+			if value > 255 {
+				value = 255
+			}
+			sample = byte(value)
+		}
+
+		if sample == semicolon {
+			switch sr.strategy {
+			case semicolonUp:
+				sample = semicolon + 1
+			case semicolonSlope:
+				next := sample
+				if i+1 < len(remapped) {
+					next = remapped[i+1]
+				}
+				if int(sr.previous)+int(next) > 2*semicolon {
+					sample = semicolon + 1
+				} else {
+					sample = semicolon - 1
+				}
+			case semicolonDiffuse:
+				sample = semicolon - 1
+				sr.carry = 1
+			default:
+				sample = semicolon - 1
+			}
+		}
+
+		remapped[i] = sample
+		sr.previous = sample
+	}
+
+	return remapped
+}
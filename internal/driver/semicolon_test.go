@@ -0,0 +1,56 @@
+package driver
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSemicolonRemap(t *testing.T) {
+	tests := []struct {
+		strategy string
+		chunks   [][]byte
+		want     [][]byte
+	}{
+		{
+			strategy: semicolonDown,
+			chunks:   [][]byte{{0x3b, 0x80, 0x3b}},
+			want:     [][]byte{{0x3a, 0x80, 0x3a}},
+		},
+		{
+			strategy: semicolonUp,
+			chunks:   [][]byte{{0x3b, 0x3a, 0x3c}},
+			want:     [][]byte{{0x3c, 0x3a, 0x3c}},
+		},
+		{
+			strategy: semicolonSlope,
+			chunks:   [][]byte{{0x10, 0x3b, 0x10}, {0x80, 0x3b, 0x80}},
+			want:     [][]byte{{0x10, 0x3a, 0x10}, {0x80, 0x3c, 0x80}},
+		},
+		{
+			// the previous sample comes from the chunk before
+			strategy: semicolonSlope,
+			chunks:   [][]byte{{0x10}, {0x3b}},
+			want:     [][]byte{{0x10}, {0x3a}},
+		},
+		{
+			strategy: semicolonDiffuse,
+			chunks:   [][]byte{{0x3b, 0x3b, 0x3a}, {0x3b}, {0xff}},
+			want:     [][]byte{{0x3a, 0x3c, 0x3a}, {0x3a}, {0xff}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			sr := &SemicolonRemap{strategy: tt.strategy, previous: 128}
+			for i, chunk := range tt.chunks {
+				original := append([]byte(nil), chunk...)
+				if got := sr.Apply(chunk); !bytes.Equal(got, tt.want[i]) {
+					t.Errorf("Apply(% x) = % x, want % x", chunk, got, tt.want[i])
+				}
+				if !bytes.Equal(chunk, original) {
+					t.Errorf("Apply(% x) changed its input", original)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.bug.st/serial"
+	"go.bug.st/serial/enumerator"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// the CH340 USB serial converter of the rig
+	rigUsbVid = "1A86"
+	rigUsbPid = "7523"
+)
+
+// SerialPort is the serial port of the rig, it gives access to the control
+// lines and its reads time out.
+type SerialPort struct {
+	serial.Port
+}
+
+func OpenSerialPort(name string) (*SerialPort, error) {
+	port, err := serial.Open(name, &serial.Mode{
+		BaudRate: 115200,
+		DataBits: 8,
+		Parity:   serial.NoParity,
+		StopBits: serial.OneStopBit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return &SerialPort{port}, nil
+}
+
+// isPortMissing tells whether the port doesn't exist, e.g. when the rig
+// isn't plugged in.
+func isPortMissing(err error) bool {
+	var portErr *serial.PortError
+	if errors.As(err, &portErr) {
+		return portErr.Code() == serial.PortNotFound
+	}
+
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// Flush waits until everything written has been transmitted.
+func (p *SerialPort) Flush() error {
+	return p.Drain()
+}
+
+// waitRigReady asks the rig for its ID until it answers, it's busy for a
+// moment after the port has been opened, which resets it. The read timeout
+// of the port is changed.
+func waitRigReady(port *SerialPort, timeout time.Duration) error {
+	port.SetReadTimeout(50 * time.Millisecond)
+	deadline := time.Now().Add(timeout)
+	chunk := make([]byte, maxReplyLength)
+
+	for time.Now().Before(deadline) {
+		port.Write([]byte(";ID;"))
+		port.Flush()
+
+		var reply []byte
+		for retry := time.Now().Add(250 * time.Millisecond); time.Now().Before(retry); {
+			n, err := port.Read(chunk)
+			if err != nil {
+				return err
+			}
+			reply = append(reply, chunk[:n]...)
+			if start := bytes.Index(reply, []byte("ID")); start >= 0 && bytes.Contains(reply[start:], []byte(";")) {
+				// answers to the earlier attempts would confuse the clients
+				time.Sleep(50 * time.Millisecond)
+				port.ResetInputBuffer()
+				return nil
+			}
+		}
+	}
+
+	return ErrNoReply
+}
+
+// serialPortName picks SERIAL_PORT if set, otherwise the first port which
+// looks like the rig.
+func serialPortName() string {
+	if name, ok := os.LookupEnv("SERIAL_PORT"); ok {
+		return name
+	}
+
+	if name, ok := detectRigPort(); ok {
+		return name
+	}
+
+	// the udev rule, or the device handed to a container under this name
+	if _, err := os.Stat("/dev/trusdx"); err == nil {
+		return "/dev/trusdx"
+	}
+
+	return defaultSerialPort
+}
+
+// detectRigPort finds the port of the rig by its USB ids.
+func detectRigPort() (string, bool) {
+	ports, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		return "", false
+	}
+	for _, port := range ports {
+		if port.IsUSB && strings.EqualFold(port.VID, rigUsbVid) && strings.EqualFold(port.PID, rigUsbPid) {
+			return port.Name, true
+		}
+	}
+
+	return "", false
+}
+
+func runListPorts(args []string) error {
+	ports, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		// not every OS can tell the details, the names will do
+		names, err := serial.GetPortsList()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	for _, port := range ports {
+		if port.IsUSB {
+			fmt.Printf("%s\tUSB %s:%s %s\n", port.Name, port.VID, port.PID, port.Product)
+		} else {
+			fmt.Println(port.Name)
+		}
+	}
+
+	return nil
+}
+
+// pollable re-opens f in the non-blocking mode, so it's handled by the
+// runtime poller and reads can have deadlines.
+func pollable(f *os.File) (*os.File, error) {
+	fd, err := unix.Dup(int(f.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	name := f.Name()
+	f.Close()
+
+	return os.NewFile(uintptr(fd), name), nil
+}
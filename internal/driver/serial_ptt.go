@@ -0,0 +1,76 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	"go.bug.st/serial"
+)
+
+// SerialPttOutput keys with the RTS and/or DTR line of a serial port, e.g. a
+// USB serial adapter wired to the PTT input of an amplifier or transverter.
+// The spec is the port with the lines, "/dev/ttyUSB1:rts", "/dev/ttyUSB1:dtr"
+// or "/dev/ttyUSB1:both", RTS by default; "!dtr" is active low.
+type SerialPttOutput struct {
+	port      serial.Port
+	name      string
+	rts       bool
+	dtr       bool
+	activeLow bool
+}
+
+func NewSerialPttOutput(spec string) (*SerialPttOutput, error) {
+	name, lines, _ := strings.Cut(spec, ":")
+	spo := &SerialPttOutput{name: name, activeLow: strings.HasPrefix(lines, "!")}
+
+	switch strings.ToLower(strings.TrimPrefix(lines, "!")) {
+	case "", "rts":
+		spo.rts = true
+	case "dtr":
+		spo.dtr = true
+	case "both":
+		spo.rts = true
+		spo.dtr = true
+	default:
+		return nil, fmt.Errorf("invalid serial PTT lines %q, expected rts, dtr or both", lines)
+	}
+
+	// the lines start released, although most systems pulse them on open
+	port, err := serial.Open(name, &serial.Mode{
+		BaudRate: 9600,
+		InitialStatusBits: &serial.ModemOutputBits{
+			RTS: spo.activeLow,
+			DTR: spo.activeLow,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	spo.port = port
+	if err := spo.Set(false); err != nil {
+		port.Close()
+		return nil, err
+	}
+
+	return spo, nil
+}
+
+func (spo *SerialPttOutput) Set(on bool) error {
+	level := on != spo.activeLow
+	if spo.rts {
+		if err := spo.port.SetRTS(level); err != nil {
+			return fmt.Errorf("%s: %w", spo.name, err)
+		}
+	}
+	if spo.dtr {
+		if err := spo.port.SetDTR(level); err != nil {
+			return fmt.Errorf("%s: %w", spo.name, err)
+		}
+	}
+
+	return nil
+}
+
+func (spo *SerialPttOutput) Close() error {
+	return spo.port.Close()
+}
@@ -0,0 +1,804 @@
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type SerialStream struct {
+	AudioOutBuf      chan []byte
+	AudioInBuf       chan []byte
+	RxAudio          *AudioTee
+	TxAudio          *AudioTee
+	RepliesBuf       chan []byte
+	CmdsBuf          chan []byte
+	State            *RigState
+	portMu           sync.Mutex // port and stop change when the port is opened again
+	port             *SerialPort
+	portName         atomic.Value // string
+	link             *LinkStateMachine
+	txKind           atomic.Int32 // TxKind of the last TX command
+	inAudioMessage   bool         // owned by receiveDataStream
+	chunkLength      int
+	running          atomic.Bool
+	polls            *PollLimiter
+	cannedReplies    *CannedReplies
+	ifMaxAge         time.Duration
+	replies          *ReplyRouter
+	aiMode           atomic.Int32
+	aiPollInterval   time.Duration
+	stop             chan struct{}
+	passthrough      atomic.Bool
+	isIdle           atomic.Bool
+	rawBuf           chan []byte
+	capture          *PcapWriter
+	catHistory       *CatHistory
+	identity         rigIdentity
+	closed           atomic.Bool
+	streamGapTimeout time.Duration
+	Stats            Stats
+	CatStats         *CatAnalytics
+	RxJitter         *JitterEstimator
+	pttOutputs       *PttOutputs
+	txAudioDelay     time.Duration
+	txPacer          *TxPacer
+	rxRate           atomic.Int32
+	txRate           atomic.Int32
+	semicolons       *SemicolonRemap
+	rxChain          *RxChain
+	txChain          *TxChain
+	modeProfiles     *ModeProfiles
+	isTxAudioOff     atomic.Bool // by the profile of the mode
+	lastAudioAt      atomic.Int64
+	lastReplyAt      atomic.Int64
+	rxStallTimeout   time.Duration
+	traceAudioBytes  int
+	readyTimeout     time.Duration
+	withAudio        bool
+	transparentCat   bool
+	openPort         func(string) (*SerialPort, error)
+	deferQueries     bool
+	deferMu          sync.Mutex
+	deferred         []deferredQuery
+	playBuf          chan []byte
+	isPlaying        atomic.Bool
+	playMu           sync.Mutex
+	playStop         chan struct{}
+	txTap            atomic.Pointer[chan []byte]
+	Events           *EventBus
+}
+
+// the longest reply of the rig, IF, is 38 characters
+const maxReplyLength = 64
+
+var (
+	ErrNoReply     = errors.New("no reply from the rig")
+	ErrSuspended   = errors.New("the serial port is released")
+	ErrPassthrough = errors.New("the serial port is in passthrough mode")
+)
+
+func NewSerialStream(name string) *SerialStream {
+	return newSerialStream(name, OpenSerialPort)
+}
+
+func newSerialStream(name string, openPort func(string) (*SerialPort, error)) *SerialStream {
+	ss := new(SerialStream)
+	ss.openPort = openPort
+	ss.link = NewLinkStateMachine()
+	ss.chunkLength = 48
+	ss.withAudio = driverMode() != driverModeCat
+	ss.transparentCat = driverMode() == driverModeAudio
+	ss.AudioOutBuf = make(chan []byte, envSize("RX_QUEUE_SIZE", 128))
+	ss.RxJitter = NewJitterEstimator(envInt("RX_BUFFER_MIN", 1), envInt("RX_BUFFER_MAX", 32))
+	ss.AudioInBuf = make(chan []byte, envSize("TX_QUEUE_SIZE", 128))
+	ss.RxAudio = NewAudioTee()
+	ss.TxAudio = NewAudioTee()
+	ss.RepliesBuf = make(chan []byte, envSize("REPLY_QUEUE_SIZE", 32))
+	ss.CmdsBuf = make(chan []byte, envSize("COMMAND_QUEUE_SIZE", 32))
+	ss.rawBuf = make(chan []byte, 32)
+	ss.playBuf = make(chan []byte, 4)
+	ss.streamGapTimeout = envDuration("STREAM_GAP_TIMEOUT", 250*time.Millisecond)
+	if ss.streamGapTimeout <= 0 {
+		ss.streamGapTimeout = 250 * time.Millisecond
+	}
+	ss.Events = NewEventBus()
+	ss.Events.Subscribe(ss.Stats.count)
+	ss.CatStats = NewCatAnalytics()
+	ss.State = NewRigState(ss.Events)
+	ss.ifMaxAge = envDuration("IF_CACHE_TTL", 2*time.Second)
+	ss.Events.Subscribe(ss.notifyAutoInformation)
+	replies, err := newReplyRouterFromEnv()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	ss.replies = replies
+	ss.polls = NewPollLimiter(envDuration("POLL_INTERVAL", 200*time.Millisecond), replies.Patience)
+	ss.aiPollInterval = envDuration("AI_POLL_INTERVAL", time.Second)
+	ss.deferQueries = os.Getenv("TX_DEFER_QUERIES") != "0"
+	ss.identity = rigIdentityFromEnv()
+	ss.cannedReplies = NewCannedReplies(ss.identity)
+	if path, ok := os.LookupEnv("CANNED_REPLIES"); ok {
+		if err := ss.cannedReplies.Load(path); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if path, ok := os.LookupEnv("PCAP_FILE"); ok {
+		capture, err := NewPcapWriter(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		ss.capture = capture
+	}
+	ss.pttOutputs = newPttOutputsFromEnv()
+	ss.readyTimeout = envDuration("READY_TIMEOUT", 10*time.Second)
+	ss.txAudioDelay = envDuration("TX_AUDIO_DELAY", 10*time.Millisecond)
+	ss.rxRate.Store(nominalRxRate)
+	ss.txRate.Store(nominalTxRate)
+	ss.txPacer = NewTxPacer(nominalTxRate, envInt("TX_PACING_BURST", 240))
+	ss.semicolons = newSemicolonRemapFromEnv()
+	ss.rxChain = newRxChainFromEnv()
+	ss.txChain = newTxChainFromEnv()
+	profiles, err := newModeProfilesFromEnv()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if profiles != nil {
+		ss.modeProfiles = profiles
+		ss.txChain.isAdjustable = profiles.AdjustsTx()
+		ss.Events.Subscribe(ss.applyModeProfile)
+	}
+	ss.rxStallTimeout = envDuration("RX_STALL_TIMEOUT", 3*time.Second)
+	ss.traceAudioBytes = envInt("TRACE_AUDIO_BYTES", 16)
+	ss.catHistory = NewCatHistory(envInt("CRASH_CAT_HISTORY", 100))
+	ss.portName.Store(name)
+	if err := ss.openWhenPresent(envDuration("RIG_WAIT_TIMEOUT", 0)); err != nil {
+		log.Fatalln(err)
+	}
+
+	return ss
+}
+
+// the longest delay between the attempts to open the port at startup
+const maxOpenDelay = 30 * time.Second
+
+// openWhenPresent opens the port, waiting with a growing delay while it
+// doesn't exist, so the driver can start before the rig is plugged in. A
+// timeout of 0 waits forever.
+func (ss *SerialStream) openWhenPresent(timeout time.Duration) error {
+	start := time.Now()
+	delay := time.Second
+	for {
+		err := ss.open(false)
+		if err == nil || !isPortMissing(err) {
+			return err
+		}
+		if timeout > 0 && time.Since(start)+delay > timeout {
+			return err
+		}
+
+		log.Infof("%s, trying again in %s\n", err, delay)
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxOpenDelay {
+			delay = maxOpenDelay
+		}
+		ss.redetectPort()
+	}
+}
+
+// redetectPort follows the rig to another name when it's found by its USB
+// ids. Otherwise the name stays, a retry never falls back to the default
+// port, which may be another device.
+func (ss *SerialStream) redetectPort() {
+	if _, ok := os.LookupEnv("SERIAL_PORT"); ok {
+		return
+	}
+	if name, ok := detectRigPort(); ok {
+		ss.portName.Store(name)
+	}
+}
+
+// open opens the port and waits for the rig to answer. At startup it goes
+// on without an answer, the rig may be busy, but when the rig comes back
+// the port has to be the rig.
+func (ss *SerialStream) open(isReturn bool) error {
+	port, err := ss.openPort(ss.PortName())
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	if err := waitRigReady(port, ss.readyTimeout); errors.Is(err, ErrNoReply) && !isReturn {
+		log.Warnf("%s: the rig doesn't answer, going on anyway\n", ss.PortName())
+	} else if err != nil {
+		port.Close()
+		return err
+	} else {
+		log.Debugf("The rig answered after %s\n", time.Since(start).Round(time.Millisecond))
+	}
+	// the audio stream is continuous, a timeout means it has stopped
+	if err := port.SetReadTimeout(ss.streamGapTimeout); err != nil {
+		port.Close()
+		return err
+	}
+	ss.portMu.Lock()
+	ss.port = port
+	ss.portMu.Unlock()
+
+	return nil
+}
+
+func (ss *SerialStream) Start() {
+	ss.portMu.Lock()
+	ss.stop = make(chan struct{})
+	port, stop := ss.port, ss.stop
+	ss.portMu.Unlock()
+
+	ss.running.Store(true)
+	ss.goGuarded("receive", func() { ss.receiveDataStream(port) })
+	ss.goGuarded("send", func() { ss.sendDataStream(port, stop) })
+	ss.goGuarded("auto information", func() { ss.pollAutoInformation(stop) })
+	ss.goGuarded("RX watchdog", func() { ss.watchRxStream(stop, ss.rxStallTimeout) })
+	ss.goGuarded("reply expiry", func() { ss.expireReplies(stop) })
+	ss.goGuarded("keepalive", func() {
+		ss.keepAlive(stop, envDuration("KEEPALIVE_INTERVAL", 10*time.Second), envSize("KEEPALIVE_FAILURES", 3))
+	})
+}
+
+func (ss *SerialStream) handleDataChunk(buffer *bytes.Buffer) {
+	for buffer.Len() > 0 {
+		if !ss.handleMessage(buffer) {
+			return
+		}
+	}
+}
+
+// handleMessage takes one message out of the buffer, it returns false when
+// the rest of the message hasn't arrived yet.
+func (ss *SerialStream) handleMessage(buffer *bytes.Buffer) bool {
+	data, err := buffer.ReadBytes(';')
+	isComplete := !errors.Is(err, io.EOF)
+
+	if ss.inAudioMessage {
+		ss.pushAudio(bytes.TrimSuffix(data, []byte(";")))
+		if isComplete {
+			ss.inAudioMessage = false
+			ss.link.Transition(StateRxStream, StateRx, "end of the audio")
+		}
+		return true
+	}
+
+	// a message starts with a command name, anything before it is garbage
+	if start := messageStart(data); start > 0 {
+		ss.Stats.DiscardedBytes.Add(uint64(start))
+		data = data[start:]
+	}
+
+	if bytes.HasPrefix(data, []byte("US")) {
+		ss.pushAudio(bytes.TrimSuffix(data[2:], []byte(";")))
+		ss.inAudioMessage = !isComplete
+		// the audio looped back while transmitting doesn't count
+		if ss.inAudioMessage && !ss.link.Is(StateTx) {
+			ss.link.To(StateRxStream, "audio")
+		}
+		return true
+	}
+
+	if !isComplete {
+		if len(data) > maxReplyLength {
+			// no reply is that long, the delimiter must have been lost
+			ss.Stats.DiscardedBytes.Add(uint64(len(data)))
+			ss.Stats.Resyncs.Add(1)
+			return false
+		}
+		buffer.Write(data)
+		return false
+	}
+
+	if len(data) <= 1 {
+		return true
+	}
+
+	ss.logTraffic(pcapFromRig, pcapCat, data)
+	ss.lastReplyAt.Store(time.Now().UnixNano())
+	ss.link.Transition(StateTxTail, StateRx, "reply")
+	ss.CatStats.Replied(data)
+	ss.State.Observe(data, true)
+	var client *CatClient
+	if waiter := ss.replies.Route(data); waiter != nil {
+		if waiter.isDuplicate {
+			return true
+		}
+		if waiter.reply != nil {
+			waiter.reply <- bytes.TrimSuffix(data, []byte(";"))
+			return true
+		}
+		client = waiter.client
+	}
+	ss.Stats.CatReplies.Add(1)
+	for _, client := range ss.polls.Resolve(data, client) {
+		ss.reply(client, data)
+	}
+
+	return true
+}
+
+func (ss *SerialStream) pushAudio(samples []byte) {
+	if len(samples) == 0 {
+		return
+	}
+	ss.logTraffic(pcapFromRig, pcapAudio, samples)
+	ss.RxJitter.Observe()
+	ss.lastAudioAt.Store(time.Now().UnixNano())
+	samples = ss.rxChain.Process(samples)
+	ss.RxAudio.Write(samples)
+
+	select {
+	case ss.AudioOutBuf <- samples:
+		ss.Stats.RxChunks.Add(1)
+	default:
+		ss.Events.Publish(ChunkDropped{"rx"})
+	}
+}
+
+func isCommandChar(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+// messageStart finds the first pair of command name characters in data.
+func messageStart(data []byte) int {
+	for i := 0; i < len(data); i++ {
+		if isCommandChar(data[i]) && (i+1 == len(data) || isCommandChar(data[i+1])) {
+			return i
+		}
+	}
+
+	return len(data)
+}
+
+// TapRxAudio gets a copy of the received audio, until the returned function
+// is called.
+func (ss *SerialStream) TapRxAudio() (chan []byte, func()) {
+	sink := ss.RxAudio.Add("tap", 256)
+
+	return sink.C, func() {
+		ss.RxAudio.Remove(sink)
+	}
+}
+
+// TapTxAudio gets a copy of the audio from the sound card, transmitted or
+// not, until the returned function is called.
+func (ss *SerialStream) TapTxAudio() (chan []byte, func()) {
+	tap := make(chan []byte, 256)
+	ss.txTap.Store(&tap)
+
+	return tap, func() {
+		ss.txTap.CompareAndSwap(&tap, nil)
+	}
+}
+
+func (ss *SerialStream) receiveDataStream(port *SerialPort) {
+	buffer := bytes.NewBuffer(make([]byte, ss.chunkLength))
+	buffer.Reset()
+	ss.inAudioMessage = false
+
+	for ss.IsRunning() {
+		chunk := make([]byte, ss.chunkLength)
+		readCount, err := port.Read(chunk)
+		if readCount == 0 && err == nil {
+			if ss.inAudioMessage {
+				// the stream has stopped without the delimiter
+				ss.inAudioMessage = false
+				ss.Stats.Resyncs.Add(1)
+				log.Debugln("[Resync]: audio stream timed out")
+			}
+			ss.link.Transition(StateRxStream, StateRx, "audio timed out")
+			continue
+		} else if err != nil && !ss.IsRunning() {
+			return
+		} else if err != nil {
+			// e.g. unplugged, or gone during a system sleep
+			log.Warnf("Serial port %s lost: %s\n", ss.PortName(), err)
+			go ss.reconnect()
+			return
+		}
+		if ss.passthrough.Load() {
+			buffer.Reset()
+			ss.inAudioMessage = false
+			if readCount > 0 {
+				ss.logTraffic(pcapFromRig, pcapCat, chunk[:readCount])
+				ss.RepliesBuf <- chunk[:readCount]
+			}
+			continue
+		}
+		buffer.Write(chunk[:readCount])
+		ss.handleDataChunk(buffer)
+	}
+}
+
+func (ss *SerialStream) sendDataStream(port *SerialPort, stop chan struct{}) {
+	for ss.IsRunning() {
+		select {
+		case <-stop:
+			return
+		case raw := <-ss.rawBuf:
+			ss.logTraffic(pcapToRig, pcapCat, raw)
+			port.Write(raw)
+			port.Flush()
+		case cmd := <-ss.CmdsBuf:
+			if ss.isTxAudio() {
+				time.Sleep(10 * time.Millisecond)
+				port.Write([]byte(";"))
+				// fmt.Print(";")
+				port.Flush()
+			}
+
+			if bytes.HasPrefix(cmd, []byte("RX")) {
+				if ss.link.Transition(StateTx, StateTxTail, "RX") {
+					// this goroutine reads the queue, so it can't fill it
+					go ss.sendDeferred()
+				}
+			} else if bytes.HasPrefix(cmd, []byte("TX")) {
+				kind := txKindOf(string(cmd), ss.State.Mode())
+				if kind == TxAudio && ss.isTxAudioOff.Load() {
+					kind = TxKey
+				}
+				log.Debugf("[Serial -> Rig]: %s; transmits %s\n", cmd, kind)
+				ss.txKind.Store(int32(kind))
+				ss.pttOutputs.Key()
+			}
+
+			ss.State.Observe(cmd, false)
+			cmd = append(cmd, ';')
+			ss.Stats.CatCommands.Add(1)
+			ss.CatStats.Sent(string(cmd[:len(cmd)-1]))
+			ss.logTraffic(pcapToRig, pcapCat, cmd)
+			port.Write(cmd)
+			// fmt.Printf("%s", cmd)
+			port.Flush()
+
+			if bytes.HasPrefix(cmd, []byte("RX")) {
+				ss.pttOutputs.Unkey()
+			}
+			if bytes.HasPrefix(cmd, []byte("TX")) && ss.link.To(StateTx, string(cmd[:len(cmd)-1])) && ss.isTxAudio() {
+				// the audio waits for the rig, and the relays of the station
+				time.Sleep(ss.txAudioDelay)
+				ss.txPacer.Reset()
+			}
+		case samples := <-ss.AudioInBuf:
+			if tap := ss.txTap.Load(); tap != nil {
+				select {
+				case *tap <- samples:
+				default:
+				}
+			}
+			// played audio replaces the sound card, with no audio to send it's dropped
+			if ss.isTxAudio() && !ss.isPlaying.Load() {
+				ss.writeAudio(port, samples)
+			}
+		case samples := <-ss.playBuf:
+			if ss.isTxAudio() {
+				ss.writeAudio(port, samples)
+			}
+		}
+	}
+}
+
+func (ss *SerialStream) writeAudio(port *SerialPort, samples []byte) {
+	ss.TxAudio.Write(samples)
+	samples = ss.semicolons.Apply(samples)
+	ss.txPacer.Wait(len(samples))
+	ss.Stats.TxChunks.Add(1)
+	ss.logTraffic(pcapToRig, pcapAudio, samples)
+	port.Write([]byte(samples))
+	// fmt.Printf("%s", []byte(samples))
+	port.Flush()
+}
+
+func (ss *SerialStream) PushCommand(cmdString string) {
+	ss.PushCommandFrom(nil, cmdString)
+}
+
+// PushCommandFrom queues the commands of a CAT client, its replies go back
+// to it, nil is the virtual port.
+func (ss *SerialStream) PushCommandFrom(client *CatClient, cmdString string) {
+	if !ss.IsRunning() {
+		return
+	}
+
+	if ss.passthrough.Load() {
+		ss.rawBuf <- []byte(cmdString)
+		return
+	}
+
+	cmds := strings.Split(cmdString, ";")
+	for i, cmd := range cmds {
+		if cmd != "" || i == 0 {
+			if ss.transparentCat {
+				ss.CatStats.Requested(cmd, false)
+				// the virtual port too, or its replies could be taken for
+				// those of the driver's own queries
+				if isQuery(cmd) {
+					ss.replies.Expect(cmd, nil, client)
+				}
+				ss.CmdsBuf <- []byte(cmd)
+				continue
+			}
+
+			if reply, ok := ss.cannedReplies.Reply(cmd); ok {
+				ss.CatStats.Requested(cmd, true)
+				// settings have no reply
+				if len(reply) > 0 {
+					ss.reply(client, reply)
+				}
+				continue
+			}
+
+			if ss.handleAutoInformation(client, cmd) {
+				ss.CatStats.Requested(cmd, true)
+				continue
+			}
+
+			if cmd == "IF" {
+				if reply, ok := ss.State.IFReply(ss.ifMaxAge); ok {
+					ss.CatStats.Requested(cmd, true)
+					ss.reply(client, reply)
+					continue
+				}
+			}
+
+			if ss.deferDuringTx(client, cmd) {
+				continue
+			}
+
+			reply, forward := ss.polls.Admit(cmd, client)
+			ss.CatStats.Requested(cmd, !forward)
+			if reply != nil {
+				ss.reply(client, reply)
+			}
+			if forward {
+				if isQuery(cmd) {
+					ss.replies.Expect(cmd, nil, client)
+				}
+				ss.CmdsBuf <- []byte(cmd)
+			}
+		}
+	}
+}
+
+// Query sends a command to the rig on behalf of the driver itself and
+// waits for the reply, which isn't passed to CAT clients.
+func (ss *SerialStream) Query(cmd string) ([]byte, error) {
+	if !ss.IsRunning() {
+		return nil, ErrSuspended
+	}
+	if ss.passthrough.Load() {
+		return nil, ErrPassthrough
+	}
+
+	reply := make(chan []byte, 1)
+	ss.replies.Expect(cmd, reply, nil)
+	ss.CmdsBuf <- []byte(cmd)
+
+	// the router gives up first, this is in case the stream stops meanwhile
+	select {
+	case data := <-reply:
+		if data == nil {
+			return nil, fmt.Errorf("%s: %w", cmd, ErrNoReply)
+		}
+		return data, nil
+	case <-time.After(ss.replies.Patience(cmd) + ss.replies.Timeout(cmd)):
+		return nil, fmt.Errorf("%s: %w", cmd, ErrNoReply)
+	}
+}
+
+// expireReplies sends again the queries the rig hasn't answered in time,
+// and tells whoever asked when there are no retries left.
+func (ss *SerialStream) expireReplies(stop chan struct{}) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		retries, failures := ss.replies.Expire()
+		for _, waiter := range retries {
+			log.Debugf("[Serial -> Rig]: no reply to %s;, retry %d\n", waiter.cmd, waiter.attempts)
+			ss.Stats.CatRetries.Add(1)
+			select {
+			case ss.CmdsBuf <- []byte(waiter.cmd):
+			case <-stop:
+				return
+			}
+		}
+		for _, waiter := range failures {
+			log.Warnf("[Serial -> Rig]: no reply to %s; after %d attempts\n", waiter.cmd, waiter.attempts+1)
+			ss.Stats.CatTimeouts.Add(1)
+			if waiter.reply != nil {
+				select {
+				case waiter.reply <- nil:
+				default:
+				}
+				continue
+			}
+			for _, client := range ss.polls.Fail(waiter.cmd, waiter.client) {
+				ss.reply(client, []byte("?;"))
+			}
+		}
+	}
+}
+
+// queryForms are the queries which carry a parameter, by their prefix and
+// length, e.g. SM0 for the S-meter. Like those without one, the reply
+// starts with the query.
+var queryForms = []struct {
+	prefix string
+	length int
+}{
+	{"AG", 3},
+	{"SM", 3},
+	{"EX", defaultRigIdentity.exRead},
+}
+
+func isQuery(cmd string) bool {
+	if len(cmd) == 2 {
+		return cmd != "TX" && cmd != "RX"
+	}
+	for _, form := range queryForms {
+		if len(cmd) == form.length && strings.HasPrefix(cmd, form.prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (ss *SerialStream) Close() {
+	ss.closed.Store(true)
+	ss.release()
+	ss.pttOutputs.Close()
+	ss.capture.Close()
+}
+
+func (ss *SerialStream) release() {
+	if !ss.IsRunning() {
+		return
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if !ss.running.CompareAndSwap(true, false) {
+		return
+	}
+	ss.link.To(StateFault, "serial port released")
+	ss.portMu.Lock()
+	port, stop := ss.port, ss.stop
+	ss.portMu.Unlock()
+	close(stop)
+	ss.pttOutputs.Unkey()
+	time.Sleep(50 * time.Millisecond)
+	port.Flush()
+	port.Close()
+}
+
+// Suspend stops streaming and releases the serial port for other programs,
+// e.g. a firmware uploader.
+func (ss *SerialStream) Suspend() {
+	if !ss.IsRunning() {
+		return
+	}
+
+	ss.PushCommand(";UA0;")
+	ss.release()
+	ss.Events.Publish(ConnectionChanged{ss.PortName(), false})
+	log.Printf("Serial port %s released\n", ss.PortName())
+}
+
+// SetPassthrough bridges CAT clients directly to the serial port, byte for
+// byte, with audio streaming paused.
+func (ss *SerialStream) SetPassthrough(enabled bool) {
+	if enabled == ss.passthrough.Load() {
+		return
+	}
+
+	if enabled {
+		ss.PushCommand(";UA0;")
+		// let the command go out before the bridge takes over
+		time.Sleep(50 * time.Millisecond)
+		ss.passthrough.Store(true)
+		log.Println("Raw passthrough enabled")
+		return
+	}
+
+	ss.passthrough.Store(false)
+	if ss.withAudio && !ss.isIdle.Load() {
+		ss.PushCommand(";UA2;")
+	}
+	log.Println("Raw passthrough disabled")
+}
+
+// SetIdle stops the audio stream of the rig while no CAT client is
+// attached, and starts it again.
+func (ss *SerialStream) SetIdle(isIdle bool) {
+	if isIdle == ss.isIdle.Swap(isIdle) {
+		return
+	}
+
+	if ss.withAudio && !ss.passthrough.Load() {
+		if isIdle {
+			ss.PushCommand(";UA0;")
+		} else {
+			ss.PushCommand(";UA2;")
+		}
+	}
+	ss.Events.Publish(IdleChanged{isIdle})
+}
+
+// reconnect waits for the rig to come back, it may show up under a
+// different name when it's detected automatically.
+func (ss *SerialStream) reconnect() {
+	ss.release()
+	ss.Events.Publish(ConnectionChanged{ss.PortName(), false})
+
+	for !ss.closed.Load() {
+		time.Sleep(time.Second)
+		ss.redetectPort()
+		if err := ss.Resume(); err == nil {
+			return
+		}
+	}
+}
+
+func (ss *SerialStream) Resume() error {
+	if ss.IsRunning() {
+		return nil
+	}
+
+	if err := ss.open(true); err != nil {
+		return err
+	}
+	ss.link.To(StateRx, "taken over")
+	ss.Start()
+	ss.Events.Publish(ConnectionChanged{ss.PortName(), true})
+	if ss.withAudio && !ss.isIdle.Load() {
+		ss.PushCommand(";UA2;RX;")
+	} else {
+		ss.PushCommand(";RX;")
+	}
+	ss.sendDeferred()
+	log.Printf("Serial port %s taken over again\n", ss.PortName())
+
+	return nil
+}
+
+// IsRunning tells whether the driver has the serial port of the rig.
+func (ss *SerialStream) IsRunning() bool {
+	return ss.running.Load()
+}
+
+// IsTransmitting tells whether the rig is, or may still be, transmitting.
+func (ss *SerialStream) IsTransmitting() bool {
+	return ss.link.IsTransmitting()
+}
+
+// LinkState tells where the link to the rig stands.
+func (ss *SerialStream) LinkState() LinkState {
+	return ss.link.State()
+}
+
+// PortName is the serial port of the rig, it may change when the rig comes
+// back under another name.
+func (ss *SerialStream) PortName() string {
+	return ss.portName.Load().(string)
+}
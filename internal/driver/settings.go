@@ -0,0 +1,239 @@
+package driver
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RigSettings maps the names of extCommands to their values.
+type RigSettings map[string]int
+
+func (ss *SerialStream) ReadSettings() (RigSettings, error) {
+	settings := make(RigSettings)
+	for _, ext := range extCommands {
+		if ext.readOnly {
+			continue
+		}
+
+		value, err := ss.GetExtended(ext.name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ext.name, err)
+		}
+		settings[ext.name] = value
+	}
+
+	return settings, nil
+}
+
+func (ss *SerialStream) WriteSettings(settings RigSettings) error {
+	for _, ext := range extCommands {
+		value, ok := settings[ext.name]
+		if !ok || ext.readOnly {
+			continue
+		}
+
+		if err := ss.SetExtended(ext.name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (settings RigSettings) Write(w io.Writer) error {
+	for _, ext := range extCommands {
+		if value, ok := settings[ext.name]; ok {
+			if _, err := fmt.Fprintf(w, "%s %d\n", ext.name, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func ParseSettings(r io.Reader) (RigSettings, error) {
+	settings := make(RigSettings)
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a name and a value", lineNo)
+		}
+		if _, ok := findExtCommand(fields[0]); !ok {
+			return nil, fmt.Errorf("line %d: unknown setting %q", lineNo, fields[0])
+		}
+		value, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid value %q", lineNo, fields[1])
+		}
+		settings[fields[0]] = value
+	}
+
+	return settings, scanner.Err()
+}
+
+// Diff lists the settings which have different values in other.
+func (settings RigSettings) Diff(other RigSettings) []string {
+	var diff []string
+	for _, ext := range extCommands {
+		value, ok := settings[ext.name]
+		otherValue, otherOk := other[ext.name]
+		if ok && otherOk && value != otherValue {
+			diff = append(diff, fmt.Sprintf("%s: %d -> %d", ext.name, value, otherValue))
+		}
+	}
+
+	return diff
+}
+
+// settings                         dumps all settings
+// settings set <name>=<value> ...  changes them
+func registerSettingsCommands(cs *ControlServer, ss *SerialStream) {
+	cs.Handle("settings", func(args []string) (string, error) {
+		if len(args) == 0 {
+			settings, err := ss.ReadSettings()
+			if err != nil {
+				return "", err
+			}
+			var output strings.Builder
+			settings.Write(&output)
+			return output.String(), nil
+		}
+
+		if args[0] != "set" {
+			return "", errUsage
+		}
+
+		settings := make(RigSettings)
+		for _, arg := range args[1:] {
+			name, valueText, _ := strings.Cut(arg, "=")
+			value, err := strconv.Atoi(valueText)
+			if err != nil {
+				return "", fmt.Errorf("invalid value of %s", name)
+			}
+			settings[name] = value
+		}
+
+		return "", ss.WriteSettings(settings)
+	})
+}
+
+func fetchSettings() (RigSettings, error) {
+	output, err := controlRequest([]string{"settings"})
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSettings(strings.NewReader(output))
+}
+
+func loadSettingsFile(path string) (RigSettings, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseSettings(file)
+}
+
+// runSettingsCommand implements the settings CLI on top of the control
+// socket, the files are read and written on the client side.
+func runSettingsCommand(args []string) error {
+	if len(args) == 0 {
+		return runSettingsExport(nil)
+	}
+
+	switch args[0] {
+	case "export":
+		return runSettingsExport(args[1:])
+	case "import":
+		if len(args) != 2 {
+			return errUsage
+		}
+		return runSettingsImport(args[1])
+	case "diff":
+		if len(args) != 2 {
+			return errUsage
+		}
+		return runSettingsDiff(args[1])
+	}
+
+	return errUsage
+}
+
+func runSettingsExport(args []string) error {
+	settings, err := fetchSettings()
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if len(args) > 0 {
+		out, err = os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	fmt.Fprintf(out, "# trusdx-go settings, exported %s\n", time.Now().Format(time.RFC3339))
+
+	return settings.Write(out)
+}
+
+func runSettingsImport(path string) error {
+	settings, err := loadSettingsFile(path)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"settings", "set"}
+	for name, value := range settings {
+		args = append(args, fmt.Sprintf("%s=%d", name, value))
+	}
+	if _, err := controlRequest(args); err != nil {
+		return err
+	}
+
+	// the firmware may have refused some values, e.g. after an update
+	current, err := fetchSettings()
+	if err != nil {
+		return err
+	}
+	for _, line := range settings.Diff(current) {
+		fmt.Fprintf(os.Stderr, "not applied, %s\n", line)
+	}
+
+	return nil
+}
+
+func runSettingsDiff(path string) error {
+	saved, err := loadSettingsFile(path)
+	if err != nil {
+		return err
+	}
+
+	current, err := fetchSettings()
+	if err != nil {
+		return err
+	}
+
+	for _, line := range saved.Diff(current) {
+		fmt.Println(line)
+	}
+
+	return nil
+}
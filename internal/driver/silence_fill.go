@@ -0,0 +1,192 @@
+package driver
+
+import (
+	"math"
+	"math/rand"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// flat 128, clicks where the audio stops
+	fillSilence = "silence"
+	// the last audio once more, then fading out
+	fillRepeat = "repeat"
+	// from the last sample down to 128
+	fillFade = "fade"
+	// a faint hiss, decoders and listeners don't notice the gap as much
+	fillNoise = "noise"
+	// the last pitch period of the audio repeated, fading out
+	fillConceal = "conceal"
+)
+
+// the concealment, in samples at the RX rate
+const (
+	concealMinPeriod = 20  // 2.5 ms
+	concealMaxPeriod = 160 // 20 ms
+	concealHistory   = 2 * concealMaxPeriod
+	concealHold      = 80  // 10 ms at full level
+	concealFade      = 400 // then 50 ms down to silence
+	concealMerge     = 32  // crossfaded into the audio once it's back
+)
+
+// SilenceFill fills in for the audio missing from the rig.
+type SilenceFill struct {
+	strategy   string
+	last       []byte
+	lastSample byte
+	isRepeated bool
+	rand       *rand.Rand
+	// the concealment
+	history   []byte
+	period    []byte
+	phase     int
+	concealed int
+}
+
+func newSilenceFillFromEnv() *SilenceFill {
+	strategy, ok := os.LookupEnv("RX_FILL")
+	if !ok {
+		strategy = fillSilence
+	}
+
+	switch strategy {
+	case fillSilence, fillRepeat, fillFade, fillNoise, fillConceal:
+	default:
+		log.Warnf("Invalid RX_FILL value %q, using %s\n", strategy, fillSilence)
+		strategy = fillSilence
+	}
+
+	return &SilenceFill{strategy: strategy, lastSample: 128, rand: rand.New(rand.NewSource(1))}
+}
+
+// Played tells what went out last, the fill follows on from it.
+func (sf *SilenceFill) Played(samples []byte) {
+	if len(samples) == 0 {
+		return
+	}
+
+	if sf.strategy == fillConceal {
+		sf.merge(samples)
+	}
+
+	sf.last = append(sf.last[:0], samples...)
+	sf.lastSample = samples[len(samples)-1]
+	sf.isRepeated = false
+}
+
+func (sf *SilenceFill) Fill(samples []byte) {
+	switch sf.strategy {
+	case fillRepeat:
+		if !sf.isRepeated && len(sf.last) > 0 {
+			for i := range samples {
+				samples[i] = sf.last[i%len(sf.last)]
+			}
+			sf.isRepeated = true
+			sf.lastSample = samples[len(samples)-1]
+			return
+		}
+		sf.fade(samples)
+	case fillFade:
+		sf.fade(samples)
+	case fillConceal:
+		sf.conceal(samples)
+	case fillNoise:
+		for i := range samples {
+			samples[i] = byte(128 + sf.rand.Intn(3) - 1)
+		}
+	default:
+		for i := range samples {
+			samples[i] = 128
+		}
+	}
+}
+
+// fade decays from the last sample to 128 within a few milliseconds.
+func (sf *SilenceFill) fade(samples []byte) {
+	level := float64(sf.lastSample) - 128
+	for i := range samples {
+		level *= 0.9
+		samples[i] = byte(128 + level)
+	}
+	sf.lastSample = byte(128 + level)
+}
+
+// conceal repeats the last pitch period of the audio, so tones and voices
+// carry on through a short gap instead of breaking off. After concealHold
+// it fades out, a long gap is silence.
+func (sf *SilenceFill) conceal(samples []byte) {
+	if sf.concealed == 0 {
+		sf.period = pitchPeriod(sf.history)
+		sf.phase = 0
+	}
+
+	for i := range samples {
+		samples[i] = sf.next()
+	}
+}
+
+// next is the following sample of the concealment.
+func (sf *SilenceFill) next() byte {
+	gain := 1.0
+	if sf.concealed > concealHold {
+		gain = 1 - float64(sf.concealed-concealHold)/concealFade
+	}
+	sf.concealed++
+	if gain <= 0 || len(sf.period) == 0 {
+		return 128
+	}
+
+	sample := sf.period[sf.phase]
+	sf.phase = (sf.phase + 1) % len(sf.period)
+
+	return byte(128 + gain*(float64(sample)-128) + 0.5)
+}
+
+// merge crossfades from the concealment into the audio which is back, and
+// keeps the history the pitch is taken from.
+func (sf *SilenceFill) merge(samples []byte) {
+	if sf.concealed > 0 {
+		for i := 0; i < len(samples) && i < concealMerge; i++ {
+			weight := float64(i+1) / (concealMerge + 1)
+			samples[i] = byte(weight*float64(samples[i]) + (1-weight)*float64(sf.next()) + 0.5)
+		}
+		sf.concealed = 0
+	}
+
+	sf.history = append(sf.history, samples...)
+	if len(sf.history) > concealHistory {
+		sf.history = append(sf.history[:0], sf.history[len(sf.history)-concealHistory:]...)
+	}
+}
+
+// pitchPeriod finds the lag at which the end of the audio resembles itself
+// the most, and returns the last period of that length.
+func pitchPeriod(history []byte) []byte {
+	if len(history) < 2*concealMinPeriod {
+		return nil
+	}
+
+	best, bestScore := 0, 0.0
+	for lag := concealMinPeriod; lag <= concealMaxPeriod && 2*lag <= len(history); lag++ {
+		var sum, energy1, energy2 float64
+		for i := len(history) - lag; i < len(history); i++ {
+			a, b := float64(history[i])-128, float64(history[i-lag])-128
+			sum += a * b
+			energy1 += a * a
+			energy2 += b * b
+		}
+		if energy1 == 0 || energy2 == 0 {
+			continue
+		}
+		if score := sum / math.Sqrt(energy1*energy2); score > bestScore {
+			best, bestScore = lag, score
+		}
+	}
+	if best == 0 {
+		return nil
+	}
+
+	return append([]byte(nil), history[len(history)-best:]...)
+}
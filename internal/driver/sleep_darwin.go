@@ -0,0 +1,24 @@
+package driver
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// preventSleep keeps macOS from putting the driver to sleep (App Nap) and
+// from idle sleep while it's running. caffeinate exits together with it.
+func preventSleep() {
+	if os.Getenv("PREVENT_SLEEP") == "0" {
+		return
+	}
+
+	caffeinate := exec.Command("caffeinate", "-i", "-s", "-w", strconv.Itoa(os.Getpid()))
+	if err := caffeinate.Start(); err != nil {
+		log.Warnf("Can't prevent sleep: %s\n", err)
+		return
+	}
+	go caffeinate.Wait()
+}
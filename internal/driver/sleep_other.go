@@ -0,0 +1,5 @@
+//go:build !darwin
+
+package driver
+
+func preventSleep() {}
@@ -0,0 +1,104 @@
+package driver
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type catDecoder struct {
+	direction  string
+	pending    []byte
+	streaming  bool
+	audioBytes int
+}
+
+func (d *catDecoder) Feed(data []byte) {
+	d.pending = append(d.pending, data...)
+
+	for {
+		if d.streaming {
+			end := bytes.IndexByte(d.pending, ';')
+			if end < 0 {
+				d.audioBytes += len(d.pending)
+				d.pending = d.pending[:0]
+				return
+			}
+			d.audioBytes += end
+			log.Infof("[%s]: US (%d bytes of audio)\n", d.direction, d.audioBytes)
+			d.pending = d.pending[end+1:]
+			d.streaming = false
+			continue
+		}
+
+		if bytes.HasPrefix(d.pending, []byte("US")) {
+			d.pending = d.pending[2:]
+			d.streaming = true
+			d.audioBytes = 0
+			continue
+		}
+
+		end := bytes.IndexByte(d.pending, ';')
+		if end < 0 {
+			return
+		}
+		msg := string(d.pending[:end+1])
+		d.pending = d.pending[end+1:]
+
+		if description := describeCat(msg); description != "" {
+			log.Infof("[%s]: %s (%s)\n", d.direction, msg, description)
+		} else {
+			log.Infof("[%s]: %s\n", d.direction, msg)
+		}
+	}
+}
+
+func sniff(src io.Reader, dst io.Writer, direction string) {
+	decoder := &catDecoder{direction: direction}
+	buffer := make([]byte, 64)
+
+	for {
+		readCount, err := src.Read(buffer)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		dst.Write(buffer[:readCount])
+		decoder.Feed(buffer[:readCount])
+	}
+}
+
+// runSniffer sits between the serial port of the rig and a client of
+// another program, logging the traffic without touching it.
+func runSniffer(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	port, err := OpenSerialPort(args[0])
+	if err != nil {
+		return err
+	}
+	defer port.Close()
+
+	ptm, pts, err := openPty()
+	if err != nil {
+		return err
+	}
+	defer ptm.Close()
+	defer pts.Close()
+	configurePort(pts)
+	log.Printf("CAT serial port: %s\n", pts.Name())
+
+	go sniff(ptm, port, "CAT -> Rig")
+	go sniff(port, ptm, "CAT <- Rig")
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+
+	return nil
+}
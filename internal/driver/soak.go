@@ -0,0 +1,260 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	faultCorruption = "corruption"
+	faultStall      = "stall"
+	faultDisconnect = "disconnect"
+)
+
+var (
+	soakFaultKinds = []string{faultCorruption, faultStall, faultDisconnect}
+
+	errSoakDisconnect = errors.New("disconnected by the soak test")
+)
+
+// soakFaults breaks the simulated rig on request.
+type soakFaults struct {
+	mu         sync.Mutex
+	sim        *RigSimulator
+	opened     int // ports opened so far
+	corrupt    atomic.Int32
+	disconnect atomic.Bool
+	random     *rand.Rand
+}
+
+// soakPort is the simulated rig with the faults injected into what it
+// sends.
+type soakPort struct {
+	*RigSimulator
+	faults *soakFaults
+}
+
+func (sp *soakPort) Read(p []byte) (int, error) {
+	n, err := sp.RigSimulator.Read(p)
+	if sp.faults.disconnect.CompareAndSwap(true, false) {
+		return 0, errSoakDisconnect
+	}
+	if n > 0 && sp.faults.corrupt.Load() > 0 {
+		sp.faults.corrupt.Add(-1)
+		sp.faults.mu.Lock()
+		p[sp.faults.random.Intn(n)] = byte(sp.faults.random.Intn(256))
+		sp.faults.mu.Unlock()
+	}
+
+	return n, err
+}
+
+func (sf *soakFaults) open(name string) (*SerialPort, error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	sf.sim = newStreamingRigSimulator()
+	sf.opened++
+
+	return &SerialPort{&soakPort{sf.sim, sf}}, nil
+}
+
+// inject starts a fault and returns a function telling whether it is over,
+// whether the driver has recovered or not.
+func (sf *soakFaults) inject(kind string) func() bool {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	switch kind {
+	case faultCorruption:
+		sf.corrupt.Store(16)
+		return func() bool { return sf.corrupt.Load() <= 0 }
+	case faultStall:
+		sim := sf.sim
+		sim.stall()
+		return func() bool {
+			sim.mu.Lock()
+			defer sim.mu.Unlock()
+			return sim.isStreaming
+		}
+	default:
+		opened := sf.opened
+		sf.disconnect.Store(true)
+		return func() bool {
+			sf.mu.Lock()
+			defer sf.mu.Unlock()
+			return sf.opened > opened
+		}
+	}
+}
+
+// soakFault is one injected fault, recovered once the audio comes back
+// after it is over.
+type soakFault struct {
+	kind     string
+	at       time.Time
+	isOver   func() bool
+	overSeen bool
+}
+
+// runSoak runs the driver against the simulator for a long time, breaking
+// it every now and then, and reports how it recovered and whether it leaks.
+func runSoak(args []string) error {
+	options := map[string]string{"duration": "1h", "faults": "20s", "report": "1m", "seed": "0"}
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if _, known := options[name]; !ok || !known {
+			return errUsage
+		}
+		options[name] = value
+	}
+	var durations [3]time.Duration
+	for i, name := range []string{"duration", "faults", "report"} {
+		value, err := time.ParseDuration(options[name])
+		if err != nil || value <= 0 {
+			return fmt.Errorf("invalid %s %q", name, options[name])
+		}
+		durations[i] = value
+	}
+	duration, faultInterval, reportInterval := durations[0], durations[1], durations[2]
+	seed, err := strconv.ParseInt(options["seed"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid seed %q", options["seed"])
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	random := rand.New(rand.NewSource(seed))
+	faults := &soakFaults{random: rand.New(rand.NewSource(seed + 1))}
+	ss := newSerialStream("simulator", faults.open)
+	// never key real outputs for the simulator
+	ss.pttOutputs.Close()
+	ss.pttOutputs = nil
+	ss.Start()
+	defer ss.Close()
+	ss.PushCommand(";UA2;RX;")
+
+	// transmit a bit every now and then, through the whole pipeline
+	stop := make(chan struct{})
+	defer close(stop)
+	var txCycles atomic.Uint64
+	go func() {
+		tone := generateTone(float64(ss.TxRate()), 0.5, ss.TxRate(), 1000)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Second):
+			}
+			ss.PushCommand("TX;")
+			ticker := time.NewTicker(time.Second * dataChunkLength / time.Duration(ss.TxRate()))
+			for i := 0; i+dataChunkLength <= len(tone); i += dataChunkLength {
+				<-ticker.C
+				select {
+				case ss.AudioInBuf <- tone[i : i+dataChunkLength]:
+				default:
+				}
+			}
+			ticker.Stop()
+			ss.PushCommand("RX;")
+			txCycles.Add(1)
+		}
+	}()
+
+	// the driver settles before the baseline of the leaks
+	settled := time.After(2 * time.Second)
+settle:
+	for {
+		select {
+		case <-ss.AudioOutBuf:
+		case <-settled:
+			break settle
+		}
+	}
+	runtime.GC()
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	startGoroutines, startHeap := runtime.NumGoroutine(), memory.HeapAlloc
+
+	injected := make(map[string]int)
+	recoveries := make(map[string]*benchTimes)
+	for _, kind := range soakFaultKinds {
+		recoveries[kind] = new(benchTimes)
+	}
+	var unrecovered int
+	var fault *soakFault
+	var received uint64
+
+	log.Printf("Soak test for %s, a fault every %s, seed %d\n", duration, faultInterval, seed)
+	start := time.Now()
+	end := time.After(duration)
+	faultTicker := time.NewTicker(faultInterval)
+	defer faultTicker.Stop()
+	reportTicker := time.NewTicker(reportInterval)
+	defer reportTicker.Stop()
+soak:
+	for {
+		select {
+		case samples := <-ss.AudioOutBuf:
+			received += uint64(len(samples))
+			if fault != nil && fault.overSeen {
+				recoveries[fault.kind].add(time.Since(fault.at))
+				fault = nil
+			} else if fault != nil && fault.isOver() {
+				// the audio after this one counts
+				fault.overSeen = true
+			}
+		case <-faultTicker.C:
+			if fault != nil {
+				log.Warnf("No recovery from the %s after %s\n", fault.kind, faultInterval)
+				unrecovered++
+			}
+			kind := soakFaultKinds[random.Intn(len(soakFaultKinds))]
+			log.Infof("Injecting a %s\n", kind)
+			fault = &soakFault{kind: kind, at: time.Now(), isOver: faults.inject(kind)}
+			injected[kind]++
+		case <-reportTicker.C:
+			log.Infof("Soak %s: %s\n", time.Since(start).Round(time.Second), ss.Stats.String())
+		case <-end:
+			break soak
+		}
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&memory)
+	endGoroutines, endHeap := runtime.NumGoroutine(), memory.HeapAlloc
+	elapsed := time.Since(start)
+
+	fmt.Printf("Duration:    %s, seed %d\n", elapsed.Round(time.Second), seed)
+	fmt.Printf("Audio:       %d samples received, %d TX cycles\n", received, txCycles.Load())
+	for _, kind := range soakFaultKinds {
+		fmt.Printf("%-12s %d injected, recovery %s\n", kind+":", injected[kind], recoveries[kind])
+	}
+	fmt.Printf("Unrecovered: %d\n", unrecovered)
+	fmt.Printf("Drops:       %d RX chunks, %d TX chunks, %d RX underruns\n",
+		ss.Stats.RxOverruns.Load(), ss.Stats.TxOverruns.Load(), ss.Stats.RxUnderruns.Load())
+	fmt.Printf("Recovery:    %d reconnects, %d resyncs, %d discarded bytes, %d RX stalls\n",
+		ss.Stats.Reconnects.Load(), ss.Stats.Resyncs.Load(), ss.Stats.DiscardedBytes.Load(), ss.Stats.RxStalls.Load())
+	fmt.Printf("Goroutines:  %d at the start, %d at the end\n", startGoroutines, endGoroutines)
+	fmt.Printf("Heap:        %d KiB at the start, %d KiB at the end\n", startHeap/1024, endHeap/1024)
+
+	// a fault in progress may hold a few goroutines
+	isLeaking := endGoroutines > startGoroutines+5 || endHeap > 2*startHeap+1<<20
+	if unrecovered > 0 || isLeaking {
+		fmt.Println("FAIL")
+		return errors.New("soak test failed")
+	}
+	fmt.Println("PASS")
+
+	return nil
+}
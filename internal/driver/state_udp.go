@@ -0,0 +1,132 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// openUdpSender opens a UDP socket allowed to send to broadcast addresses,
+// where displays and loggers usually listen.
+func openUdpSender() (net.PacketConn, error) {
+	config := net.ListenConfig{Control: func(network, address string, c syscall.RawConn) error {
+		var err error
+		c.Control(func(fd uintptr) {
+			err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_BROADCAST, 1)
+		})
+		return err
+	}}
+
+	return config.ListenPacket(context.Background(), "udp", ":0")
+}
+
+// resolveUdpAddresses resolves a comma separated list of addresses.
+func resolveUdpAddresses(spec string) ([]*net.UDPAddr, error) {
+	var addresses []*net.UDPAddr
+	for _, address := range strings.Split(spec, ",") {
+		resolved, err := net.ResolveUDPAddr("udp", strings.TrimSpace(address))
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, resolved)
+	}
+
+	return addresses, nil
+}
+
+// udpState is the datagram, compact for small displays.
+type udpState struct {
+	Frequency int64  `json:"freq"`
+	Mode      string `json:"mode"`
+	Band      string `json:"band"`
+	Tx        bool   `json:"tx"`
+	Connected bool   `json:"connected"`
+	Time      int64  `json:"time"`
+}
+
+// StateUdp sends the state of the rig as JSON to the addresses in
+// STATE_UDP, for dashboards and shack displays.
+type StateUdp struct {
+	ss      *SerialStream
+	sender  net.PacketConn
+	targets []*net.UDPAddr
+	stop    chan struct{}
+}
+
+// startStateUdpFromEnv returns nil when STATE_UDP isn't set.
+func startStateUdpFromEnv(ss *SerialStream) *StateUdp {
+	spec := strings.TrimSpace(os.Getenv("STATE_UDP"))
+	if spec == "" {
+		return nil
+	}
+
+	targets, err := resolveUdpAddresses(spec)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	sender, err := openUdpSender()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	su := &StateUdp{ss: ss, sender: sender, targets: targets, stop: make(chan struct{})}
+	go su.run(envDuration("STATE_UDP_INTERVAL", time.Second))
+
+	return su
+}
+
+func (su *StateUdp) run(interval time.Duration) {
+	if interval <= 0 {
+		log.Warnf("Invalid STATE_UDP_INTERVAL value %s, using 1s\n", interval)
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-su.stop:
+			return
+		case <-ticker.C:
+		}
+		su.send()
+	}
+}
+
+func (su *StateUdp) send() {
+	frequency := su.ss.State.Frequency()
+	band, _ := findBand(frequency)
+	data, err := json.Marshal(udpState{
+		Frequency: frequency,
+		Mode:      modeNames[su.ss.State.Mode()],
+		Band:      band.name,
+		Tx:        su.ss.State.Transmitting(),
+		Connected: su.ss.IsRunning(),
+		Time:      time.Now().Unix(),
+	})
+	if err != nil {
+		log.Warnf("State UDP: %s\n", err)
+		return
+	}
+
+	for _, target := range su.targets {
+		if _, err := su.sender.WriteTo(data, target); err != nil {
+			log.Warnf("State UDP %s: %s\n", target, err)
+		}
+	}
+}
+
+func (su *StateUdp) Close() {
+	if su == nil {
+		return
+	}
+
+	close(su.stop)
+	su.sender.Close()
+}
@@ -0,0 +1,91 @@
+package driver
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Stats struct {
+	RxChunks       atomic.Uint64
+	TxChunks       atomic.Uint64
+	CatCommands    atomic.Uint64
+	CatReplies     atomic.Uint64
+	Reconnects     atomic.Uint64
+	Resyncs        atomic.Uint64
+	DiscardedBytes atomic.Uint64
+	RxUnderruns    atomic.Uint64 // the soundcard got silence, no audio from the rig in time
+	RxOverruns     atomic.Uint64 // audio from the rig dropped, the soundcard doesn't keep up
+	TxOverruns     atomic.Uint64 // audio for the rig dropped, the serial port doesn't keep up
+	RxStalls       atomic.Uint64 // the rig stopped streaming and was restarted
+	CatRetries     atomic.Uint64 // queries sent again, the rig didn't answer in time
+	CatTimeouts    atomic.Uint64 // queries the rig didn't answer at all
+}
+
+// count keeps the counters of the events.
+func (st *Stats) count(event Event) {
+	switch e := event.(type) {
+	case ChunkDropped:
+		if e.Direction == "rx" {
+			st.RxOverruns.Add(1)
+		} else {
+			st.TxOverruns.Add(1)
+		}
+	case AudioStalled:
+		st.RxStalls.Add(1)
+	case ConnectionChanged:
+		if e.Connected {
+			st.Reconnects.Add(1)
+		}
+	}
+}
+
+func (st *Stats) String() string {
+	return fmt.Sprintf(
+		"rx_chunks=%d tx_chunks=%d cat_commands=%d cat_replies=%d reconnects=%d "+
+			"resyncs=%d discarded_bytes=%d rx_underruns=%d rx_overruns=%d tx_overruns=%d rx_stalls=%d "+
+			"cat_retries=%d cat_timeouts=%d",
+		st.RxChunks.Load(), st.TxChunks.Load(), st.CatCommands.Load(), st.CatReplies.Load(), st.Reconnects.Load(),
+		st.Resyncs.Load(), st.DiscardedBytes.Load(),
+		st.RxUnderruns.Load(), st.RxOverruns.Load(), st.TxOverruns.Load(), st.RxStalls.Load(),
+		st.CatRetries.Load(), st.CatTimeouts.Load(),
+	)
+}
+
+func (ss *SerialStream) queueDepths() string {
+	depths := fmt.Sprintf(
+		"queue_audio_out=%d queue_audio_in=%d queue_replies=%d queue_cmds=%d",
+		len(ss.AudioOutBuf), len(ss.AudioInBuf), len(ss.RepliesBuf), len(ss.CmdsBuf),
+	)
+	for _, tee := range []*AudioTee{ss.RxAudio, ss.TxAudio} {
+		if sinks := tee.String(); sinks != "" {
+			depths += " " + sinks
+		}
+	}
+
+	return depths
+}
+
+func reportStats(ss *SerialStream, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	for isRunning() {
+		time.Sleep(interval)
+		log.Infof("Stats: %s %s\n", ss.Stats.String(), ss.queueDepths())
+	}
+}
+
+func registerStatsCommands(cs *ControlServer, ss *SerialStream) {
+	cs.Handle("stats", func(args []string) (string, error) {
+		if len(args) == 1 && args[0] == "cat" {
+			return ss.CatStats.String() + "\n", nil
+		} else if len(args) > 0 {
+			return "", errUsage
+		}
+		return ss.Stats.String() + " " + ss.queueDepths() + "\n", nil
+	})
+}
@@ -0,0 +1,61 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// registerStatusCommands adds status, a summary of the running driver, and
+// stop, which shuts it down as Ctrl-C does.
+func registerStatusCommands(cs *ControlServer, ss *SerialStream, catPort string, sig chan<- os.Signal) {
+	started := time.Now()
+
+	cs.Handle("status", func(args []string) (string, error) {
+		if len(args) > 0 {
+			return "", errUsage
+		}
+
+		var sb strings.Builder
+		uptime := time.Since(started).Round(time.Second)
+		fmt.Fprintf(&sb, "Driver: running for %s, pid %d, %s mode\n", uptime, os.Getpid(), driverMode())
+		connection := "connected"
+		if !ss.IsRunning() {
+			connection = "waiting"
+		}
+		fmt.Fprintf(&sb, "Rig:    %s, %s, link %s\n", ss.PortName(), connection, ss.link.State())
+		mode, ok := modeNames[ss.State.Mode()]
+		if !ok {
+			mode = "mode unknown"
+		}
+		direction := "receiving"
+		if ss.State.Transmitting() {
+			direction = "transmitting"
+		}
+		fmt.Fprintf(&sb, "VFO:    %d Hz, %s, %s\n", ss.State.Frequency(), mode, direction)
+		fmt.Fprintf(&sb, "CAT:    %s\n", catPort)
+		if ss.withAudio {
+			fmt.Fprintf(&sb, "Audio:  RX %d Hz, TX %d Hz\n", ss.RxRate(), ss.TxRate())
+		} else {
+			sb.WriteString("Audio:  off\n")
+		}
+
+		return sb.String(), nil
+	})
+
+	cs.Handle("stop", func(args []string) (string, error) {
+		if len(args) > 0 {
+			return "", errUsage
+		}
+
+		select {
+		case sig <- syscall.SIGTERM:
+		default:
+			// already stopping
+		}
+
+		return "Stopping\n", nil
+	})
+}
@@ -0,0 +1,86 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PowerSweep transmits a tone while stepping the drive level, and lists
+// the steps with the time they started into the sweep. The rig reports
+// neither its output power nor its supply voltage over CAT, they are read
+// off a meter for every step. The drive level is restored afterwards.
+func (ss *SerialStream) PowerSweep(from, to, step int, dwell time.Duration, frequency float64) (string, error) {
+	original, err := ss.GetExtended("drive")
+	if err != nil {
+		return "", err
+	}
+	defer ss.SetExtended("drive", original)
+
+	if err := ss.SetExtended("drive", from); err != nil {
+		return "", err
+	}
+	steps := (to-from)/step + 1
+	if err := ss.TransmitTone(0.5, time.Duration(steps+1)*dwell, frequency); err != nil {
+		return "", err
+	}
+	defer ss.StopPlayback()
+
+	var table strings.Builder
+	fmt.Fprintf(&table, "%5s %6s\n", "drive", "at")
+	start := time.Now()
+	for drive := from; drive <= to; drive += step {
+		if err := ss.SetExtended("drive", drive); err != nil {
+			return "", err
+		}
+		at := time.Since(start).Round(time.Second)
+		log.Infof("Sweep: drive %d at %s\n", drive, at)
+		time.Sleep(dwell)
+		if !ss.isPlaying.Load() {
+			return table.String(), fmt.Errorf("stopped at drive %d", drive)
+		}
+		fmt.Fprintf(&table, "%5d %6s\n", drive, at)
+	}
+
+	return table.String(), nil
+}
+
+func registerSweepCommands(cs *ControlServer, ss *SerialStream) {
+	cs.Handle("sweep", func(args []string) (string, error) {
+		options := map[string]string{
+			"from": "10", "to": "100", "step": "10", "dwell": "2s", "tone": "1000",
+		}
+		for _, arg := range args {
+			name, value, ok := strings.Cut(arg, "=")
+			if _, known := options[name]; !ok || !known {
+				return "", errUsage
+			}
+			options[name] = value
+		}
+
+		var values [3]int
+		for i, name := range []string{"from", "to", "step"} {
+			value, err := strconv.Atoi(options[name])
+			if err != nil || value < 0 {
+				return "", fmt.Errorf("invalid %s %q", name, options[name])
+			}
+			values[i] = value
+		}
+		if values[2] == 0 || values[0] > values[1] {
+			return "", fmt.Errorf("invalid range %d-%d by %d", values[0], values[1], values[2])
+		}
+		dwell, err := time.ParseDuration(options["dwell"])
+		if err != nil {
+			return "", err
+		}
+		frequency, err := strconv.ParseFloat(options["tone"], 64)
+		if err != nil || frequency <= 0 || frequency >= 11520/2 {
+			return "", fmt.Errorf("invalid tone %q", options["tone"])
+		}
+
+		return ss.PowerSweep(values[0], values[1], values[2], dwell, frequency)
+	})
+}
@@ -0,0 +1,95 @@
+package driver
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// generateTone makes 8-bit unsigned samples of the sum of sine waves at the
+// given frequencies, the peak is at level relative to the full scale.
+func generateTone(sampleRate float64, level float64, count int, frequencies ...float64) []byte {
+	samples := make([]byte, count)
+	amplitude := 127 * level / float64(len(frequencies))
+
+	for i := range samples {
+		value := 0.0
+		for _, frequency := range frequencies {
+			value += math.Sin(2 * math.Pi * frequency * float64(i) / sampleRate)
+		}
+		samples[i] = byte(math.Round(128 + amplitude*value))
+	}
+
+	return samples
+}
+
+// rmsLevel is the RMS level of 8-bit unsigned samples in dBFS.
+func rmsLevel(samples []byte) float64 {
+	sum := 0.0
+	for _, sample := range samples {
+		value := (float64(sample) - 128) / 128
+		sum += value * value
+	}
+
+	return 20 * math.Log10(math.Sqrt(sum/float64(len(samples))))
+}
+
+// TransmitTone keys the rig and sends a test tone in place of the sound
+// card audio, until the duration passes or StopPlayback is called.
+func (ss *SerialStream) TransmitTone(level float64, duration time.Duration, frequencies ...float64) error {
+	// a second of whole-Hz tones loops without a click
+	rate := ss.TxRate()
+	period := generateTone(float64(rate), level, rate, frequencies...)
+	_, err := ss.Play(period, true, duration)
+
+	return err
+}
+
+func registerToneCommands(cs *ControlServer, ss *SerialStream) {
+	cs.Handle("tone", func(args []string) (string, error) {
+		if len(args) == 1 && args[0] == "stop" {
+			ss.StopPlayback()
+			return "", nil
+		}
+
+		level := 0.5
+		duration := 10 * time.Second
+		var frequencies []float64
+		for _, arg := range args {
+			name, valueText, isOption := strings.Cut(arg, "=")
+			var err error
+			switch {
+			case !isOption:
+				var frequency float64
+				frequency, err = strconv.ParseFloat(arg, 64)
+				if frequency <= 0 || frequency >= 11520/2 {
+					err = fmt.Errorf("invalid frequency %q", arg)
+				}
+				frequencies = append(frequencies, frequency)
+			case name == "level":
+				level, err = strconv.ParseFloat(valueText, 64)
+				if level <= 0 || level > 1 {
+					err = fmt.Errorf("invalid level %q, it's between 0 and 1", valueText)
+				}
+			case name == "duration":
+				duration, err = time.ParseDuration(valueText)
+			default:
+				err = errUsage
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+		if len(frequencies) == 0 || len(frequencies) > 2 {
+			return "", errUsage
+		}
+
+		if err := ss.TransmitTone(level, duration, frequencies...); err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("Transmitting for %s, stop with: tone stop\n", duration), nil
+	})
+}
@@ -0,0 +1,41 @@
+package driver
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// logTraffic records what goes over the serial port, in the capture file
+// and, at the trace log level, as a hex dump.
+func (ss *SerialStream) logTraffic(direction byte, kind byte, data []byte) {
+	ss.capture.Write(direction, kind, data)
+	if kind == pcapCat {
+		ss.catHistory.Add(direction == pcapFromRig, data)
+	}
+	if !log.IsLevelEnabled(log.TraceLevel) {
+		return
+	}
+
+	arrow := "->"
+	if direction == pcapFromRig {
+		arrow = "<-"
+	}
+	name := "CAT"
+	shown := data
+	if kind == pcapAudio {
+		name = "audio"
+		// the samples are rarely interesting
+		if ss.traceAudioBytes >= 0 && len(shown) > ss.traceAudioBytes {
+			shown = shown[:ss.traceAudioBytes]
+		}
+	}
+
+	dump := hex.Dump(shown)
+	if len(shown) < len(data) {
+		dump += fmt.Sprintf("... %d more bytes\n", len(data)-len(shown))
+	}
+	log.Tracef("[Serial %s Rig]: %s %s, %d bytes\n%s", arrow, time.Now().Format("15:04:05.000000"), name, len(data), dump)
+}
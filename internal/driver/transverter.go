@@ -0,0 +1,129 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FrequencyOffset shows CAT clients the frequency behind a transverter: the
+// rig is tuned to the client frequency minus the offset, and its replies are
+// shifted back. Transverters can have their own ranges of client
+// frequencies, the one last tuned to is used for the replies. A nil
+// FrequencyOffset passes everything through.
+type FrequencyOffset struct {
+	mu          sync.Mutex
+	base        int64
+	transverter []transverterRange
+	active      int64
+}
+
+type transverterRange struct {
+	low, high int64
+	offset    int64
+}
+
+// newFrequencyOffsetFromEnv reads FREQUENCY_OFFSET and TRANSVERTERS, it
+// returns nil when neither is set.
+func newFrequencyOffsetFromEnv() (*FrequencyOffset, error) {
+	base := os.Getenv("FREQUENCY_OFFSET")
+	ranges := strings.TrimSpace(os.Getenv("TRANSVERTERS"))
+	if base == "" && ranges == "" {
+		return nil, nil
+	}
+
+	fo := new(FrequencyOffset)
+	if base != "" {
+		offset, err := strconv.ParseInt(base, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FREQUENCY_OFFSET %q", base)
+		}
+		fo.base = offset
+	}
+	if ranges != "" {
+		// <low>-<high>:<offset>, in client frequencies
+		for _, spec := range strings.Split(ranges, ",") {
+			var r transverterRange
+			if _, err := fmt.Sscanf(strings.TrimSpace(spec), "%d-%d:%d", &r.low, &r.high, &r.offset); err != nil {
+				return nil, fmt.Errorf("invalid transverter %q, expected <low>-<high>:<offset>", spec)
+			}
+			fo.transverter = append(fo.transverter, r)
+		}
+	}
+	fo.active = fo.base
+
+	return fo, nil
+}
+
+// the largest frequency of FA and FB, 11 digits
+const maxCatFrequency = 99999999999
+
+// ToRig shifts the frequencies set by a client. A frequency the rig can't be
+// tuned to, e.g. below the offset, isn't forwarded, the client is answered
+// "?;" as the rig answers invalid commands.
+func (fo *FrequencyOffset) ToRig(cmdString string) (string, string) {
+	if fo == nil {
+		return cmdString, ""
+	}
+
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+
+	var replies string
+	cmds := strings.Split(cmdString, ";")
+	forwarded := cmds[:0]
+	for _, cmd := range cmds {
+		if !strings.HasPrefix(cmd, "FA") && !strings.HasPrefix(cmd, "FB") || len(cmd) != 13 {
+			forwarded = append(forwarded, cmd)
+			continue
+		}
+		frequency, err := strconv.ParseInt(cmd[2:], 10, 64)
+		if err != nil {
+			forwarded = append(forwarded, cmd)
+			continue
+		}
+
+		offset := fo.base
+		for _, r := range fo.transverter {
+			if frequency >= r.low && frequency <= r.high {
+				offset = r.offset
+				break
+			}
+		}
+		if frequency-offset <= 0 || frequency-offset > maxCatFrequency {
+			replies += "?;"
+			continue
+		}
+		fo.active = offset
+		forwarded = append(forwarded, fmt.Sprintf("%s%011d", cmd[:2], frequency-offset))
+	}
+
+	return strings.Join(forwarded, ";"), replies
+}
+
+// ToClient shifts the frequencies reported by the rig.
+func (fo *FrequencyOffset) ToClient(reply []byte) []byte {
+	if fo == nil {
+		return reply
+	}
+
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+
+	messages := strings.Split(string(reply), ";")
+	for i, message := range messages {
+		if !strings.HasPrefix(message, "FA") && !strings.HasPrefix(message, "FB") && !strings.HasPrefix(message, "IF") ||
+			len(message) < 13 {
+			continue
+		}
+		frequency, err := strconv.ParseInt(message[2:13], 10, 64)
+		if err != nil {
+			continue
+		}
+		messages[i] = fmt.Sprintf("%s%011d%s", message[:2], frequency+fo.active, message[13:])
+	}
+
+	return []byte(strings.Join(messages, ";"))
+}
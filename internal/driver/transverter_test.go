@@ -0,0 +1,104 @@
+package driver
+
+import "testing"
+
+func TestFrequencyOffsetToRig(t *testing.T) {
+	tests := []struct {
+		name        string
+		offset      *FrequencyOffset
+		cmd         string
+		want        string
+		wantReplies string
+		wantActive  int64
+	}{
+		{
+			name:   "nil",
+			offset: nil,
+			cmd:    "FA00144174000;",
+			want:   "FA00144174000;",
+		},
+		{
+			name:       "base offset",
+			offset:     &FrequencyOffset{base: 116000000},
+			cmd:        "FA00144174000;",
+			want:       "FA00028174000;",
+			wantActive: 116000000,
+		},
+		{
+			name:       "other commands untouched",
+			offset:     &FrequencyOffset{base: 116000000},
+			cmd:        "MD2;FA;IF;",
+			want:       "MD2;FA;IF;",
+			wantActive: 0,
+		},
+		{
+			name: "transverter range",
+			offset: &FrequencyOffset{transverter: []transverterRange{
+				{low: 144000000, high: 146000000, offset: 116000000},
+				{low: 432000000, high: 438000000, offset: 404000000},
+			}},
+			cmd:        "FB00432174000;",
+			want:       "FB00028174000;",
+			wantActive: 404000000,
+		},
+		{
+			name:        "below the offset",
+			offset:      &FrequencyOffset{base: 116000000},
+			cmd:         "FA00007074000;MD2;",
+			want:        "MD2;",
+			wantReplies: "?;",
+			wantActive:  0,
+		},
+		{
+			name:        "at the offset",
+			offset:      &FrequencyOffset{base: 116000000},
+			cmd:         "FA00116000000;",
+			want:        "",
+			wantReplies: "?;",
+			wantActive:  0,
+		},
+		{
+			name:        "beyond the CAT range",
+			offset:      &FrequencyOffset{base: -1},
+			cmd:         "FA99999999999;",
+			want:        "",
+			wantReplies: "?;",
+			wantActive:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, replies := tt.offset.ToRig(tt.cmd)
+			if got != tt.want || replies != tt.wantReplies {
+				t.Errorf("ToRig(%q) = %q, %q, want %q, %q", tt.cmd, got, replies, tt.want, tt.wantReplies)
+			}
+			if tt.offset != nil && tt.offset.active != tt.wantActive {
+				t.Errorf("active offset %d, want %d", tt.offset.active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestFrequencyOffsetToClient(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset *FrequencyOffset
+		reply  string
+		want   string
+	}{
+		{name: "nil", offset: nil, reply: "FA00028174000;", want: "FA00028174000;"},
+		{name: "FA", offset: &FrequencyOffset{active: 116000000}, reply: "FA00028174000;", want: "FA00144174000;"},
+		{name: "IF", offset: &FrequencyOffset{active: 116000000}, reply: "IF00028174000     +00000000002000000;", want: "IF00144174000     +00000000002000000;"},
+		{name: "several", offset: &FrequencyOffset{active: 1000}, reply: "MD2;FB00007074000;", want: "MD2;FB00007075000;"},
+		{name: "query", offset: &FrequencyOffset{active: 1000}, reply: "FA;", want: "FA;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(tt.offset.ToClient([]byte(tt.reply))); got != tt.want {
+				t.Errorf("ToClient(%q) = %q, want %q", tt.reply, got, tt.want)
+			}
+		})
+	}
+}
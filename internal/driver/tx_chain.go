@@ -0,0 +1,88 @@
+package driver
+
+import (
+	"math"
+	"sync"
+)
+
+// TxChain processes the 16-bit audio from the sound card on its way down to
+// the 8 bits of the rig, as floats from -1 to 1.
+type TxChain struct {
+	mu        sync.Mutex // the filters change with the mode
+	highPass  *Biquad
+	limiter   *Limiter
+	quantizer *Quantizer
+	// the filters may be switched on later, by a mode profile
+	isAdjustable bool
+}
+
+func newTxChainFromEnv() *TxChain {
+	return &TxChain{
+		highPass:  newHighPassFromEnv(),
+		limiter:   newLimiterFromEnv(),
+		quantizer: newQuantizerFromEnv(),
+	}
+}
+
+// IsBypassed tells when the top 8 bits of the sound card will do.
+func (tc *TxChain) IsBypassed() bool {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	return !tc.isAdjustable && tc.highPass == nil && tc.limiter == nil && tc.quantizer.mode == ditherOff
+}
+
+// SetHighPass changes the cutoff of the high-pass filter in Hz, 0 turns it
+// off.
+func (tc *TxChain) SetHighPass(cutoff int) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.highPass = newTxHighPass(cutoff)
+}
+
+// SetLimiter changes the threshold of the limiter in dBFS, 0 turns it off.
+func (tc *TxChain) SetLimiter(threshold int) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.limiter = newTxLimiter(threshold)
+}
+
+func (tc *TxChain) Process(samples []int16) []byte {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	processed := make([]byte, len(samples))
+	for i, sample := range samples {
+		processed[i] = tc.processSample(float64(sample) / 32768)
+	}
+
+	return processed
+}
+
+// ProcessFloat32 takes the samples of float pipelines, from -1 to 1.
+func (tc *TxChain) ProcessFloat32(samples []float32) []byte {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	processed := make([]byte, len(samples))
+	for i, sample := range samples {
+		// the top of the 16-bit range, beyond it the 8 bits wrap around
+		value := math.Max(-1, math.Min(float64(sample), 32767.0/32768))
+		processed[i] = tc.processSample(value)
+	}
+
+	return processed
+}
+
+func (tc *TxChain) processSample(value float64) byte {
+	if tc.highPass != nil {
+		value = tc.highPass.Process(value)
+	}
+	if tc.limiter != nil {
+		value = tc.limiter.Process(value)
+	}
+
+	return tc.quantizer.quantize(value)
+}
@@ -0,0 +1,36 @@
+package driver
+
+// TxKind is what the rig transmits after a TX command.
+type TxKind int32
+
+const (
+	TxAudio TxKind = iota // the audio streamed by the driver
+	TxKey                 // CW key-down, the carrier without audio
+	TxTune                // the tune carrier
+)
+
+var txKindNames = [...]string{"audio", "key-down", "tune"}
+
+func (k TxKind) String() string {
+	if k < 0 || int(k) >= len(txKindNames) {
+		return "unknown"
+	}
+	return txKindNames[k]
+}
+
+// txKindOf tells what the TX command transmits in the mode. TX and TX0/TX1
+// send the audio, except in CW where they key the carrier, TX2 tunes.
+func txKindOf(cmd string, mode byte) TxKind {
+	switch {
+	case cmd == "TX2":
+		return TxTune
+	case mode == '3' || mode == '7':
+		return TxKey
+	}
+	return TxAudio
+}
+
+// isTxAudio tells whether the rig expects the TX audio on the serial port.
+func (ss *SerialStream) isTxAudio() bool {
+	return ss.link.Is(StateTx) && TxKind(ss.txKind.Load()) == TxAudio
+}
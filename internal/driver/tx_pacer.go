@@ -0,0 +1,69 @@
+package driver
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// TxPacer holds the audio for the rig back to the rate it plays it at, so
+// its buffer doesn't overflow when the sound card delivers in bursts. It's a
+// token bucket, burst samples may go ahead of the rate. A nil TxPacer
+// doesn't wait.
+type TxPacer struct {
+	rate   atomic.Uint64 // float64 bits, samples per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewTxPacer(rate float64, burst int) *TxPacer {
+	if burst <= 0 {
+		return nil
+	}
+
+	tp := &TxPacer{burst: float64(burst)}
+	tp.SetRate(rate)
+
+	return tp
+}
+
+// SetRate changes the rate, once the rig has been measured.
+func (tp *TxPacer) SetRate(rate float64) {
+	if tp == nil {
+		return
+	}
+
+	tp.rate.Store(math.Float64bits(rate))
+}
+
+// Reset fills the bucket, the buffer of the rig is empty at the start of a
+// transmission.
+func (tp *TxPacer) Reset() {
+	if tp == nil {
+		return
+	}
+
+	tp.tokens = tp.burst
+	tp.last = time.Now()
+}
+
+// Wait blocks until count samples may be sent.
+func (tp *TxPacer) Wait(count int) {
+	if tp == nil {
+		return
+	}
+
+	rate := math.Float64frombits(tp.rate.Load())
+	now := time.Now()
+	tp.tokens += now.Sub(tp.last).Seconds() * rate
+	if tp.tokens > tp.burst {
+		tp.tokens = tp.burst
+	}
+	tp.last = now
+
+	tp.tokens -= float64(count)
+	if tp.tokens < 0 {
+		time.Sleep(time.Duration(-tp.tokens / rate * float64(time.Second)))
+	}
+}
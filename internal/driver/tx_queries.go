@@ -0,0 +1,48 @@
+package driver
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+type deferredQuery struct {
+	client *CatClient
+	cmd    string
+}
+
+// deferDuringTx keeps the queries of the clients away from the rig while
+// the TX audio flows, a command in the middle of it breaks the framing of
+// the audio. The frequency and the mode are answered from the state the
+// driver tracks, anything else waits for RX. Commands which change
+// something still go through, between two chunks of the audio. It returns
+// true when the query has been taken care of.
+func (ss *SerialStream) deferDuringTx(client *CatClient, cmd string) bool {
+	if !ss.deferQueries || !isQuery(cmd) || !ss.isTxAudio() {
+		return false
+	}
+
+	if reply, ok := ss.State.Reply(cmd); ok {
+		ss.CatStats.Requested(cmd, true)
+		ss.reply(client, reply)
+		return true
+	}
+
+	ss.deferMu.Lock()
+	defer ss.deferMu.Unlock()
+
+	log.Debugf("[Serial -> Rig]: %s; deferred until RX\n", cmd)
+	ss.deferred = append(ss.deferred, deferredQuery{client, cmd})
+
+	return true
+}
+
+// sendDeferred sends the queries which have waited for the end of TX.
+func (ss *SerialStream) sendDeferred() {
+	ss.deferMu.Lock()
+	deferred := ss.deferred
+	ss.deferred = nil
+	ss.deferMu.Unlock()
+
+	for _, query := range deferred {
+		ss.PushCommandFrom(query.client, query.cmd)
+	}
+}
@@ -0,0 +1,66 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const udevRulesPath = "/etc/udev/rules.d/99-trusdx.rules"
+
+// udevRule lets the members of the group, and whoever is logged in at the
+// seat, use the rig, and links it as /dev/trusdx.
+func udevRule(group string) string {
+	return fmt.Sprintf("# truSDX, the CH340 USB serial converter\n"+
+		"SUBSYSTEM==\"tty\", ATTRS{idVendor}==\"%s\", ATTRS{idProduct}==\"%s\", "+
+		"MODE=\"0660\", GROUP=\"%s\", TAG+=\"uaccess\", SYMLINK+=\"trusdx\"\n",
+		strings.ToLower(rigUsbVid), strings.ToLower(rigUsbPid), group)
+}
+
+// runSetupUdev prints the udev rule of the rig, or installs it with
+// --install, which needs root.
+func runSetupUdev(args []string) error {
+	group := "dialout"
+	isInstall := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--install":
+			isInstall = true
+		case args[i] == "--group" && i+1 < len(args):
+			i++
+			group = args[i]
+		default:
+			return errUsage
+		}
+	}
+
+	rule := udevRule(group)
+	if !isInstall {
+		fmt.Print(rule)
+		return nil
+	}
+
+	if err := os.WriteFile(udevRulesPath, []byte(rule), 0o644); err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("%w, run it with sudo", err)
+		}
+		return err
+	}
+	fmt.Printf("Installed %s\n", udevRulesPath)
+
+	// apply it to a rig which is already plugged in
+	if output, err := exec.Command("udevadm", "control", "--reload-rules").CombinedOutput(); err != nil {
+		return errors.Join(err, errors.New(string(output)))
+	}
+	if output, err := exec.Command("udevadm", "trigger", "--subsystem-match=tty").CombinedOutput(); err != nil {
+		return errors.Join(err, errors.New(string(output)))
+	}
+
+	if user := os.Getenv("SUDO_USER"); user != "" {
+		fmt.Printf("Unless logged in at the machine, %s needs to be in the %s group: usermod -aG %s %s\n", user, group, group, user)
+	}
+
+	return nil
+}
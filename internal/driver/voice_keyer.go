@@ -0,0 +1,159 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leshniak/trusdx-go/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const voiceBanks = 8
+
+// VoiceKeyer records messages from the sound card to the banks F1-F8 and
+// transmits them on demand.
+type VoiceKeyer struct {
+	ss            *SerialStream
+	dir           string
+	mu            sync.Mutex
+	stopRecording chan struct{}
+}
+
+func NewVoiceKeyer(ss *SerialStream) *VoiceKeyer {
+	dir, ok := os.LookupEnv("VOICE_KEYER_DIR")
+	if !ok {
+		dir = filepath.Join(filepath.Dir(config.FilePath()), "voice")
+	}
+
+	return &VoiceKeyer{ss: ss, dir: dir}
+}
+
+// path of the recording of a bank, given as f1-f8 or 1-8
+func (vk *VoiceKeyer) path(bank string) (string, error) {
+	var number int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(strings.ToLower(bank), "f"), "%d", &number); err != nil || number < 1 || number > voiceBanks {
+		return "", fmt.Errorf("invalid bank %q, it's f1 to f%d", bank, voiceBanks)
+	}
+
+	return filepath.Join(vk.dir, fmt.Sprintf("f%d.wav", number)), nil
+}
+
+// Record saves what comes from the sound card to the bank, until the
+// duration passes or Stop is called.
+func (vk *VoiceKeyer) Record(bank string, duration time.Duration) error {
+	path, err := vk.path(bank)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(vk.dir, 0o755); err != nil {
+		return err
+	}
+
+	vk.mu.Lock()
+	if vk.stopRecording != nil {
+		vk.mu.Unlock()
+		return errors.New("already recording")
+	}
+	stop := make(chan struct{})
+	vk.stopRecording = stop
+	vk.mu.Unlock()
+
+	tap, untap := vk.ss.TapTxAudio()
+	var samples []byte
+	timeout := time.After(duration)
+record:
+	for {
+		select {
+		case chunk := <-tap:
+			samples = append(samples, chunk...)
+		case <-timeout:
+			break record
+		case <-stop:
+			break record
+		}
+	}
+	untap()
+
+	vk.mu.Lock()
+	if vk.stopRecording == stop {
+		vk.stopRecording = nil
+	}
+	vk.mu.Unlock()
+
+	if len(samples) == 0 {
+		return errors.New("no audio from the sound card")
+	}
+	log.Infof("Voice keyer: recorded %s\n", path)
+
+	return writeWav(path, samples, vk.ss.TxRate())
+}
+
+// Play transmits the bank, it returns once it has started.
+func (vk *VoiceKeyer) Play(bank string) error {
+	path, err := vk.path(bank)
+	if err != nil {
+		return err
+	}
+
+	samples, err := readWav(path, vk.ss.TxRate())
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s is empty", bank)
+	} else if err != nil {
+		return err
+	}
+
+	_, err = vk.ss.Play(samples, false, 0)
+
+	return err
+}
+
+// Stop ends a recording or a transmission.
+func (vk *VoiceKeyer) Stop() {
+	vk.mu.Lock()
+	if vk.stopRecording != nil {
+		close(vk.stopRecording)
+		vk.stopRecording = nil
+	}
+	vk.mu.Unlock()
+
+	vk.ss.StopPlayback()
+}
+
+func registerVoiceKeyerCommands(cs *ControlServer, vk *VoiceKeyer) {
+	cs.Handle("voice", func(args []string) (string, error) {
+		switch {
+		case len(args) == 0:
+			var output strings.Builder
+			for bank := 1; bank <= voiceBanks; bank++ {
+				path, _ := vk.path(fmt.Sprint(bank))
+				if samples, err := readWav(path, vk.ss.TxRate()); err == nil {
+					fmt.Fprintf(&output, "f%d %.1fs\n", bank, float64(len(samples))/float64(vk.ss.TxRate()))
+				} else {
+					fmt.Fprintf(&output, "f%d -\n", bank)
+				}
+			}
+			return output.String(), nil
+		case args[0] == "stop" && len(args) == 1:
+			vk.Stop()
+			return "", nil
+		case args[0] == "play" && len(args) == 2:
+			return "", vk.Play(args[1])
+		case args[0] == "record" && (len(args) == 2 || len(args) == 3):
+			duration := 10 * time.Second
+			if len(args) == 3 {
+				var err error
+				if duration, err = time.ParseDuration(args[2]); err != nil {
+					return "", err
+				}
+			}
+			return "", vk.Record(args[1], duration)
+		}
+
+		return "", errUsage
+	})
+}
@@ -0,0 +1,119 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// writeWav saves 8-bit unsigned mono samples as a WAV file.
+func writeWav(path string, samples []byte, sampleRate int) error {
+	return os.WriteFile(path, append(wavHeader(len(samples), sampleRate), samples...), 0o644)
+}
+
+// wavHeader is the header of a WAV file of 8-bit unsigned mono samples.
+func wavHeader(length int, sampleRate int) []byte {
+	var header bytes.Buffer
+	header.WriteString("RIFF")
+	binary.Write(&header, binary.LittleEndian, uint32(36+length))
+	header.WriteString("WAVEfmt ")
+	binary.Write(&header, binary.LittleEndian, uint32(16))
+	binary.Write(&header, binary.LittleEndian, uint16(1)) // PCM
+	binary.Write(&header, binary.LittleEndian, uint16(1)) // mono
+	binary.Write(&header, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&header, binary.LittleEndian, uint32(sampleRate)) // bytes per second
+	binary.Write(&header, binary.LittleEndian, uint16(1))          // block align
+	binary.Write(&header, binary.LittleEndian, uint16(8))          // bits per sample
+	header.WriteString("data")
+	binary.Write(&header, binary.LittleEndian, uint32(length))
+
+	return header.Bytes()
+}
+
+// readWav loads a mono 8 or 16-bit PCM WAV file as 8-bit unsigned samples,
+// resampled to sampleRate.
+func readWav(path string, sampleRate int) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("%s: not a WAV file", path)
+	}
+
+	var channels, bits uint16
+	var rate uint32
+	var pcm []byte
+	reader := bytes.NewReader(data[12:])
+	for {
+		var id [4]byte
+		var size uint32
+		if err := binary.Read(reader, binary.LittleEndian, &id); err != nil {
+			break
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+			break
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			// a truncated recording is still worth playing
+			chunk = chunk[:0]
+		}
+		if size%2 == 1 {
+			reader.ReadByte()
+		}
+
+		switch string(id[:]) {
+		case "fmt ":
+			if len(chunk) < 16 || binary.LittleEndian.Uint16(chunk[0:2]) != 1 {
+				return nil, fmt.Errorf("%s: only PCM is supported", path)
+			}
+			channels = binary.LittleEndian.Uint16(chunk[2:4])
+			rate = binary.LittleEndian.Uint32(chunk[4:8])
+			bits = binary.LittleEndian.Uint16(chunk[14:16])
+		case "data":
+			pcm = chunk
+		}
+	}
+	if channels != 1 || (bits != 8 && bits != 16) || rate == 0 {
+		return nil, fmt.Errorf("%s: only mono 8 or 16-bit files are supported", path)
+	}
+	if pcm == nil {
+		return nil, errors.New(path + ": no audio")
+	}
+
+	samples := pcm
+	if bits == 16 {
+		wide := make([]int16, len(pcm)/2)
+		for i := range wide {
+			wide[i] = int16(binary.LittleEndian.Uint16(pcm[2*i:]))
+		}
+		samples = newQuantizerFromEnv().Quantize(wide)
+	}
+
+	return resample(samples, int(rate), sampleRate), nil
+}
+
+// resample converts the rate of 8-bit samples by linear interpolation.
+func resample(samples []byte, from, to int) []byte {
+	if from == to || len(samples) == 0 {
+		return samples
+	}
+
+	resampled := make([]byte, int(int64(len(samples))*int64(to)/int64(from)))
+	for i := range resampled {
+		position := float64(i) * float64(from) / float64(to)
+		j := int(position)
+		if j+1 >= len(samples) {
+			resampled[i] = samples[len(samples)-1]
+			continue
+		}
+		fraction := position - float64(j)
+		resampled[i] = byte(float64(samples[j])*(1-fraction) + float64(samples[j+1])*fraction + 0.5)
+	}
+
+	return resampled
+}
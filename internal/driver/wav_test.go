@@ -0,0 +1,126 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// wavChunk is a RIFF chunk, padded to an even length.
+func wavChunk(id string, body []byte) []byte {
+	chunk := []byte(id)
+	chunk = binary.LittleEndian.AppendUint32(chunk, uint32(len(body)))
+	chunk = append(chunk, body...)
+	if len(body)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+
+	return chunk
+}
+
+func wavFormat(format, channels uint16, rate uint32, bits uint16) []byte {
+	body := binary.LittleEndian.AppendUint16(nil, format)
+	body = binary.LittleEndian.AppendUint16(body, channels)
+	body = binary.LittleEndian.AppendUint32(body, rate)
+	body = binary.LittleEndian.AppendUint32(body, rate*uint32(channels*bits/8))
+	body = binary.LittleEndian.AppendUint16(body, channels*bits/8)
+	body = binary.LittleEndian.AppendUint16(body, bits)
+
+	return wavChunk("fmt ", body)
+}
+
+func wavFile(chunks ...[]byte) []byte {
+	body := []byte("WAVE")
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+
+	return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+}
+
+func TestReadWav(t *testing.T) {
+	t.Setenv("TX_DITHER", ditherOff)
+	wide := []byte{0, 0, 0, 0x40, 0, 0xc0}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		rate    int
+		want    []byte
+		wantErr string
+	}{
+		{
+			name: "8-bit",
+			data: wavFile(wavFormat(1, 1, 8000, 8), wavChunk("data", []byte{0x80, 0xff, 0x00})),
+			rate: 8000,
+			want: []byte{0x80, 0xff, 0x00},
+		},
+		{
+			name: "16-bit",
+			data: wavFile(wavFormat(1, 1, 8000, 16), wavChunk("data", wide)),
+			rate: 8000,
+			want: []byte{0x80, 0xc0, 0x40},
+		},
+		{
+			name: "resampled",
+			data: wavFile(wavFormat(1, 1, 16000, 8), wavChunk("data", []byte{10, 20, 30, 40})),
+			rate: 8000,
+			want: []byte{10, 30},
+		},
+		{
+			name: "odd chunk skipped",
+			data: wavFile(wavFormat(1, 1, 8000, 8), wavChunk("LIST", []byte{1, 2, 3}), wavChunk("data", []byte{0x80})),
+			rate: 8000,
+			want: []byte{0x80},
+		},
+		{
+			name: "truncated data",
+			data: wavFile(wavFormat(1, 1, 8000, 8), wavChunk("data", []byte{0x80, 0x81}))[:44],
+			rate: 8000,
+			want: []byte{},
+		},
+		{
+			name:    "not a WAV file",
+			data:    []byte("ID3 and some mp3"),
+			wantErr: "not a WAV file",
+		},
+		{
+			name:    "not PCM",
+			data:    wavFile(wavFormat(3, 1, 8000, 32), wavChunk("data", []byte{0, 0, 0, 0})),
+			wantErr: "only PCM",
+		},
+		{
+			name:    "stereo",
+			data:    wavFile(wavFormat(1, 2, 8000, 8), wavChunk("data", []byte{0x80, 0x80})),
+			wantErr: "only mono",
+		},
+		{
+			name:    "no data",
+			data:    wavFile(wavFormat(1, 1, 8000, 8)),
+			wantErr: "no audio",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.wav")
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readWav(path, tt.rate)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("readWav() error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !bytes.Equal(got, tt.want) {
+				t.Errorf("readWav() = % x, %v, want % x", got, err, tt.want)
+			}
+		})
+	}
+}
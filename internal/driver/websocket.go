@@ -0,0 +1,170 @@
+package driver
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Just enough of RFC 6455 for streaming audio to and from browsers.
+
+const (
+	wsText   = 0x1
+	wsBinary = 0x2
+	wsClose  = 0x8
+	wsPing   = 0x9
+	wsPong   = 0xa
+)
+
+// the longest message taken from a client
+const wsMaxMessage = 1 << 20
+
+type WebSocket struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex
+}
+
+// isSameOrigin turns away the pages of other sites, which browsers let
+// open WebSockets anywhere. Clients other than browsers send no Origin.
+func isSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*WebSocket, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket", http.StatusBadRequest)
+		return nil, errors.New("not a WebSocket request")
+	}
+	if !isSameOrigin(r) {
+		http.Error(w, "cross-origin WebSocket refused", http.StatusForbidden)
+		return nil, fmt.Errorf("WebSocket from %s refused, the page is at %s", r.RemoteAddr, r.Header.Get("Origin"))
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "can't upgrade", http.StatusInternalServerError)
+		return nil, errors.New("the connection can't be taken over")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	accept := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &WebSocket{conn: conn, rw: rw}, nil
+}
+
+// ReadMessage returns the next text or binary message, it answers pings on
+// the way and returns io.EOF when the client closes.
+func (ws *WebSocket) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(ws.rw, header[:]); err != nil {
+			return nil, err
+		}
+		isFinal := header[0]&0x80 != 0
+		opcode := header[0] & 0x0f
+		isMasked := header[1]&0x80 != 0
+
+		length := uint64(header[1] & 0x7f)
+		switch length {
+		case 126:
+			var extended [2]byte
+			if _, err := io.ReadFull(ws.rw, extended[:]); err != nil {
+				return nil, err
+			}
+			length = uint64(binary.BigEndian.Uint16(extended[:]))
+		case 127:
+			var extended [8]byte
+			if _, err := io.ReadFull(ws.rw, extended[:]); err != nil {
+				return nil, err
+			}
+			length = binary.BigEndian.Uint64(extended[:])
+		}
+		if length > wsMaxMessage || uint64(len(message))+length > wsMaxMessage {
+			return nil, errors.New("WebSocket message too long")
+		}
+
+		var mask [4]byte
+		if isMasked {
+			if _, err := io.ReadFull(ws.rw, mask[:]); err != nil {
+				return nil, err
+			}
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(ws.rw, payload); err != nil {
+			return nil, err
+		}
+		if isMasked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case wsClose:
+			ws.WriteMessage(wsClose, nil)
+			return nil, io.EOF
+		case wsPing:
+			ws.WriteMessage(wsPong, payload)
+			continue
+		case wsPong:
+			continue
+		}
+
+		message = append(message, payload...)
+		if isFinal {
+			return message, nil
+		}
+	}
+}
+
+func (ws *WebSocket) WriteMessage(opcode byte, data []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch {
+	case len(data) < 126:
+		header = append(header, byte(len(data)))
+	case len(data) <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(data)))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(data)))
+	}
+	ws.rw.Write(header)
+	ws.rw.Write(data)
+
+	return ws.rw.Flush()
+}
+
+func (ws *WebSocket) Close() error {
+	return ws.conn.Close()
+}
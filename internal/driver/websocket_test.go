@@ -0,0 +1,160 @@
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// wsFrame builds a frame as a client sends it, masked.
+func wsFrame(isFinal bool, opcode byte, payload []byte) []byte {
+	first := opcode
+	if isFinal {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	return frame
+}
+
+func newTestWebSocket(input []byte) (*WebSocket, *bytes.Buffer) {
+	output := new(bytes.Buffer)
+	rw := bufio.NewReadWriter(bufio.NewReader(bytes.NewReader(input)), bufio.NewWriter(output))
+
+	return &WebSocket{rw: rw}, output
+}
+
+func TestWebSocketReadMessage(t *testing.T) {
+	long := bytes.Repeat([]byte{0xaa}, 300)
+	tests := []struct {
+		name       string
+		input      []byte
+		want       []byte
+		wantErr    error
+		wantOutput []byte
+	}{
+		{
+			name:  "short",
+			input: wsFrame(true, wsBinary, []byte("hello")),
+			want:  []byte("hello"),
+		},
+		{
+			name:  "16-bit length",
+			input: wsFrame(true, wsBinary, long),
+			want:  long,
+		},
+		{
+			name:  "fragmented",
+			input: append(wsFrame(false, wsText, []byte("hel")), wsFrame(true, 0, []byte("lo"))...),
+			want:  []byte("hello"),
+		},
+		{
+			name:       "ping in between",
+			input:      append(wsFrame(true, wsPing, []byte("p")), wsFrame(true, wsText, []byte("hi"))...),
+			want:       []byte("hi"),
+			wantOutput: []byte{0x80 | wsPong, 1, 'p'},
+		},
+		{
+			name:       "close",
+			input:      wsFrame(true, wsClose, nil),
+			wantErr:    io.EOF,
+			wantOutput: []byte{0x80 | wsClose, 0},
+		},
+		{
+			name:    "truncated",
+			input:   wsFrame(true, wsBinary, []byte("hello"))[:8],
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, output := newTestWebSocket(tt.input)
+			got, err := ws.ReadMessage()
+			if !errors.Is(err, tt.wantErr) || !bytes.Equal(got, tt.want) {
+				t.Errorf("ReadMessage() = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+			if !bytes.Equal(output.Bytes(), tt.wantOutput) {
+				t.Errorf("wrote % x, want % x", output.Bytes(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestWebSocketReadMessageTooLong(t *testing.T) {
+	header := []byte{0x80 | wsBinary, 127}
+	header = binary.BigEndian.AppendUint64(header, wsMaxMessage+1)
+	ws, _ := newTestWebSocket(header)
+	if _, err := ws.ReadMessage(); err == nil {
+		t.Error("ReadMessage() of a message over the limit succeeded")
+	}
+}
+
+func TestWebSocketWriteMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		length     int
+		wantHeader []byte
+	}{
+		{name: "short", length: 125, wantHeader: []byte{0x82, 125}},
+		{name: "16-bit length", length: 126, wantHeader: []byte{0x82, 126, 0, 126}},
+		{name: "64-bit length", length: 0x10000, wantHeader: []byte{0x82, 127, 0, 0, 0, 0, 0, 1, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, output := newTestWebSocket(nil)
+			data := bytes.Repeat([]byte{1}, tt.length)
+			if err := ws.WriteMessage(wsBinary, data); err != nil {
+				t.Fatal(err)
+			}
+			frame := output.Bytes()
+			if !bytes.HasPrefix(frame, tt.wantHeader) || !bytes.Equal(frame[len(tt.wantHeader):], data) {
+				t.Errorf("frame starts with % x, want % x and the data", frame[:len(tt.wantHeader)], tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestIsSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		host   string
+		want   bool
+	}{
+		{origin: "", host: "rig.local:8073", want: true},
+		{origin: "http://rig.local:8073", host: "rig.local:8073", want: true},
+		{origin: "http://RIG.local:8073", host: "rig.local:8073", want: true},
+		{origin: "http://evil.example", host: "rig.local:8073", want: false},
+		{origin: "http://rig.local:8074", host: "rig.local:8073", want: false},
+	}
+
+	for _, tt := range tests {
+		r := &http.Request{Host: tt.host, Header: http.Header{}}
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := isSameOrigin(r); got != tt.want {
+			t.Errorf("isSameOrigin(%q from %q) = %v, want %v", tt.host, tt.origin, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package main
+
+import "github.com/leshniak/trusdx-go/internal/driver"
+
+func main() {
+	driver.Main()
+}